@@ -0,0 +1,258 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	netHttp "net/http"
+	"neurobot/app/bot"
+	"neurobot/infrastructure/event"
+	"neurobot/infrastructure/http"
+	"neurobot/infrastructure/matrix"
+	b "neurobot/model/bot"
+	"neurobot/model/payload"
+	t "neurobot/model/trigger"
+	w "neurobot/model/workflow"
+	wfs "neurobot/model/workflowstep"
+	"strings"
+
+	"github.com/apex/log"
+)
+
+// ClientFactory makes the client a bot logs in with, when it is added or activated.
+type ClientFactory func(bot b.Bot) (matrix.Client, error)
+
+// Repositories are the repositories changes are saved with, bound to the transaction they are made within.
+type Repositories struct {
+	Workflows     w.Repository
+	WorkflowSteps wfs.Repository
+	Triggers      t.Repository
+}
+
+// Transaction runs fn with repositories bound to a database transaction, and makes the triggers take the changes it
+// made into account from within it: when fn fails, or when triggers fail to load the changes, they are rolled back
+// and the triggers stay as they were.
+type Transaction func(fn func(repositories Repositories) error) error
+
+type api struct {
+	authenticator          http.Authenticator
+	workflowRepository     w.Repository
+	workflowStepRepository wfs.Repository
+	triggerRepository      t.Repository
+	botRepository          b.Repository
+	botRegistry            bot.Registry
+	newClient              ClientFactory
+	eventBus               event.Bus
+	transaction            Transaction
+	stepSchemas            map[string]wfs.Schema
+	triggerChecks          map[string]t.Check
+	routes                 []route
+}
+
+// route is an endpoint of the API, its handler returns the value to respond with as JSON.
+type route struct {
+	method string
+	path   http.PathPattern
+	handle func(r *netHttp.Request, params map[string]string) (status int, result interface{}, err error)
+}
+
+// apiError is an error which is the fault of the request, responded with its status.
+type apiError struct {
+	status  int
+	message string
+}
+
+func (e apiError) Error() string {
+	return e.message
+}
+
+func badRequest(format string, args ...interface{}) error {
+	return apiError{status: netHttp.StatusBadRequest, message: fmt.Sprintf(format, args...)}
+}
+
+func notFound(format string, args ...interface{}) error {
+	return apiError{status: netHttp.StatusNotFound, message: fmt.Sprintf(format, args...)}
+}
+
+func conflict(format string, args ...interface{}) error {
+	return apiError{status: netHttp.StatusConflict, message: fmt.Sprintf(format, args...)}
+}
+
+// NewAPI makes the admin REST API, which manages workflows, their steps and triggers, and bots while running. Requests
+// must carry the token as `Authorization: Bearer <token>` header.
+func NewAPI(
+	token string,
+	workflowRepository w.Repository,
+	workflowStepRepository wfs.Repository,
+	triggerRepository t.Repository,
+	botRepository b.Repository,
+	botRegistry bot.Registry,
+	newClient ClientFactory,
+	eventBus event.Bus,
+	transaction Transaction,
+	stepSchemas map[string]wfs.Schema,
+	triggerChecks map[string]t.Check,
+) netHttp.Handler {
+	a := &api{
+		authenticator:          http.BearerToken{Token: token},
+		workflowRepository:     workflowRepository,
+		workflowStepRepository: workflowStepRepository,
+		triggerRepository:      triggerRepository,
+		botRepository:          botRepository,
+		botRegistry:            botRegistry,
+		newClient:              newClient,
+		eventBus:               eventBus,
+		transaction:            transaction,
+		stepSchemas:            stepSchemas,
+		triggerChecks:          triggerChecks,
+	}
+
+	a.route(netHttp.MethodGet, "/workflows", a.listWorkflows)
+	a.route(netHttp.MethodPost, "/workflows", a.createWorkflow)
+	a.route(netHttp.MethodGet, "/workflows/{workflow}", a.getWorkflow)
+	a.route(netHttp.MethodPut, "/workflows/{workflow}", a.updateWorkflow)
+	a.route(netHttp.MethodDelete, "/workflows/{workflow}", a.deleteWorkflow)
+	a.route(netHttp.MethodPost, "/workflows/{workflow}/enable", a.enableWorkflow(true))
+	a.route(netHttp.MethodPost, "/workflows/{workflow}/disable", a.enableWorkflow(false))
+	a.route(netHttp.MethodPost, "/workflows/{workflow}/trigger", a.triggerWorkflow)
+	a.route(netHttp.MethodGet, "/workflows/{workflow}/steps", a.listSteps)
+	a.route(netHttp.MethodPost, "/workflows/{workflow}/steps", a.createStep)
+	a.route(netHttp.MethodGet, "/steps/{step}", a.getStep)
+	a.route(netHttp.MethodPut, "/steps/{step}", a.updateStep)
+	a.route(netHttp.MethodDelete, "/steps/{step}", a.deleteStep)
+	a.route(netHttp.MethodGet, "/workflows/{workflow}/triggers", a.listTriggers)
+	a.route(netHttp.MethodPost, "/workflows/{workflow}/triggers", a.createTrigger)
+	a.route(netHttp.MethodGet, "/triggers/{trigger}", a.getTrigger)
+	a.route(netHttp.MethodPut, "/triggers/{trigger}", a.updateTrigger)
+	a.route(netHttp.MethodDelete, "/triggers/{trigger}", a.deleteTrigger)
+	a.route(netHttp.MethodGet, "/bots", a.listBots)
+	a.route(netHttp.MethodPost, "/bots", a.createBot)
+	a.route(netHttp.MethodGet, "/bots/{bot}", a.getBot)
+	a.route(netHttp.MethodPut, "/bots/{bot}", a.updateBot)
+	a.route(netHttp.MethodDelete, "/bots/{bot}", a.deleteBot)
+
+	return a
+}
+
+func (a *api) route(method string, path string, handle func(*netHttp.Request, map[string]string) (int, interface{}, error)) {
+	pattern, err := http.ParsePathPattern(path)
+	if err != nil {
+		panic(err)
+	}
+
+	a.routes = append(a.routes, route{method: method, path: pattern, handle: handle})
+}
+
+func (a *api) ServeHTTP(response netHttp.ResponseWriter, request *netHttp.Request) {
+	if err := a.authenticator.Authenticate(request, nil); err != nil {
+		writeError(response, apiError{status: netHttp.StatusUnauthorized, message: "unauthorized"})
+		return
+	}
+
+	var allowed []string
+	for _, route := range a.routes {
+		params, ok := route.path.Match(request.URL.Path)
+		if !ok {
+			continue
+		}
+		if route.method != request.Method {
+			allowed = append(allowed, route.method)
+			continue
+		}
+
+		status, result, err := route.handle(request, params)
+		if err != nil {
+			writeError(response, err)
+			return
+		}
+
+		writeJSON(response, status, result)
+		return
+	}
+
+	if len(allowed) > 0 {
+		response.Header().Set("Allow", strings.Join(allowed, ", "))
+		writeError(response, apiError{status: netHttp.StatusMethodNotAllowed, message: fmt.Sprintf("method %s not allowed", request.Method)})
+		return
+	}
+
+	writeError(response, notFound("no endpoint found for `%s`", request.URL.Path))
+}
+
+// transact runs fn within the transaction, changes which the triggers fail to load being refused as invalid.
+func (a *api) transact(fn func(repositories Repositories) error) error {
+	var changed bool
+	err := a.transaction(func(repositories Repositories) error {
+		changed = false // the transaction may be retried
+		if err := fn(repositories); err != nil {
+			return err
+		}
+		changed = true
+
+		return nil
+	})
+	if err != nil && changed {
+		return badRequest("triggers failed to load the change, it was rolled back: %s", err)
+	}
+
+	return err
+}
+
+// decode decodes the JSON body of a request into v, fields missing from the body keep the value v already has.
+func decode(request *netHttp.Request, v interface{}) error {
+	decoder := json.NewDecoder(request.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return badRequest("invalid request body: %s", err)
+	}
+
+	return nil
+}
+
+// decodePayload decodes the JSON body of a request as a payload, an empty body being an empty payload.
+func decodePayload(request *netHttp.Request) (payload.Payload, error) {
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		return nil, badRequest("failed to read request body: %s", err)
+	}
+
+	if strings.TrimSpace(string(body)) == "" {
+		return payload.Payload{}, nil
+	}
+
+	p, err := payload.Decode(body)
+	if err != nil {
+		return nil, badRequest("request body must be a JSON object: %s", err)
+	}
+
+	return p, nil
+}
+
+func writeError(response netHttp.ResponseWriter, err error) {
+	var e apiError
+	if !errors.As(err, &e) {
+		log.WithError(err).Error("Admin API request failed")
+		e = apiError{status: netHttp.StatusInternalServerError, message: "internal error"}
+	}
+
+	writeJSON(response, e.status, map[string]string{"error": e.message})
+}
+
+func writeJSON(response netHttp.ResponseWriter, status int, value interface{}) {
+	if status == netHttp.StatusNoContent {
+		response.WriteHeader(status)
+		return
+	}
+
+	body, err := json.Marshal(value)
+	if err != nil {
+		netHttp.Error(response, "failed to encode response", netHttp.StatusInternalServerError)
+		return
+	}
+
+	response.Header().Set("Content-Type", "application/json")
+	response.WriteHeader(status)
+	response.Write(body)
+}
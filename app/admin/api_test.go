@@ -0,0 +1,432 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	netHttp "net/http"
+	"net/http/httptest"
+	"neurobot/app/bot"
+	"neurobot/app/trigger"
+	"neurobot/app/workflow"
+	"neurobot/app/workflowstep"
+	"neurobot/infrastructure/event"
+	"neurobot/infrastructure/matrix"
+	b "neurobot/model/bot"
+	"neurobot/model/message"
+	"neurobot/model/payload"
+	"neurobot/model/room"
+	trm "neurobot/model/trigger"
+	wfs "neurobot/model/workflowstep"
+	"neurobot/resources/tests/database"
+	"neurobot/resources/tests/fixtures"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/upper/db/v4"
+)
+
+type clientMock struct {
+	loggedIn        bool
+	stopped         bool
+	messageHandlers []func(roomID room.ID, sender string, message message.Message)
+}
+
+// receive makes the client receive a message, as the handlers it was given see it.
+func (c *clientMock) receive(roomID room.ID, sender string, message message.Message) {
+	for _, handler := range c.messageHandlers {
+		handler(roomID, sender, message)
+	}
+}
+
+func (c *clientMock) Login(ctx context.Context, username string, password string) error {
+	c.loggedIn = true
+	return nil
+}
+func (c *clientMock) JoinRoom(ctx context.Context, id room.ID) error { return nil }
+func (c *clientMock) SendMessage(ctx context.Context, roomID room.ID, message message.Message) error {
+	return nil
+}
+func (c *clientMock) Stop()                                           { c.stopped = true }
+func (c *clientMock) OnRoomInvite(handler func(roomID room.ID)) error { return nil }
+func (c *clientMock) OnMessage(handler func(roomID room.ID, sender string, message message.Message)) error {
+	c.messageHandlers = append(c.messageHandlers, handler)
+	return nil
+}
+func (c *clientMock) OnReaction(handler func(roomID room.ID, sender string, eventID string, key string)) error {
+	return nil
+}
+
+type apiTest struct {
+	t       *testing.T
+	handler netHttp.Handler
+}
+
+// do makes an authenticated request to the API, and decodes its JSON response into result when not nil.
+func (a apiTest) do(method string, target string, body string, status int, result interface{}) {
+	request := httptest.NewRequest(method, target, strings.NewReader(body))
+	request.Header.Set("Authorization", "Bearer s3cret")
+	recorder := httptest.NewRecorder()
+	a.handler.ServeHTTP(recorder, request)
+
+	if recorder.Code != status {
+		a.t.Errorf("%s %s: expected status %d, got %d: %s", method, target, status, recorder.Code, recorder.Body)
+		return
+	}
+
+	if result != nil {
+		if err := json.Unmarshal(recorder.Body.Bytes(), result); err != nil {
+			a.t.Errorf("%s %s: invalid JSON response: %s", method, target, err)
+		}
+	}
+}
+
+// newAPITest makes the API, its changes being made within a savepoint of the test transaction, which is rolled back
+// when they fail or when the command listener fails to load them.
+func newAPITest(t *testing.T, session db.Session, reloads *int, clients map[string]*clientMock) (apiTest, event.Bus) {
+	bus := event.NewMemoryBus()
+	registry := bot.NewRegistry("matrix.test")
+	schemas := map[string]wfs.Schema{
+		"stdout":            {},
+		"postMatrixMessage": {"message": {Type: wfs.StringMeta, Required: true}, "asBot": {Type: wfs.StringMeta}},
+	}
+	newClient := func(bot b.Bot) (matrix.Client, error) {
+		clients[bot.Username] = &clientMock{}
+		return clients[bot.Username], nil
+	}
+
+	repositories := Repositories{
+		Workflows:     workflow.NewRepository(session),
+		WorkflowSteps: workflowstep.NewRepository(session),
+		Triggers:      trigger.NewRepository(session),
+	}
+	commands := trigger.NewCommandListener(repositories.Triggers, repositories.Workflows, registry, bus)
+	transaction := func(fn func(repositories Repositories) error) error {
+		if _, err := session.SQL().Exec("SAVEPOINT change"); err != nil {
+			return err
+		}
+
+		swap, err := func() (func(), error) {
+			if err := fn(repositories); err != nil {
+				return nil, err
+			}
+			return commands.Load(repositories.Triggers, repositories.Workflows)
+		}()
+		if err != nil {
+			session.SQL().Exec("ROLLBACK TO change")
+		}
+		session.SQL().Exec("RELEASE change")
+		if err != nil {
+			return err
+		}
+
+		*reloads++
+		swap()
+
+		return nil
+	}
+
+	handler := NewAPI(
+		"s3cret",
+		repositories.Workflows,
+		repositories.WorkflowSteps,
+		repositories.Triggers,
+		bot.NewRepository(session),
+		registry,
+		newClient,
+		bus,
+		transaction,
+		schemas,
+		trigger.Checks(),
+	)
+
+	return apiTest{t: t, handler: handler}, bus
+}
+
+func TestUnauthorized(t *testing.T) {
+	database.Test(func(session db.Session) {
+		a, _ := newAPITest(t, session, new(int), make(map[string]*clientMock))
+
+		for _, header := range []string{"", "Bearer wrong", "s3cret"} {
+			request := httptest.NewRequest(netHttp.MethodGet, "/workflows", nil)
+			request.Header.Set("Authorization", header)
+			recorder := httptest.NewRecorder()
+			a.handler.ServeHTTP(recorder, request)
+
+			if recorder.Code != netHttp.StatusUnauthorized {
+				t.Errorf("%q: expected status 401, got %d", header, recorder.Code)
+			}
+		}
+	})
+}
+
+func TestRouting(t *testing.T) {
+	database.Test(func(session db.Session) {
+		a, _ := newAPITest(t, session, new(int), make(map[string]*clientMock))
+
+		a.do(netHttp.MethodGet, "/unknown", "", netHttp.StatusNotFound, nil)
+		a.do(netHttp.MethodPatch, "/workflows", "", netHttp.StatusMethodNotAllowed, nil)
+		a.do(netHttp.MethodGet, "/workflows/UNKNOWN", "", netHttp.StatusNotFound, nil)
+		a.do(netHttp.MethodGet, "/steps/foo", "", netHttp.StatusNotFound, nil)
+	})
+}
+
+func TestWorkflows(t *testing.T) {
+	database.Test(func(session db.Session) {
+		fixtures.Workflows(session)
+		reloads := 0
+		a, bus := newAPITest(t, session, &reloads, make(map[string]*clientMock))
+
+		var workflows []workflowJSON
+		a.do(netHttp.MethodGet, "/workflows", "", netHttp.StatusOK, &workflows)
+		if len(workflows) != 5 || workflows[2].Identifier != "DEACTIVATED" || workflows[2].Active {
+			t.Errorf("unexpected workflows %+v", workflows)
+		}
+
+		var created workflowJSON
+		a.do(netHttp.MethodPost, "/workflows", `{"identifier": "DEPLOY", "name": "Deploy", "onError": "halt"}`, netHttp.StatusCreated, &created)
+		if created.ID == 0 || !created.Active || created.Name != "Deploy" || created.OnError != "halt" || reloads != 1 {
+			t.Errorf("unexpected workflow %+v, reloaded %d times", created, reloads)
+		}
+
+		a.do(netHttp.MethodPost, "/workflows", `{"identifier": "DEPLOY"}`, netHttp.StatusConflict, nil)
+		a.do(netHttp.MethodPost, "/workflows", `{"identifier": "SHIP", "onError": "panic"}`, netHttp.StatusBadRequest, nil)
+		a.do(netHttp.MethodPost, "/workflows", `{"identifier": "SHIP", "timeout": "-1s"}`, netHttp.StatusBadRequest, nil)
+		a.do(netHttp.MethodPost, "/workflows", `{"identifier": "SHIP", "unknown": true}`, netHttp.StatusBadRequest, nil)
+		a.do(netHttp.MethodPost, "/workflows", `{"name": "No identifier"}`, netHttp.StatusBadRequest, nil)
+
+		var updated workflowJSON
+		a.do(netHttp.MethodPut, "/workflows/DEPLOY", `{"description": "Ships it"}`, netHttp.StatusOK, &updated)
+		expected := created
+		expected.Description = "Ships it"
+		if !reflect.DeepEqual(updated, expected) {
+			t.Errorf("fields missing from the request should be kept\n%+v\n%+v", updated, expected)
+		}
+
+		var got workflowJSON
+		a.do(netHttp.MethodPost, "/workflows/DEPLOY/disable", "", netHttp.StatusOK, &got)
+		if got.Active {
+			t.Errorf("workflow should be disabled")
+		}
+		a.do(netHttp.MethodPost, "/workflows/DEPLOY/trigger", `{"env": "production"}`, netHttp.StatusConflict, nil)
+
+		a.do(netHttp.MethodPost, "/workflows/DEPLOY/enable", "", netHttp.StatusOK, &got)
+		if !got.Active {
+			t.Errorf("workflow should be enabled")
+		}
+
+		var triggered []event.Trigger
+		bus.Subscribe(event.TriggerTopic(), func(e interface{}) {
+			trigger := e.(event.Trigger)
+			triggered = append(triggered, trigger)
+			trigger.Queued <- 42
+		})
+
+		var run map[string]uint64
+		a.do(netHttp.MethodPost, "/workflows/DEPLOY/trigger", `{"env": "production"}`, netHttp.StatusAccepted, &run)
+		if run["run"] != 42 || len(triggered) != 1 || triggered[0].WorkflowIdentifier != "DEPLOY" || triggered[0].Variety != ManualVariety ||
+			!reflect.DeepEqual(triggered[0].Payload, payload.Payload{"env": "production"}) {
+			t.Errorf("unexpected run %+v of trigger %+v", run, triggered)
+		}
+		a.do(netHttp.MethodPost, "/workflows/DEPLOY/trigger", `["production"]`, netHttp.StatusBadRequest, nil)
+
+		a.do(netHttp.MethodPost, "/workflows/DEPLOY/steps", `{"name": "Print", "variety": "stdout"}`, netHttp.StatusCreated, nil)
+		a.do(netHttp.MethodDelete, "/workflows/DEPLOY", "", netHttp.StatusNoContent, nil)
+		a.do(netHttp.MethodGet, "/workflows/DEPLOY", "", netHttp.StatusNotFound, nil)
+
+		if steps, _ := workflowstep.NewRepository(session).FindByWorkflowID(created.ID); len(steps) > 0 {
+			t.Errorf("steps of a deleted workflow should be removed, got %+v", steps)
+		}
+	})
+}
+
+func TestSteps(t *testing.T) {
+	database.Test(func(session db.Session) {
+		fixtures.Workflows(session)
+		a, _ := newAPITest(t, session, new(int), make(map[string]*clientMock))
+
+		var first, second stepJSON
+		a.do(netHttp.MethodPost, "/workflows/11/steps", `{"name": "Print", "variety": "stdout"}`, netHttp.StatusCreated, &first)
+		a.do(netHttp.MethodPost, "/workflows/MVP/steps", `{"name": "Post", "variety": "postMatrixMessage", "if": "payload.env == \"production\"", "meta": {"message": "Deployed"}}`, netHttp.StatusCreated, &second)
+		if first.WorkflowID != 11 || !first.Active || first.SortOrder != 0 || second.SortOrder != 1 || second.If != `payload.env == "production"` {
+			t.Errorf("steps should be added after the last one\n%+v\n%+v", first, second)
+		}
+
+		a.do(netHttp.MethodPost, "/workflows/MVP/steps", `{"variety": "unknown"}`, netHttp.StatusBadRequest, nil)
+		a.do(netHttp.MethodPost, "/workflows/MVP/steps", `{"variety": "postMatrixMessage"}`, netHttp.StatusBadRequest, nil)
+		a.do(netHttp.MethodPost, "/workflows/MVP/steps", `{"variety": "stdout", "if": "payload.env =="}`, netHttp.StatusBadRequest, nil)
+
+		var steps []stepJSON
+		a.do(netHttp.MethodGet, "/workflows/MVP/steps", "", netHttp.StatusOK, &steps)
+		if !reflect.DeepEqual(steps, []stepJSON{first, second}) {
+			t.Errorf("unexpected steps\n%+v\n%+v", steps, []stepJSON{first, second})
+		}
+
+		var updated stepJSON
+		target := "/steps/" + strconv.FormatUint(second.ID, 10)
+		a.do(netHttp.MethodPut, target, `{"name": "Announce"}`, netHttp.StatusOK, &updated)
+		if updated.Name != "Announce" || !reflect.DeepEqual(updated.Meta, second.Meta) {
+			t.Errorf("meta should be kept when not in the request, got %+v", updated)
+		}
+
+		a.do(netHttp.MethodPut, target, `{"meta": {"message": "Shipped"}}`, netHttp.StatusOK, &updated)
+		if !reflect.DeepEqual(updated.Meta, map[string]string{"message": "Shipped"}) {
+			t.Errorf("meta should be replaced, got %+v", updated.Meta)
+		}
+
+		a.do(netHttp.MethodPut, target, `{"workflowId": 1}`, netHttp.StatusBadRequest, nil)
+
+		a.do(netHttp.MethodDelete, target, "", netHttp.StatusNoContent, nil)
+		a.do(netHttp.MethodGet, target, "", netHttp.StatusNotFound, nil)
+	})
+}
+
+func TestTriggers(t *testing.T) {
+	database.Test(func(session db.Session) {
+		fixtures.Workflows(session)
+		reloads := 0
+		a, _ := newAPITest(t, session, &reloads, make(map[string]*clientMock))
+
+		var created triggerJSON
+		a.do(netHttp.MethodPost, "/workflows/MVP/triggers", `{"variety": "webhook", "meta": {"path": "/deploy"}}`, netHttp.StatusCreated, &created)
+		if created.ID == 0 || created.WorkflowID != 11 || created.Meta["path"] != "/deploy" || reloads != 1 {
+			t.Errorf("unexpected trigger %+v, reloaded %d times", created, reloads)
+		}
+
+		a.do(netHttp.MethodPost, "/workflows/MVP/triggers", `{"variety": "carrierPigeon"}`, netHttp.StatusBadRequest, nil)
+		a.do(netHttp.MethodPost, "/workflows/MVP/triggers", `{"variety": "schedule", "meta": {"cron": "every day"}}`, netHttp.StatusBadRequest, nil)
+		// Commands of unknown bots fail to load, the trigger is rolled back
+		a.do(netHttp.MethodPost, "/workflows/MVP/triggers", `{"variety": "matrixCommand", "meta": {"command": "!deploy", "asBot": "nobody"}}`, netHttp.StatusBadRequest, nil)
+
+		var triggers []triggerJSON
+		a.do(netHttp.MethodGet, "/workflows/MVP/triggers", "", netHttp.StatusOK, &triggers)
+		if !reflect.DeepEqual(triggers, []triggerJSON{created}) || reloads != 1 {
+			t.Errorf("invalid triggers should not be saved, got %+v, reloaded %d times", triggers, reloads)
+		}
+
+		var updated triggerJSON
+		target := "/triggers/" + strconv.FormatUint(created.ID, 10)
+		a.do(netHttp.MethodPut, target, `{"meta": {"path": "/ship", "methods": "post"}}`, netHttp.StatusOK, &updated)
+		if !reflect.DeepEqual(updated.Meta, map[string]string{"path": "/ship", "methods": "post"}) || updated.Variety != "webhook" {
+			t.Errorf("meta should be replaced, got %+v", updated)
+		}
+
+		a.do(netHttp.MethodPut, target, `{"variety": "schedule"}`, netHttp.StatusBadRequest, nil)
+		a.do(netHttp.MethodPut, target, `{"workflowId": 1}`, netHttp.StatusBadRequest, nil)
+
+		var got triggerJSON
+		a.do(netHttp.MethodGet, target, "", netHttp.StatusOK, &got)
+		if !reflect.DeepEqual(got, updated) {
+			t.Errorf("failed updates should change nothing\n%+v\n%+v", got, updated)
+		}
+
+		a.do(netHttp.MethodDelete, target, "", netHttp.StatusNoContent, nil)
+		a.do(netHttp.MethodGet, target, "", netHttp.StatusNotFound, nil)
+		a.do(netHttp.MethodGet, "/triggers/foo", "", netHttp.StatusNotFound, nil)
+
+		// Enabling a workflow whose triggers fail to load is rolled back too
+		invalid := trm.Trigger{WorkflowID: 12, Variety: "matrixCommand", Meta: map[string]string{"command": "!deploy", "asBot": "nobody"}}
+		if err := trigger.NewRepository(session).Save(&invalid); err != nil {
+			t.Fatalf("failed to save trigger: %s", err)
+		}
+
+		a.do(netHttp.MethodPost, "/workflows/DEACTIVATED/enable", "", netHttp.StatusBadRequest, nil)
+		var deactivated workflowJSON
+		a.do(netHttp.MethodGet, "/workflows/DEACTIVATED", "", netHttp.StatusOK, &deactivated)
+		if deactivated.Active {
+			t.Errorf("workflow should still be disabled")
+		}
+	})
+}
+
+func TestBots(t *testing.T) {
+	database.Test(func(session db.Session) {
+		fixtures.Bots(session)
+		clients := make(map[string]*clientMock)
+		a, _ := newAPITest(t, session, new(int), clients)
+
+		var bots []map[string]interface{}
+		a.do(netHttp.MethodGet, "/bots", "", netHttp.StatusOK, &bots)
+		if len(bots) != 3 {
+			t.Errorf("expected 3 bots, got %+v", bots)
+		}
+		for _, bot := range bots {
+			if _, ok := bot["password"]; ok {
+				t.Errorf("passwords should never be responded with, got %+v", bot)
+			}
+		}
+
+		var created botJSON
+		a.do(netHttp.MethodPost, "/bots", `{"username": "deploybot", "password": "p4ss", "description": "Deploys"}`, netHttp.StatusCreated, &created)
+		if created.ID == 0 || !created.Active || created.Password != "" {
+			t.Errorf("unexpected bot %+v", created)
+		}
+		if clients["deploybot"] == nil || !clients["deploybot"].loggedIn {
+			t.Errorf("active bots should log in when added")
+		}
+
+		a.do(netHttp.MethodPost, "/bots", `{"username": "deploybot", "password": "p4ss"}`, netHttp.StatusConflict, nil)
+		a.do(netHttp.MethodPost, "/bots", `{"username": "shipbot"}`, netHttp.StatusBadRequest, nil)
+
+		var updated botJSON
+		a.do(netHttp.MethodPut, "/bots/deploybot", `{"active": false}`, netHttp.StatusOK, &updated)
+		if updated.Active || updated.Description != "Deploys" || !clients["deploybot"].stopped {
+			t.Errorf("bots should stop when deactivated, got %+v", updated)
+		}
+
+		stored, _ := bot.NewRepository(session).FindByUsername("deploybot")
+		if stored.Password != "p4ss" {
+			t.Errorf("password should be kept when not in the request")
+		}
+
+		a.do(netHttp.MethodPut, "/bots/1", `{"active": false}`, netHttp.StatusConflict, nil)
+		a.do(netHttp.MethodDelete, "/bots/1", "", netHttp.StatusConflict, nil)
+
+		a.do(netHttp.MethodDelete, "/bots/deploybot", "", netHttp.StatusNoContent, nil)
+		a.do(netHttp.MethodGet, "/bots/deploybot", "", netHttp.StatusNotFound, nil)
+	})
+}
+
+func TestBotChanges(t *testing.T) {
+	database.Test(func(session db.Session) {
+		fixtures.Workflows(session)
+		fixtures.Bots(session)
+		clients := make(map[string]*clientMock)
+		a, bus := newAPITest(t, session, new(int), clients)
+
+		var triggered []event.Trigger
+		bus.Subscribe(event.TriggerTopic(), func(e interface{}) {
+			triggered = append(triggered, e.(event.Trigger))
+		})
+
+		a.do(netHttp.MethodPost, "/bots", `{"username": "deploybot", "password": "p4ss"}`, netHttp.StatusCreated, nil)
+		a.do(netHttp.MethodPost, "/workflows/MVP/triggers", `{"variety": "matrixCommand", "meta": {"command": "!deploy", "asBot": "deploybot"}}`, netHttp.StatusCreated, nil)
+
+		// Commands are listened for with the client the bot logged in again with, not the stopped one
+		previous := clients["deploybot"]
+		a.do(netHttp.MethodPut, "/bots/deploybot", `{"password": "n3w"}`, netHttp.StatusOK, nil)
+		if clients["deploybot"] == previous || !previous.stopped {
+			t.Fatalf("bot should log in again with another client")
+		}
+
+		ops, _ := room.NewID("!ops:matrix.test")
+		previous.receive(ops, "@alice:matrix.test", message.NewPlainTextMessage("!deploy"))
+		clients["deploybot"].receive(ops, "@alice:matrix.test", message.NewPlainTextMessage("!deploy"))
+		if len(triggered) != 1 || triggered[0].WorkflowIdentifier != "MVP" || triggered[0].Variety != trigger.CommandVariety {
+			t.Errorf("expected a single command trigger, got %+v", triggered)
+		}
+
+		// Triggers would fail to load without the bots they listen as
+		a.do(netHttp.MethodPut, "/bots/deploybot", `{"active": false}`, netHttp.StatusConflict, nil)
+		a.do(netHttp.MethodPut, "/bots/deploybot", `{"username": "shipbot"}`, netHttp.StatusConflict, nil)
+		a.do(netHttp.MethodDelete, "/bots/deploybot", "", netHttp.StatusConflict, nil)
+		a.do(netHttp.MethodPut, "/bots/deploybot", `{"description": "Ships"}`, netHttp.StatusOK, nil)
+
+		// Nor could steps run without the bots they act as
+		a.do(netHttp.MethodPost, "/bots", `{"username": "announcebot", "password": "p4ss"}`, netHttp.StatusCreated, nil)
+		a.do(netHttp.MethodPost, "/workflows/MVP/steps", `{"variety": "postMatrixMessage", "meta": {"message": "Deployed", "asBot": "announcebot"}}`, netHttp.StatusCreated, nil)
+		a.do(netHttp.MethodPut, "/bots/announcebot", `{"username": "shoutbot"}`, netHttp.StatusConflict, nil)
+		a.do(netHttp.MethodDelete, "/bots/announcebot", "", netHttp.StatusConflict, nil)
+	})
+}
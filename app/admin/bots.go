@@ -0,0 +1,250 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	netHttp "net/http"
+	b "neurobot/model/bot"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/upper/db/v4"
+)
+
+// loginTimeout is how long a bot is given to log in, when it is added or activated.
+const loginTimeout = 30 * time.Second
+
+// botJSON is a bot as exchanged with the API, its password is never responded with.
+type botJSON struct {
+	ID          uint64 `json:"id"`
+	Username    string `json:"username"`
+	Password    string `json:"password,omitempty"`
+	Description string `json:"description"`
+	Active      bool   `json:"active"`
+}
+
+func toBotJSON(bot b.Bot) botJSON {
+	return botJSON{
+		ID:          bot.ID,
+		Username:    bot.Username,
+		Description: bot.Description,
+		Active:      bot.Active,
+	}
+}
+
+func (j botJSON) apply(bot *b.Bot) {
+	bot.Username = j.Username
+	bot.Description = j.Description
+	bot.Active = j.Active
+	if j.Password != "" {
+		bot.Password = j.Password
+	}
+}
+
+func (a *api) listBots(r *netHttp.Request, params map[string]string) (int, interface{}, error) {
+	bots, err := a.botRepository.FindAll()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	result := make([]botJSON, 0, len(bots))
+	for _, bot := range bots {
+		result = append(result, toBotJSON(bot))
+	}
+
+	return netHttp.StatusOK, result, nil
+}
+
+func (a *api) getBot(r *netHttp.Request, params map[string]string) (int, interface{}, error) {
+	bot, err := a.findBot(params["bot"])
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return netHttp.StatusOK, toBotJSON(bot), nil
+}
+
+// createBot adds a bot, which logs in right away when active, triggers listening as it once reloaded.
+func (a *api) createBot(r *netHttp.Request, params map[string]string) (int, interface{}, error) {
+	j := botJSON{Active: true}
+	if err := decode(r, &j); err != nil {
+		return 0, nil, err
+	}
+	if j.Password == "" {
+		return 0, nil, badRequest("password must not be empty")
+	}
+
+	var bot b.Bot
+	j.apply(&bot)
+	if err := a.saveBot(&bot); err != nil {
+		return 0, nil, err
+	}
+	if err := a.login(bot); err != nil {
+		return 0, nil, err
+	}
+
+	return netHttp.StatusCreated, toBotJSON(bot), a.reload()
+}
+
+// updateBot updates a bot, which logs in again when active, triggers listening as it with its new client once
+// reloaded. Its password is kept when the request has none.
+func (a *api) updateBot(r *netHttp.Request, params map[string]string) (int, interface{}, error) {
+	bot, err := a.findBot(params["bot"])
+	if err != nil {
+		return 0, nil, err
+	}
+	if bot.IsPrimary() {
+		return 0, nil, conflict("the primary bot is configured in the .env file")
+	}
+
+	previousUsername := bot.Username
+	j := toBotJSON(bot)
+	if err = decode(r, &j); err != nil {
+		return 0, nil, err
+	}
+
+	if (bot.Active && !j.Active) || j.Username != previousUsername {
+		if err = a.checkBotUnused(previousUsername); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	j.apply(&bot)
+	if err = a.saveBot(&bot); err != nil {
+		return 0, nil, err
+	}
+
+	a.botRegistry.Remove(previousUsername)
+	if err = a.login(bot); err != nil {
+		return 0, nil, err
+	}
+
+	return netHttp.StatusOK, toBotJSON(bot), a.reload()
+}
+
+// deleteBot removes a bot, unless triggers listen as it or steps act as it.
+func (a *api) deleteBot(r *netHttp.Request, params map[string]string) (int, interface{}, error) {
+	bot, err := a.findBot(params["bot"])
+	if err != nil {
+		return 0, nil, err
+	}
+	if bot.IsPrimary() {
+		return 0, nil, conflict("the primary bot is configured in the .env file")
+	}
+
+	if err = a.checkBotUnused(bot.Username); err != nil {
+		return 0, nil, err
+	}
+
+	if err = a.botRepository.Remove(bot.ID); err != nil {
+		return 0, nil, err
+	}
+
+	a.botRegistry.Remove(bot.Username)
+
+	return netHttp.StatusNoContent, nil, a.reload()
+}
+
+// findBot finds a bot by its ID, or by its username.
+func (a *api) findBot(key string) (bot b.Bot, err error) {
+	if ID, parseErr := strconv.ParseUint(key, 10, 64); parseErr == nil {
+		bot, err = a.botRepository.FindByID(ID)
+	} else {
+		bot, err = a.botRepository.FindByUsername(key)
+	}
+
+	if errors.Is(err, db.ErrNoMoreRows) {
+		err = notFound("bot %s not found", key)
+	}
+
+	return
+}
+
+func (a *api) saveBot(bot *b.Bot) error {
+	if bot.Username == "" {
+		return badRequest("username must not be empty")
+	}
+	if _, err := strconv.ParseUint(bot.Username, 10, 64); err == nil {
+		return badRequest("username must not be a number, got %q", bot.Username)
+	}
+
+	existing, err := a.botRepository.FindByUsername(bot.Username)
+	switch {
+	case err == nil && existing.ID != bot.ID:
+		return conflict("bot %s already exists", bot.Username)
+	case err != nil && !errors.Is(err, db.ErrNoMoreRows):
+		return err
+	}
+
+	return a.botRepository.Save(bot)
+}
+
+// checkBotUnused makes sure no trigger listens as a bot and no step acts as it, as triggers would fail to load and
+// steps would fail to run without it. Bots aren't saved within the transaction triggers are reloaded from, changes to
+// them can't be rolled back.
+func (a *api) checkBotUnused(username string) error {
+	workflows, err := a.workflowRepository.FindAll()
+	if err != nil {
+		return err
+	}
+
+	for _, workflow := range workflows {
+		steps, err := a.workflowStepRepository.FindByWorkflowID(workflow.ID)
+		if err != nil {
+			return err
+		}
+
+		for _, step := range steps {
+			if strings.TrimSpace(step.Meta["asBot"]) == username {
+				return conflict("bot %s is used by step %d of workflow %s", username, step.ID, workflow.Identifier)
+			}
+		}
+	}
+
+	for variety := range a.triggerChecks {
+		triggers, err := a.triggerRepository.FindByVariety(variety)
+		if err != nil {
+			return err
+		}
+
+		for _, trigger := range triggers {
+			for _, botName := range strings.Split(trigger.Meta["asBot"], ",") {
+				if strings.TrimSpace(botName) == username {
+					return conflict("bot %s is used by trigger %d of workflow %d", username, trigger.ID, trigger.WorkflowID)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// reload reloads the triggers, so that they listen as the bots which logged in again.
+func (a *api) reload() error {
+	return a.transaction(func(repositories Repositories) error {
+		return nil
+	})
+}
+
+// login logs an active bot in and adds it to the registry, so that workflows can use it right away.
+func (a *api) login(bot b.Bot) error {
+	if !bot.Active {
+		return nil
+	}
+
+	client, err := a.newClient(bot)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), loginTimeout)
+	defer cancel()
+
+	if err = a.botRegistry.Append(ctx, bot, client); err != nil {
+		return apiError{status: netHttp.StatusBadGateway, message: fmt.Sprintf("bot %s was saved, but failed to log in: %s", bot.Username, err)}
+	}
+
+	return nil
+}
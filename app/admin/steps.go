@@ -0,0 +1,208 @@
+package admin
+
+import (
+	"errors"
+	netHttp "net/http"
+	"neurobot/infrastructure/condition"
+	wfs "neurobot/model/workflowstep"
+	"strconv"
+
+	"github.com/upper/db/v4"
+)
+
+type stepJSON struct {
+	ID           uint64            `json:"id"`
+	WorkflowID   uint64            `json:"workflowId"`
+	SortOrder    uint64            `json:"sortOrder"`
+	Name         string            `json:"name"`
+	Description  string            `json:"description"`
+	Variety      string            `json:"variety"`
+	Active       bool              `json:"active"`
+	If           string            `json:"if"`
+	OnError      string            `json:"onError"`
+	MaxAttempts  uint64            `json:"maxAttempts"`
+	RetryBackoff string            `json:"retryBackoff"`
+	Timeout      string            `json:"timeout"`
+	Meta         map[string]string `json:"meta"`
+}
+
+func toStepJSON(step wfs.WorkflowStep) stepJSON {
+	meta := step.Meta
+	if meta == nil {
+		meta = make(map[string]string)
+	}
+
+	return stepJSON{
+		ID:           step.ID,
+		WorkflowID:   step.WorkflowID,
+		SortOrder:    step.SortOrder,
+		Name:         step.Name,
+		Description:  step.Description,
+		Variety:      step.Variety,
+		Active:       step.Active,
+		If:           step.Condition,
+		OnError:      step.OnError,
+		MaxAttempts:  step.MaxAttempts,
+		RetryBackoff: step.RetryBackoff,
+		Timeout:      step.Timeout,
+		Meta:         meta,
+	}
+}
+
+func (j stepJSON) apply(step *wfs.WorkflowStep) {
+	step.SortOrder = j.SortOrder
+	step.Name = j.Name
+	step.Description = j.Description
+	step.Variety = j.Variety
+	step.Active = j.Active
+	step.Condition = j.If
+	step.OnError = j.OnError
+	step.MaxAttempts = j.MaxAttempts
+	step.RetryBackoff = j.RetryBackoff
+	step.Timeout = j.Timeout
+	step.Meta = j.Meta
+}
+
+func (a *api) listSteps(r *netHttp.Request, params map[string]string) (int, interface{}, error) {
+	workflow, err := findWorkflow(a.workflowRepository, params["workflow"])
+	if err != nil {
+		return 0, nil, err
+	}
+
+	steps, err := a.workflowStepRepository.FindByWorkflowID(workflow.ID)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	result := make([]stepJSON, 0, len(steps))
+	for _, step := range steps {
+		result = append(result, toStepJSON(step))
+	}
+
+	return netHttp.StatusOK, result, nil
+}
+
+func (a *api) getStep(r *netHttp.Request, params map[string]string) (int, interface{}, error) {
+	step, err := a.findStep(params["step"])
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return netHttp.StatusOK, toStepJSON(step), nil
+}
+
+// createStep adds a step to a workflow, after its last step unless it has a sortOrder.
+func (a *api) createStep(r *netHttp.Request, params map[string]string) (int, interface{}, error) {
+	workflow, err := findWorkflow(a.workflowRepository, params["workflow"])
+	if err != nil {
+		return 0, nil, err
+	}
+
+	steps, err := a.workflowStepRepository.FindByWorkflowID(workflow.ID)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	j := stepJSON{Active: true}
+	for _, step := range steps {
+		if step.SortOrder >= j.SortOrder {
+			j.SortOrder = step.SortOrder + 1
+		}
+	}
+	if err = decode(r, &j); err != nil {
+		return 0, nil, err
+	}
+
+	var step wfs.WorkflowStep
+	err = a.transact(func(repositories Repositories) error {
+		step = wfs.WorkflowStep{WorkflowID: workflow.ID}
+		j.apply(&step)
+		return a.saveStep(repositories, &step)
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return netHttp.StatusCreated, toStepJSON(step), nil
+}
+
+// updateStep updates a step, its meta is replaced when the request has one.
+func (a *api) updateStep(r *netHttp.Request, params map[string]string) (int, interface{}, error) {
+	step, err := a.findStep(params["step"])
+	if err != nil {
+		return 0, nil, err
+	}
+
+	j := toStepJSON(step)
+	j.Meta = nil // decoding would merge the meta of the request into the existing one
+	if err = decode(r, &j); err != nil {
+		return 0, nil, err
+	}
+	if j.Meta == nil {
+		j.Meta = step.Meta
+	}
+	if j.WorkflowID != step.WorkflowID {
+		return 0, nil, badRequest("steps can't be moved to another workflow")
+	}
+
+	j.apply(&step)
+	err = a.transact(func(repositories Repositories) error {
+		return a.saveStep(repositories, &step)
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return netHttp.StatusOK, toStepJSON(step), nil
+}
+
+func (a *api) deleteStep(r *netHttp.Request, params map[string]string) (int, interface{}, error) {
+	step, err := a.findStep(params["step"])
+	if err != nil {
+		return 0, nil, err
+	}
+
+	err = a.transact(func(repositories Repositories) error {
+		return repositories.WorkflowSteps.Remove(step.ID)
+	})
+
+	return netHttp.StatusNoContent, nil, err
+}
+
+func (a *api) findStep(key string) (step wfs.WorkflowStep, err error) {
+	ID, err := strconv.ParseUint(key, 10, 64)
+	if err != nil {
+		return step, notFound("step %s not found", key)
+	}
+
+	step, err = a.workflowStepRepository.FindByID(ID)
+	if errors.Is(err, db.ErrNoMoreRows) {
+		err = notFound("step %s not found", key)
+	}
+
+	return
+}
+
+// saveStep validates and saves a step along with its meta, the engine reads the steps of a workflow whenever it runs
+// it.
+func (a *api) saveStep(repositories Repositories, step *wfs.WorkflowStep) error {
+	if err := checkPolicy(step.OnError, step.RetryBackoff, step.Timeout); err != nil {
+		return err
+	}
+
+	if step.Condition != "" {
+		if _, err := condition.Parse(step.Condition); err != nil {
+			return badRequest("invalid if: %s", err)
+		}
+	}
+
+	schema, ok := a.stepSchemas[step.Variety]
+	if !ok {
+		return badRequest("unknown variety %q", step.Variety)
+	}
+	if err := schema.Validate(step.Meta); err != nil {
+		return badRequest("invalid meta: %s", err)
+	}
+
+	return repositories.WorkflowSteps.Save(step)
+}
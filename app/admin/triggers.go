@@ -0,0 +1,166 @@
+package admin
+
+import (
+	"errors"
+	netHttp "net/http"
+	t "neurobot/model/trigger"
+	"strconv"
+
+	"github.com/upper/db/v4"
+)
+
+type triggerJSON struct {
+	ID         uint64            `json:"id"`
+	WorkflowID uint64            `json:"workflowId"`
+	Variety    string            `json:"variety"`
+	Meta       map[string]string `json:"meta"`
+}
+
+func toTriggerJSON(trigger t.Trigger) triggerJSON {
+	meta := trigger.Meta
+	if meta == nil {
+		meta = make(map[string]string)
+	}
+
+	return triggerJSON{
+		ID:         trigger.ID,
+		WorkflowID: trigger.WorkflowID,
+		Variety:    trigger.Variety,
+		Meta:       meta,
+	}
+}
+
+func (j triggerJSON) apply(trigger *t.Trigger) {
+	trigger.Variety = j.Variety
+	trigger.Meta = j.Meta
+}
+
+func (a *api) listTriggers(r *netHttp.Request, params map[string]string) (int, interface{}, error) {
+	workflow, err := findWorkflow(a.workflowRepository, params["workflow"])
+	if err != nil {
+		return 0, nil, err
+	}
+
+	triggers, err := a.triggerRepository.FindByWorkflowID(workflow.ID)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	result := make([]triggerJSON, 0, len(triggers))
+	for _, trigger := range triggers {
+		result = append(result, toTriggerJSON(trigger))
+	}
+
+	return netHttp.StatusOK, result, nil
+}
+
+func (a *api) getTrigger(r *netHttp.Request, params map[string]string) (int, interface{}, error) {
+	trigger, err := a.findTrigger(params["trigger"])
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return netHttp.StatusOK, toTriggerJSON(trigger), nil
+}
+
+// createTrigger adds a trigger to a workflow, which starts it once the triggers are reloaded.
+func (a *api) createTrigger(r *netHttp.Request, params map[string]string) (int, interface{}, error) {
+	workflow, err := findWorkflow(a.workflowRepository, params["workflow"])
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var j triggerJSON
+	if err = decode(r, &j); err != nil {
+		return 0, nil, err
+	}
+
+	var trigger t.Trigger
+	err = a.transact(func(repositories Repositories) error {
+		trigger = t.Trigger{WorkflowID: workflow.ID}
+		j.apply(&trigger)
+		return a.saveTrigger(repositories, &trigger)
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return netHttp.StatusCreated, toTriggerJSON(trigger), nil
+}
+
+// updateTrigger updates a trigger, its meta is replaced when the request has one.
+func (a *api) updateTrigger(r *netHttp.Request, params map[string]string) (int, interface{}, error) {
+	trigger, err := a.findTrigger(params["trigger"])
+	if err != nil {
+		return 0, nil, err
+	}
+
+	j := toTriggerJSON(trigger)
+	j.Meta = nil // decoding would merge the meta of the request into the existing one
+	if err = decode(r, &j); err != nil {
+		return 0, nil, err
+	}
+	if j.Meta == nil {
+		j.Meta = trigger.Meta
+	}
+	if j.WorkflowID != trigger.WorkflowID {
+		return 0, nil, badRequest("triggers can't be moved to another workflow")
+	}
+
+	j.apply(&trigger)
+	err = a.transact(func(repositories Repositories) error {
+		return a.saveTrigger(repositories, &trigger)
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return netHttp.StatusOK, toTriggerJSON(trigger), nil
+}
+
+func (a *api) deleteTrigger(r *netHttp.Request, params map[string]string) (int, interface{}, error) {
+	trigger, err := a.findTrigger(params["trigger"])
+	if err != nil {
+		return 0, nil, err
+	}
+
+	err = a.transact(func(repositories Repositories) error {
+		return repositories.Triggers.Remove(trigger.ID)
+	})
+
+	return netHttp.StatusNoContent, nil, err
+}
+
+func (a *api) findTrigger(key string) (trigger t.Trigger, err error) {
+	ID, err := strconv.ParseUint(key, 10, 64)
+	if err != nil {
+		return trigger, notFound("trigger %s not found", key)
+	}
+
+	trigger, err = a.triggerRepository.FindByID(ID)
+	if errors.Is(err, db.ErrNoMoreRows) {
+		err = notFound("trigger %s not found", key)
+	}
+
+	return
+}
+
+// saveTrigger validates and saves a trigger, checking its meta the way its variety does, the triggers listen for it
+// once the transaction it is saved within succeeded.
+func (a *api) saveTrigger(repositories Repositories, trigger *t.Trigger) error {
+	check, ok := a.triggerChecks[trigger.Variety]
+	if !ok {
+		return badRequest("unknown variety %q", trigger.Variety)
+	}
+
+	workflow, err := repositories.Workflows.FindByID(trigger.WorkflowID)
+	if err != nil {
+		return err
+	}
+
+	if err = check(workflow, *trigger); err != nil {
+		return badRequest("invalid meta: %s", err)
+	}
+
+	return repositories.Triggers.Save(trigger)
+}
@@ -0,0 +1,258 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	netHttp "net/http"
+	"neurobot/infrastructure/event"
+	w "neurobot/model/workflow"
+	"strconv"
+	"time"
+
+	"github.com/upper/db/v4"
+)
+
+// ManualVariety is the variety of the trigger of workflows started through the admin API.
+const ManualVariety = "manual"
+
+type workflowJSON struct {
+	ID           uint64 `json:"id"`
+	Identifier   string `json:"identifier"`
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	Active       bool   `json:"active"`
+	OnError      string `json:"onError"`
+	MaxAttempts  uint64 `json:"maxAttempts"`
+	RetryBackoff string `json:"retryBackoff"`
+	Timeout      string `json:"timeout"`
+}
+
+func toWorkflowJSON(workflow w.Workflow) workflowJSON {
+	return workflowJSON{
+		ID:           workflow.ID,
+		Identifier:   workflow.Identifier,
+		Name:         workflow.Name,
+		Description:  workflow.Description,
+		Active:       workflow.Active,
+		OnError:      workflow.OnError,
+		MaxAttempts:  workflow.MaxAttempts,
+		RetryBackoff: workflow.RetryBackoff,
+		Timeout:      workflow.Timeout,
+	}
+}
+
+func (j workflowJSON) apply(workflow *w.Workflow) {
+	workflow.Identifier = j.Identifier
+	workflow.Name = j.Name
+	workflow.Description = j.Description
+	workflow.Active = j.Active
+	workflow.OnError = j.OnError
+	workflow.MaxAttempts = j.MaxAttempts
+	workflow.RetryBackoff = j.RetryBackoff
+	workflow.Timeout = j.Timeout
+}
+
+func (a *api) listWorkflows(r *netHttp.Request, params map[string]string) (int, interface{}, error) {
+	workflows, err := a.workflowRepository.FindAll()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	result := make([]workflowJSON, 0, len(workflows))
+	for _, workflow := range workflows {
+		result = append(result, toWorkflowJSON(workflow))
+	}
+
+	return netHttp.StatusOK, result, nil
+}
+
+func (a *api) getWorkflow(r *netHttp.Request, params map[string]string) (int, interface{}, error) {
+	workflow, err := findWorkflow(a.workflowRepository, params["workflow"])
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return netHttp.StatusOK, toWorkflowJSON(workflow), nil
+}
+
+func (a *api) createWorkflow(r *netHttp.Request, params map[string]string) (int, interface{}, error) {
+	j := workflowJSON{Active: true}
+	if err := decode(r, &j); err != nil {
+		return 0, nil, err
+	}
+
+	var workflow w.Workflow
+	err := a.transact(func(repositories Repositories) error {
+		workflow = w.Workflow{}
+		j.apply(&workflow)
+		return saveWorkflow(repositories.Workflows, &workflow)
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return netHttp.StatusCreated, toWorkflowJSON(workflow), nil
+}
+
+func (a *api) updateWorkflow(r *netHttp.Request, params map[string]string) (int, interface{}, error) {
+	workflow, err := findWorkflow(a.workflowRepository, params["workflow"])
+	if err != nil {
+		return 0, nil, err
+	}
+
+	j := toWorkflowJSON(workflow)
+	if err = decode(r, &j); err != nil {
+		return 0, nil, err
+	}
+
+	j.apply(&workflow)
+	err = a.transact(func(repositories Repositories) error {
+		return saveWorkflow(repositories.Workflows, &workflow)
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return netHttp.StatusOK, toWorkflowJSON(workflow), nil
+}
+
+// deleteWorkflow removes a workflow along with its steps and triggers, its runs are kept in the history.
+func (a *api) deleteWorkflow(r *netHttp.Request, params map[string]string) (int, interface{}, error) {
+	workflow, err := findWorkflow(a.workflowRepository, params["workflow"])
+	if err != nil {
+		return 0, nil, err
+	}
+
+	err = a.transact(func(repositories Repositories) error {
+		if err := repositories.WorkflowSteps.RemoveByWorkflowID(workflow.ID); err != nil {
+			return err
+		}
+		if err := repositories.Triggers.RemoveByWorkflowID(workflow.ID); err != nil {
+			return err
+		}
+
+		return repositories.Workflows.Remove(workflow.ID)
+	})
+
+	return netHttp.StatusNoContent, nil, err
+}
+
+func (a *api) enableWorkflow(active bool) func(*netHttp.Request, map[string]string) (int, interface{}, error) {
+	return func(r *netHttp.Request, params map[string]string) (int, interface{}, error) {
+		workflow, err := findWorkflow(a.workflowRepository, params["workflow"])
+		if err != nil {
+			return 0, nil, err
+		}
+
+		workflow.Active = active
+		err = a.transact(func(repositories Repositories) error {
+			return saveWorkflow(repositories.Workflows, &workflow)
+		})
+		if err != nil {
+			return 0, nil, err
+		}
+
+		return netHttp.StatusOK, toWorkflowJSON(workflow), nil
+	}
+}
+
+// triggerWorkflow starts a workflow with the JSON body of the request as payload, and responds with the ID of its
+// run once queued.
+func (a *api) triggerWorkflow(r *netHttp.Request, params map[string]string) (int, interface{}, error) {
+	workflow, err := findWorkflow(a.workflowRepository, params["workflow"])
+	if err != nil {
+		return 0, nil, err
+	}
+	if !workflow.Active {
+		return 0, nil, conflict("workflow %s is disabled", workflow.Identifier)
+	}
+
+	p, err := decodePayload(r)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	queued := make(chan uint64, 1)
+	e := event.NewTrigger(workflow.Identifier, ManualVariety, p)
+	e.Queued = queued
+	a.eventBus.Publish(event.TriggerTopic(), e)
+
+	var runID uint64
+	select {
+	case runID = <-queued:
+	case <-r.Context().Done():
+	}
+	if runID == 0 {
+		return 0, nil, fmt.Errorf("failed to queue workflow %s", workflow.Identifier)
+	}
+
+	return netHttp.StatusAccepted, map[string]uint64{"run": runID}, nil
+}
+
+// findWorkflow finds a workflow by its ID, or by its identifier.
+func findWorkflow(workflowRepository w.Repository, key string) (workflow w.Workflow, err error) {
+	if ID, parseErr := strconv.ParseUint(key, 10, 64); parseErr == nil {
+		workflow, err = workflowRepository.FindByID(ID)
+	} else {
+		workflow, err = workflowRepository.FindByIdentifier(key)
+	}
+
+	if errors.Is(err, db.ErrNoMoreRows) {
+		err = notFound("workflow %s not found", key)
+	}
+
+	return
+}
+
+// saveWorkflow validates and saves a workflow, the triggers know about it once the transaction it is saved within
+// succeeded.
+func saveWorkflow(workflowRepository w.Repository, workflow *w.Workflow) error {
+	if err := checkWorkflow(*workflow); err != nil {
+		return err
+	}
+
+	existing, err := workflowRepository.FindByIdentifier(workflow.Identifier)
+	switch {
+	case err == nil && existing.ID != workflow.ID:
+		return conflict("workflow %s already exists", workflow.Identifier)
+	case err != nil && !errors.Is(err, db.ErrNoMoreRows):
+		return err
+	}
+
+	return workflowRepository.Save(workflow)
+}
+
+func checkWorkflow(workflow w.Workflow) error {
+	if workflow.Identifier == "" {
+		return badRequest("identifier must not be empty")
+	}
+	if _, err := strconv.ParseUint(workflow.Identifier, 10, 64); err == nil {
+		return badRequest("identifier must not be a number, got %q", workflow.Identifier)
+	}
+
+	return checkPolicy(workflow.OnError, workflow.RetryBackoff, workflow.Timeout)
+}
+
+// checkPolicy makes sure the error policy and timeout of a workflow or a step are valid, empty values being inherited
+// or defaults.
+func checkPolicy(onError string, retryBackoff string, timeout string) error {
+	switch onError {
+	case "", "continue", "halt", "retry":
+	default:
+		return badRequest("onError must be continue, halt or retry, got %q", onError)
+	}
+
+	if retryBackoff != "" {
+		if _, err := time.ParseDuration(retryBackoff); err != nil {
+			return badRequest("invalid retryBackoff: %s", err)
+		}
+	}
+
+	if timeout != "" {
+		if d, err := time.ParseDuration(timeout); err != nil || d <= 0 {
+			return badRequest("timeout must be a positive duration, got %q", timeout)
+		}
+	}
+
+	return nil
+}
@@ -1,83 +1,273 @@
 package app
 
 import (
-	"fmt"
-	"log"
-	netHttp "net/http"
+	"context"
+	"errors"
 	"neurobot/app/bot"
 	"neurobot/app/engine"
+	"neurobot/app/queue"
 	r "neurobot/app/runner"
-	"neurobot/app/runner/afk_notifier"
+	"neurobot/app/trigger"
+	"neurobot/infrastructure/event"
 	"neurobot/infrastructure/http"
+	"neurobot/model/run"
+	t "neurobot/model/trigger"
 	w "neurobot/model/workflow"
-	"strings"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
 )
 
 type app struct {
-	engine             engine.Engine
-	botRegistry        bot.Registry
-	workflowRepository w.Repository
-	webhookListener    *http.Server
+	engine                 engine.Engine
+	botRegistry            bot.Registry
+	workflowRepository     w.Repository
+	triggerRepository      t.Repository
+	triggerStateRepository t.StateRepository
+	runRepository          run.Repository
+	webhookServer          *http.Server
+	eventBus               event.Bus
+	queue                  queue.Queue
+	webhookListener        trigger.WebhookListener
+	listeners              []trigger.Listener // every listener, including the webhook listener
+	reloadMutex            sync.Mutex
+	stopped                bool                // whether the listeners were stopped for good, they can't be reloaded then
+	runners                map[string]r.Runner // by workflow identifier, for workflows not run by the engine
+	waitersMutex           sync.Mutex
+	waiters                map[uint64]chan<- run.Run // by run ID, for the triggers waiting for their run to finish
 }
 
+// Transaction runs fn with repositories bound to a database transaction, which is committed when fn succeeds.
+type Transaction func(fn func(triggerRepository t.Repository, workflowRepository w.Repository) error) error
+
 func NewApp(
 	engine engine.Engine,
 	botRegistry bot.Registry,
 	workflowRepository w.Repository,
-	webhookListener *http.Server,
+	triggerRepository t.Repository,
+	triggerStateRepository t.StateRepository,
+	runRepository run.Repository,
+	webhookServer *http.Server,
+	eventBus event.Bus,
+	workers int,
 ) *app {
-	return &app{
-		engine:             engine,
-		botRegistry:        botRegistry,
-		workflowRepository: workflowRepository,
-		webhookListener:    webhookListener,
+	app := &app{
+		engine:                 engine,
+		botRegistry:            botRegistry,
+		workflowRepository:     workflowRepository,
+		triggerRepository:      triggerRepository,
+		triggerStateRepository: triggerStateRepository,
+		runRepository:          runRepository,
+		webhookServer:          webhookServer,
+		eventBus:               eventBus,
+		runners:                make(map[string]r.Runner),
+		waiters:                make(map[uint64]chan<- run.Run),
 	}
+	app.queue = queue.NewQueue(runRepository, workers, app.run)
+
+	return app
 }
 
-func (app app) Run() (err error) {
-	err = app.webhookListener.RegisterRoute(
-		"/",
-		func(response netHttp.ResponseWriter, request *netHttp.Request, payload map[string]string) {
-			workflowIdentifier := strings.TrimPrefix(request.URL.Path, "/")
-			workflow, err := app.workflowRepository.FindByIdentifier(workflowIdentifier)
-			if err != nil {
-				errorMessage := fmt.Sprintf("no workflow found for `%s`", workflowIdentifier)
-				netHttp.Error(response, errorMessage, netHttp.StatusNotFound)
-				return
-			}
+// RegisterRunner makes a workflow run by a custom runner instead of the engine.
+func (app *app) RegisterRunner(workflowIdentifier string, runner r.Runner) {
+	app.runners[workflowIdentifier] = runner
+}
+
+func (app *app) Run() (err error) {
+	if err = app.queue.Start(); err != nil {
+		return
+	}
+
+	app.eventBus.Subscribe(event.TriggerTopic(), app.dispatch)
+
+	app.webhookListener = trigger.NewWebhookListener(app.triggerRepository, app.workflowRepository, app.eventBus)
+	app.listeners = []trigger.Listener{
+		app.webhookListener,
+		trigger.NewScheduler(app.triggerRepository, app.triggerStateRepository, app.workflowRepository, app.eventBus),
+		trigger.NewPollListener(app.triggerRepository, app.triggerStateRepository, app.workflowRepository, app.eventBus),
+		trigger.NewFeedListener(app.triggerRepository, app.triggerStateRepository, app.workflowRepository, app.eventBus),
+		trigger.NewCommandListener(app.triggerRepository, app.workflowRepository, app.botRegistry, app.eventBus),
+		trigger.NewReactionListener(app.triggerRepository, app.workflowRepository, app.botRegistry, app.eventBus),
+	}
+
+	err = app.webhookServer.RegisterRoute("/", app.webhookListener.Handle)
+	if err != nil {
+		return
+	}
+
+	return app.Reload()
+}
 
-			err = app.runWorkflow(workflow, payload)
+// Reload makes the triggers take the workflows and triggers as they are now in the database into account. Workflows
+// which were disabled are never started, whether triggers were reloaded or not.
+func (app *app) Reload() error {
+	return app.ReloadWithin(func(fn func(t.Repository, w.Repository) error) error {
+		return fn(app.triggerRepository, app.workflowRepository)
+	})
+}
+
+// ReloadWithin makes the triggers take the workflows and triggers read from the repositories the transaction is run
+// with into account. Every listener loads its triggers within the transaction, they are only swapped for the ones
+// listened for until then once all of them loaded and the transaction succeeded: when anything fails, the triggers
+// stay as they were and the error is returned. Runs in progress finish with the steps they started with.
+func (app *app) ReloadWithin(transaction Transaction) error {
+	app.reloadMutex.Lock()
+	defer app.reloadMutex.Unlock()
+
+	if app.stopped {
+		return errors.New("app is stopped")
+	}
+
+	var swaps []func()
+	err := transaction(func(triggerRepository t.Repository, workflowRepository w.Repository) error {
+		swaps = nil // the transaction may be retried
+		for _, listener := range app.listeners {
+			swap, err := listener.Load(triggerRepository, workflowRepository)
 			if err != nil {
-				netHttp.Error(response, "something went wrong", netHttp.StatusInternalServerError)
-				log.Printf("Error when attempting to run workflow: %s, payload: %+v", err, payload)
-				return
+				return err
 			}
-		})
 
-	return
+			swaps = append(swaps, swap)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, swap := range swaps {
+		swap()
+	}
+
+	return nil
 }
 
-func (app app) runWorkflow(workflow w.Workflow, payload map[string]string) error {
-	var runner r.Runner
+// Stop stops listening for triggers, then waits for the workflow runs in progress to finish until ctx is done. The runs
+// still in progress then are cancelled, they will be run again when the app starts again, along with the ones which
+// are queued.
+func (app *app) Stop(ctx context.Context) {
+	app.reloadMutex.Lock()
+	for _, listener := range app.listeners {
+		listener.Stop()
+	}
+	app.stopped = true
+	app.reloadMutex.Unlock()
 
-	switch workflow.Identifier {
-	default:
-		runner = app.engine
-	case "afk_notifier":
-		matrixClient, err := app.botRegistry.GetClient("afk")
-		if err != nil {
-			return err
-		}
-		runner = afk_notifier.NewRunner(matrixClient)
+	app.queue.Stop(ctx)
+}
+
+// dispatch starts the workflow of a trigger event, it is the single path through which all workflows are started.
+// The trigger is told about the run, when it asked for it.
+func (app *app) dispatch(e interface{}) {
+	trigger, ok := e.(event.Trigger)
+	if !ok {
+		log.Errorf("Unexpected event on trigger topic: %+v", e)
+		return
 	}
 
-	go func() {
-		log.Printf("Starting workflow with identifier %s, payload: %+v", workflow.Identifier, payload)
-		err := runner.Run(workflow, payload)
-		if err != nil {
-			log.Printf("Failed to run workflow: %s", err)
+	logger := log.WithFields(log.Fields{
+		"workflow": trigger.WorkflowIdentifier,
+		"trigger":  trigger.Variety,
+	})
+
+	var record run.Run
+	defer func() {
+		if trigger.Queued != nil {
+			trigger.Queued <- record.ID
 		}
 	}()
 
-	return nil
+	workflow, err := app.workflowRepository.FindByIdentifier(trigger.WorkflowIdentifier)
+	if err != nil {
+		logger.WithError(err).Error("Failed to find workflow to run")
+		return
+	}
+
+	if !workflow.Active {
+		logger.Info("Not running inactive workflow")
+		return
+	}
+
+	// Runs are queued as of when their trigger was received, which is when the ones waiting the longest started waiting
+	record = run.Run{
+		WorkflowID: workflow.ID,
+		Trigger:    trigger.Variety,
+		Payload:    trigger.Payload,
+		StartedAt:  trigger.ReceivedAt,
+	}
+	if record.StartedAt.IsZero() {
+		record.StartedAt = time.Now()
+	}
+
+	// The waiter is known before the run can finish, as finishing waits for the lock
+	app.waitersMutex.Lock()
+	defer app.waitersMutex.Unlock()
+
+	if err = app.queue.Enqueue(&record); err != nil {
+		logger.WithError(err).Error("Failed to queue workflow run")
+		record.ID = 0
+		return
+	}
+
+	if trigger.Finished != nil {
+		app.waiters[record.ID] = trigger.Finished
+	}
+
+	logger.WithFields(log.Fields{"run": record.ID}).Debug("Queued workflow run")
+}
+
+// run runs a workflow for a run claimed from the queue, keeping track of how it went in the history.
+// A run interrupted because the queue is stopping is queued again, to be run when the app starts again.
+func (app *app) run(ctx context.Context, record run.Run) {
+	logger := log.WithFields(log.Fields{
+		"trigger": record.Trigger,
+		"run":     record.ID,
+		"attempt": record.Attempts,
+	})
+
+	workflow, err := app.workflowRepository.FindByID(record.WorkflowID)
+	if err == nil {
+		logger = logger.WithFields(log.Fields{"workflow": workflow.Identifier})
+		logger.WithFields(log.Fields{"payload": record.Payload}).Info("Starting workflow")
+
+		if runner, ok := app.runners[workflow.Identifier]; ok {
+			record.Output, err = runner.Run(ctx, workflow, record.Payload)
+		} else {
+			record.Output, err = app.engine.RunRecorded(ctx, workflow, record.Payload, record.ID)
+		}
+	}
+
+	record.Status = run.StatusSucceeded
+	record.FinishedAt = time.Now()
+	switch {
+	case err != nil && ctx.Err() != nil:
+		logger.WithError(err).Warn("Workflow run interrupted, it will be run again")
+		record.Status = run.StatusQueued
+		record.FinishedAt = time.Time{}
+	case err != nil:
+		logger.WithError(err).Error("Failed to run workflow")
+		record.Status = run.StatusFailed
+		record.Error = err.Error()
+	}
+
+	if err = app.runRepository.Save(&record); err != nil {
+		logger.WithError(err).Error("Failed to record workflow run in history")
+	}
+
+	// A run queued again is told about too, as it won't finish before the app starts again, when nobody waits for it
+	app.notifyWaiter(record)
+}
+
+// notifyWaiter tells the trigger waiting for a run, if any, that it finished or was interrupted.
+func (app *app) notifyWaiter(record run.Run) {
+	app.waitersMutex.Lock()
+	waiter, ok := app.waiters[record.ID]
+	delete(app.waiters, record.ID)
+	app.waitersMutex.Unlock()
+
+	if ok {
+		waiter <- record
+	}
 }
@@ -0,0 +1,309 @@
+package app
+
+import (
+	"context"
+	"errors"
+	runApp "neurobot/app/run"
+	"neurobot/app/trigger"
+	"neurobot/app/workflow"
+	"neurobot/infrastructure/event"
+	"neurobot/model/payload"
+	"neurobot/model/run"
+	trm "neurobot/model/trigger"
+	w "neurobot/model/workflow"
+	"neurobot/resources/tests/database"
+	"neurobot/resources/tests/fixtures"
+	"testing"
+	"time"
+
+	"github.com/upper/db/v4"
+)
+
+type runnerMock struct {
+	runs chan payload.Payload
+}
+
+func (runner runnerMock) Run(ctx context.Context, workflow w.Workflow, p payload.Payload) (payload.Payload, error) {
+	runner.runs <- p
+
+	output := p.Clone()
+	output.Set("runner.workflow", workflow.Identifier)
+	return output, nil
+}
+
+func (runner runnerMock) RunRecorded(ctx context.Context, workflow w.Workflow, p payload.Payload, runID uint64) (payload.Payload, error) {
+	return runner.Run(ctx, workflow, p)
+}
+
+func TestDispatch(t *testing.T) {
+	database.Test(func(session db.Session) {
+		fixtures.Workflows(session)
+
+		engine := runnerMock{runs: make(chan payload.Payload, 1)}
+		custom := runnerMock{runs: make(chan payload.Payload, 1)}
+
+		runRepository := runApp.NewRepository(session)
+		app := NewApp(engine, nil, workflow.NewRepository(session), nil, nil, runRepository, nil, event.NewMemoryBus(), 2)
+		app.RegisterRunner("MVP", custom)
+		app.eventBus.Subscribe(event.TriggerTopic(), app.dispatch)
+		if err := app.queue.Start(); err != nil {
+			t.Fatalf("failed to start queue: %s", err)
+		}
+		defer app.queue.Stop(context.Background())
+
+		tables := []struct {
+			workflowIdentifier string
+			expectedRunner     *runnerMock
+		}{
+			{workflowIdentifier: "QUICKSTART", expectedRunner: &engine},
+			{workflowIdentifier: "MVP", expectedRunner: &custom},
+			{workflowIdentifier: "DEACTIVATED"},
+			{workflowIdentifier: "UNKNOWN"},
+		}
+
+		for _, table := range tables {
+			p := payload.Payload{"message": table.workflowIdentifier}
+			app.eventBus.Publish(event.TriggerTopic(), event.NewTrigger(table.workflowIdentifier, trigger.WebhookVariety, p))
+
+			if table.expectedRunner == nil {
+				continue
+			}
+
+			select {
+			case got := <-table.expectedRunner.runs:
+				if got["message"] != table.workflowIdentifier {
+					t.Errorf("workflow %s was run with unexpected payload %+v", table.workflowIdentifier, got)
+				}
+			case <-time.After(time.Second):
+				t.Errorf("workflow %s was not run by the expected runner", table.workflowIdentifier)
+			}
+		}
+
+		// Both runs are recorded in the history, once finished.
+		for _, workflowID := range []uint64{1, 11} {
+			deadline := time.Now().Add(time.Second)
+			for {
+				runs, err := runRepository.FindByWorkflowID(workflowID, time.Time{})
+				if err == nil && len(runs) == 1 && runs[0].Status == run.StatusSucceeded && runs[0].Trigger == trigger.WebhookVariety {
+					break
+				}
+
+				if time.Now().After(deadline) {
+					t.Errorf("run of workflow %d was not recorded, got %+v (%v)", workflowID, runs, err)
+					break
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		}
+
+		select {
+		case got := <-engine.runs:
+			t.Errorf("unexpected run with payload %+v", got)
+		case got := <-custom.runs:
+			t.Errorf("unexpected run with payload %+v", got)
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+}
+
+func TestDispatchReplies(t *testing.T) {
+	database.Test(func(session db.Session) {
+		fixtures.Workflows(session)
+
+		engine := runnerMock{runs: make(chan payload.Payload, 1)}
+		app := NewApp(engine, nil, workflow.NewRepository(session), nil, nil, runApp.NewRepository(session), nil, event.NewMemoryBus(), 1)
+		app.eventBus.Subscribe(event.TriggerTopic(), app.dispatch)
+		if err := app.queue.Start(); err != nil {
+			t.Fatalf("failed to start queue: %s", err)
+		}
+		defer app.queue.Stop(context.Background())
+
+		queued := make(chan uint64, 1)
+		finished := make(chan run.Run, 1)
+		e := event.NewTrigger("QUICKSTART", trigger.WebhookVariety, payload.Payload{"message": "hello"})
+		e.Queued, e.Finished = queued, finished
+		app.eventBus.Publish(event.TriggerTopic(), e)
+
+		var runID uint64
+		select {
+		case runID = <-queued:
+			if runID == 0 {
+				t.Fatalf("expected the run to be queued")
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected to be told the run was queued")
+		}
+
+		select {
+		case r := <-finished:
+			if r.ID != runID || r.Status != run.StatusSucceeded || r.Output.String("runner.workflow") != "QUICKSTART" {
+				t.Errorf("unexpected finished run %+v", r)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("expected to be told the run finished")
+		}
+
+		e = event.NewTrigger("DEACTIVATED", trigger.WebhookVariety, payload.Payload{})
+		e.Queued = queued
+		app.eventBus.Publish(event.TriggerTopic(), e)
+		if runID = <-queued; runID != 0 {
+			t.Errorf("expected no run of an inactive workflow, got %d", runID)
+		}
+	})
+}
+
+// interruptedRunner runs until it is interrupted.
+type interruptedRunner struct {
+	started chan struct{}
+}
+
+func (runner interruptedRunner) Run(ctx context.Context, workflow w.Workflow, p payload.Payload) (payload.Payload, error) {
+	runner.started <- struct{}{}
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (runner interruptedRunner) RunRecorded(ctx context.Context, workflow w.Workflow, p payload.Payload, runID uint64) (payload.Payload, error) {
+	return runner.Run(ctx, workflow, p)
+}
+
+func TestDispatchInterrupted(t *testing.T) {
+	database.Test(func(session db.Session) {
+		fixtures.Workflows(session)
+
+		runner := interruptedRunner{started: make(chan struct{}, 1)}
+		app := NewApp(runner, nil, workflow.NewRepository(session), nil, nil, runApp.NewRepository(session), nil, event.NewMemoryBus(), 1)
+		app.eventBus.Subscribe(event.TriggerTopic(), app.dispatch)
+		if err := app.queue.Start(); err != nil {
+			t.Fatalf("failed to start queue: %s", err)
+		}
+
+		queued := make(chan uint64, 1)
+		finished := make(chan run.Run, 1)
+		e := event.NewTrigger("QUICKSTART", trigger.WebhookVariety, payload.Payload{})
+		e.Queued, e.Finished = queued, finished
+		app.eventBus.Publish(event.TriggerTopic(), e)
+
+		runID := <-queued
+		select {
+		case <-runner.started:
+		case <-time.After(time.Second):
+			t.Fatalf("expected the run to start")
+		}
+
+		// Stopping right away interrupts the run
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		app.queue.Stop(ctx)
+
+		select {
+		case r := <-finished:
+			if r.ID != runID || r.Status != run.StatusQueued {
+				t.Errorf("expected to be told the run was queued again, got %+v", r)
+			}
+		default:
+			t.Errorf("expected to be told the run was interrupted")
+		}
+
+		app.waitersMutex.Lock()
+		defer app.waitersMutex.Unlock()
+		if len(app.waiters) != 0 {
+			t.Errorf("expected no waiter left, got %d", len(app.waiters))
+		}
+	})
+}
+
+func TestDispatchReceivedAt(t *testing.T) {
+	database.Test(func(session db.Session) {
+		fixtures.Workflows(session)
+
+		// The queue isn't started, so that the run stays queued
+		runRepository := runApp.NewRepository(session)
+		app := NewApp(runnerMock{}, nil, workflow.NewRepository(session), nil, nil, runRepository, nil, event.NewMemoryBus(), 1)
+		app.eventBus.Subscribe(event.TriggerTopic(), app.dispatch)
+
+		queued := make(chan uint64, 1)
+		e := event.NewTrigger("QUICKSTART", trigger.ScheduleVariety, payload.Payload{})
+		e.ReceivedAt = time.Now().Add(-time.Hour).Truncate(time.Second)
+		e.Queued = queued
+		app.eventBus.Publish(event.TriggerTopic(), e)
+
+		r, err := runRepository.FindByID(<-queued)
+		if err != nil {
+			t.Fatalf("failed to find queued run: %s", err)
+		}
+		if !r.StartedAt.Equal(e.ReceivedAt) {
+			t.Errorf("expected the run to be queued as of %s, got %s", e.ReceivedAt, r.StartedAt)
+		}
+	})
+}
+
+type listenerMock struct {
+	err     error
+	loads   int
+	swapped int
+	stopped bool
+}
+
+func (l *listenerMock) Load(triggerRepository trm.Repository, workflowRepository w.Repository) (func(), error) {
+	l.loads++
+	if l.err != nil {
+		return nil, l.err
+	}
+
+	return func() { l.swapped++ }, nil
+}
+
+func (l *listenerMock) Start() error {
+	return nil
+}
+
+func (l *listenerMock) Stop() {
+	l.stopped = true
+}
+
+func TestReloadWithin(t *testing.T) {
+	first, second := &listenerMock{}, &listenerMock{}
+	app := NewApp(nil, nil, nil, nil, nil, nil, nil, event.NewMemoryBus(), 1)
+	app.listeners = []trigger.Listener{first, second}
+
+	commit := func(fn func(trm.Repository, w.Repository) error) error {
+		return fn(nil, nil)
+	}
+	rollback := func(fn func(trm.Repository, w.Repository) error) error {
+		if err := fn(nil, nil); err != nil {
+			return err
+		}
+		return errors.New("failed to commit")
+	}
+
+	if err := app.ReloadWithin(commit); err != nil {
+		t.Fatalf("failed to reload: %s", err)
+	}
+	if first.swapped != 1 || second.swapped != 1 {
+		t.Errorf("expected both listeners to be swapped once, got %d and %d", first.swapped, second.swapped)
+	}
+
+	// Nothing is swapped when the transaction fails, or when any listener fails to load
+	if err := app.ReloadWithin(rollback); err == nil {
+		t.Errorf("expected reloading within a failed transaction to fail")
+	}
+
+	second.err = errors.New("invalid trigger")
+	if err := app.ReloadWithin(commit); err == nil {
+		t.Errorf("expected reloading an invalid trigger to fail")
+	}
+	if first.loads != 3 || first.swapped != 1 || second.swapped != 1 {
+		t.Errorf("expected listeners not to be swapped when reloading failed, got %d and %d", first.swapped, second.swapped)
+	}
+
+	app.Stop(context.Background())
+	if !first.stopped || !second.stopped {
+		t.Errorf("expected listeners to be stopped")
+	}
+
+	second.err = nil
+	if err := app.ReloadWithin(commit); err == nil || first.swapped != 1 {
+		t.Errorf("expected a stopped app not to reload")
+	}
+}
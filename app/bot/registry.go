@@ -1,24 +1,34 @@
 package bot
 
 import (
+	"context"
 	"fmt"
 	"neurobot/infrastructure/matrix"
 	model "neurobot/model/bot"
 	"neurobot/model/message"
 	"neurobot/model/room"
+	"sync"
 
 	"github.com/apex/log"
 )
 
 type Registry interface {
-	Append(bot model.Bot, client matrix.Client) error
+	// Append logs a bot in and makes its client available, the context only applies to logging in.
+	Append(ctx context.Context, bot model.Bot, client matrix.Client) error
 	GetPrimaryClient() (matrix.Client, error)
 	GetClient(identifier string) (matrix.Client, error)
+
+	// Remove stops a bot from syncing with the homeserver and forgets its client, so that it can be appended again.
+	Remove(username string)
+
+	// Stop stops all the bots from syncing with the homeserver.
+	Stop()
 }
 
 type registry struct {
 	serverName      string
 	primaryUsername string
+	mutex           sync.RWMutex // bots can be added and removed while running
 	clients         map[string]matrix.Client
 }
 
@@ -29,18 +39,17 @@ func NewRegistry(serverName string) Registry {
 	}
 }
 
-func (r *registry) Append(bot model.Bot, client matrix.Client) (err error) {
+func (r *registry) Append(ctx context.Context, bot model.Bot, client matrix.Client) (err error) {
 	log.WithFields(log.Fields{"bot": bot.Username}).Info("adding bot to registry")
 
-	if bot.IsPrimary() {
-		r.primaryUsername = bot.Username
-	}
-
-	if _, ok := r.clients[bot.Username]; ok {
+	r.mutex.RLock()
+	_, known := r.clients[bot.Username]
+	r.mutex.RUnlock()
+	if known {
 		return fmt.Errorf("bot %s is already known", bot.Username)
 	}
 
-	if err = client.Login(bot.Username, bot.Password); err != nil {
+	if err = client.Login(ctx, bot.Username, bot.Password); err != nil {
 		return
 	}
 
@@ -51,27 +60,35 @@ func (r *registry) Append(bot model.Bot, client matrix.Client) (err error) {
 			return
 		}
 
-		if err := client.JoinRoom(roomID); err != nil {
+		if err := client.JoinRoom(context.Background(), roomID); err != nil {
 			fmt.Printf("Failed to join room %s", roomID)
 			return
 		}
 	})
 
-	err = client.OnMessage(func(roomID room.ID, message message.Message) {
-		// log messages received temporarily
-		log.WithFields(log.Fields{"room": roomID, "bot": bot.Username, "message": message.String()}).Info("message received")
+	err = client.OnMessage(func(roomID room.ID, sender string, message message.Message) {
+		log.WithFields(log.Fields{"room": roomID.ID(), "bot": bot.Username, "sender": sender, "message": message.String()}).Debug("message received")
 	})
 
 	if err != nil {
 		return
 	}
 
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if bot.IsPrimary() {
+		r.primaryUsername = bot.Username
+	}
 	r.clients[bot.Username] = client
 
 	return
 }
 
 func (r *registry) GetPrimaryClient() (matrix.Client, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
 	if client, ok := r.clients[r.primaryUsername]; ok {
 		return client, nil
 	}
@@ -80,9 +97,34 @@ func (r *registry) GetPrimaryClient() (matrix.Client, error) {
 }
 
 func (r *registry) GetClient(identifier string) (matrix.Client, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
 	if client, ok := r.clients[identifier]; ok {
 		return client, nil
 	}
 
 	return nil, fmt.Errorf("no matrix client was found for bot with identifier: %s", identifier)
 }
+
+func (r *registry) Remove(username string) {
+	r.mutex.Lock()
+	client, ok := r.clients[username]
+	delete(r.clients, username)
+	r.mutex.Unlock()
+
+	if ok {
+		log.WithFields(log.Fields{"bot": username}).Info("removing bot from registry")
+		client.Stop()
+	}
+}
+
+func (r *registry) Stop() {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for username, client := range r.clients {
+		log.WithFields(log.Fields{"bot": username}).Info("stopping bot")
+		client.Stop()
+	}
+}
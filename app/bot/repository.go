@@ -16,18 +16,34 @@ func NewRepository(session db.Session) model.Repository {
 	}
 }
 
+func (repository *repository) FindAll() (bots []model.Bot, err error) {
+	result := repository.collection.Find().OrderBy("id")
+	err = result.All(&bots)
+	return
+}
+
 func (repository *repository) FindActive() (bots []model.Bot, err error) {
 	result := repository.collection.Find(db.Cond{"active": 1})
 	err = result.All(&bots)
 	return
 }
 
+func (repository *repository) FindByID(ID uint64) (bot model.Bot, err error) {
+	result := repository.collection.Find(db.Cond{"id": ID})
+	err = result.One(&bot)
+	return
+}
+
 func (repository *repository) FindByUsername(username string) (bot model.Bot, err error) {
 	result := repository.collection.Find(db.Cond{"username": username})
 	err = result.One(&bot)
 	return
 }
 
+func (repository *repository) Remove(ID uint64) error {
+	return repository.collection.Find(db.Cond{"id": ID}).Delete()
+}
+
 func (repository *repository) Save(bot *model.Bot) (err error) {
 	if bot.ID > 0 {
 		return repository.update(bot)
@@ -103,3 +103,52 @@ func TestFindByUsername(t *testing.T) {
 		}
 	})
 }
+
+func TestFindAll(t *testing.T) {
+	database.Test(func(session db.Session) {
+		bots := fixtures.Bots(session)
+		repository := NewRepository(session)
+
+		got, err := repository.FindAll()
+		if err != nil {
+			t.Errorf("failed to get bots: %s", err)
+		}
+
+		expected := []model.Bot{bots["active 1"], bots["active 2"], bots["inactive"]}
+
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("unexpected result all bots")
+		}
+	})
+}
+
+func TestFindByID(t *testing.T) {
+	database.Test(func(session db.Session) {
+		bots := fixtures.Bots(session)
+		repository := NewRepository(session)
+
+		bot, err := repository.FindByID(3)
+		if err != nil {
+			t.Errorf("failed to find bot by ID: %s", err)
+		}
+
+		if !reflect.DeepEqual(bot, bots["inactive"]) {
+			t.Errorf("unexpected result bot by ID")
+		}
+	})
+}
+
+func TestRemove(t *testing.T) {
+	database.Test(func(session db.Session) {
+		fixtures.Bots(session)
+		repository := NewRepository(session)
+
+		if err := repository.Remove(2); err != nil {
+			t.Errorf("failed to remove bot: %s", err)
+		}
+
+		if _, err := repository.FindByUsername("bar_username"); err == nil {
+			t.Errorf("bot was not removed")
+		}
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/apex/log"
 	"github.com/joho/godotenv"
@@ -13,19 +14,35 @@ import (
 type Config struct {
 	Debug               bool
 	WebhookListenerPort int
+	AdminAPIPort        int // 0 when the admin API is disabled
+	AdminAPIToken       string
 	DatabasePath       string
 	ServerName         string
 	PrimaryBotUsername string
 	PrimaryBotPassword  string
 	WorkflowsTOMLPath   string
+	WatchInterval       time.Duration // how often the TOML file is checked for changes, 0 when it isn't
+	Prune               string        // what importing does to workflows no longer in the TOML file: deactivate, delete or nothing
+	Workers             int
+	ShutdownTimeout     time.Duration
 }
 
 func LoadFromEnvFile(envPath string) *Config {
+	return load(envPath, false)
+}
+
+// LoadOfflineFromEnvFile loads the configuration of the commands which don't connect to Matrix, which don't need its
+// server name nor the credentials of the primary bot.
+func LoadOfflineFromEnvFile(envPath string) *Config {
+	return load(envPath, true)
+}
+
+func load(envPath string, offline bool) *Config {
 	logger := log.WithFields(log.Fields{
 		"envPath": envPath,
 	})
 
-	config, err := newConfig(envPath)
+	config, err := newConfig(envPath, offline)
 	if err != nil {
 		logger.WithError(err).Fatal("Failed to load .env file")
 	}
@@ -33,12 +50,13 @@ func LoadFromEnvFile(envPath string) *Config {
 	configAsMap := config.asMap()
 	configAsMap["EnvPath"] = envPath
 	configAsMap["PrimaryBotPassword"] = "******"
+	configAsMap["AdminAPIToken"] = "******"
 	logger.WithFields(log.Fields(configAsMap)).Info("Configuration loaded")
 
 	return config
 }
 
-func newConfig(envPath string) (*Config, error) {
+func newConfig(envPath string, offline bool) (*Config, error) {
 	if err := godotenv.Load(envPath); err != nil {
 		return nil, err
 	}
@@ -50,17 +68,37 @@ func newConfig(envPath string) (*Config, error) {
 		webhookListenerPort = 8080
 	}
 
+	adminAPIPort, _ := strconv.Atoi(os.Getenv("ADMIN_API_PORT"))
+
+	workers, err := strconv.Atoi(os.Getenv("WORKERS"))
+	if err != nil {
+		workers = 4
+	}
+
+	shutdownTimeout, err := time.ParseDuration(os.Getenv("SHUTDOWN_TIMEOUT"))
+	if err != nil {
+		shutdownTimeout = 30 * time.Second
+	}
+
+	watchInterval, _ := time.ParseDuration(os.Getenv("WORKFLOWS_DEF_TOML_WATCH_INTERVAL"))
+
 	config := &Config{
 		Debug:               debug,
 		WebhookListenerPort: webhookListenerPort,
+		AdminAPIPort:        adminAPIPort,
+		AdminAPIToken:       os.Getenv("ADMIN_API_TOKEN"),
 		DatabasePath:        os.Getenv("DB_FILE"),
 		ServerName:          os.Getenv("MATRIX_SERVER_NAME"),
 		PrimaryBotUsername:  os.Getenv("MATRIX_USERNAME"),
 		PrimaryBotPassword:  os.Getenv("MATRIX_PASSWORD"),
 		WorkflowsTOMLPath:   os.Getenv("WORKFLOWS_DEF_TOML_FILE"),
+		WatchInterval:       watchInterval,
+		Prune:               os.Getenv("WORKFLOWS_DEF_TOML_PRUNE"),
+		Workers:             workers,
+		ShutdownTimeout:     shutdownTimeout,
 	}
 
-	if err := config.validate(); err != nil {
+	if err := config.validate(offline); err != nil {
 		return nil, err
 	}
 
@@ -73,11 +111,15 @@ func (c Config) asMap() (values map[string]interface{}) {
 	return
 }
 
-func (c Config) validate() error {
+func (c Config) validate(offline bool) error {
 	if c.DatabasePath == "" {
 		return errors.New("DB_FILE environment variable must be set and not empty")
 	}
 
+	if offline {
+		return c.validateTOML()
+	}
+
 	if c.ServerName == "" {
 		return errors.New("MATRIX_SERVER_NAME environment variable must be set and not empty")
 	}
@@ -90,9 +132,21 @@ func (c Config) validate() error {
 		return errors.New("MATRIX_PASSWORD environment variable must be set and not empty")
 	}
 
+	if c.AdminAPIPort != 0 && c.AdminAPIToken == "" {
+		return errors.New("ADMIN_API_TOKEN environment variable must be set and not empty when ADMIN_API_PORT is")
+	}
+
+	return c.validateTOML()
+}
+
+func (c Config) validateTOML() error {
 	if c.WorkflowsTOMLPath == "" {
 		return errors.New("WORKFLOWS_DEF_TOML_FILE environment variable must be set and not empty")
 	}
 
+	if c.Prune != "" && c.Prune != "deactivate" && c.Prune != "delete" {
+		return errors.New("WORKFLOWS_DEF_TOML_PRUNE environment variable must be deactivate or delete when set")
+	}
+
 	return nil
 }
@@ -1,64 +1,274 @@
 package engine
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"neurobot/app/bot"
 	s "neurobot/app/engine/steps"
+	"neurobot/infrastructure/condition"
+	"neurobot/model/payload"
+	"neurobot/model/run"
 	wf "neurobot/model/workflow"
 	wfs "neurobot/model/workflowstep"
+	"strings"
+	"time"
 
 	"github.com/apex/log"
 )
 
+// Engine runs workflows, until the end or until their context is done, or their timeout is reached. The payload as
+// the last step left it is returned, even when the workflow failed.
 type Engine interface {
-	Run(wf.Workflow, map[string]string) error
+	Run(context.Context, wf.Workflow, payload.Payload) (payload.Payload, error)
+
+	// RunRecorded runs a workflow like Run, recording every step in the history, under a run.
+	RunRecorded(ctx context.Context, w wf.Workflow, p payload.Payload, runID uint64) (payload.Payload, error)
 }
 
 type WorkflowStepRunner interface {
-	Run(map[string]string) (map[string]string, error) // accepts payload and returns after modification (if desired)
+	Run(context.Context, payload.Payload) (payload.Payload, error) // accepts payload and returns after modification (if desired)
 }
 
 type engine struct {
 	botRegistry            bot.Registry
 	workflowStepRepository wfs.Repository
+	runRepository          run.Repository
 }
 
-func NewEngine(botRegistry bot.Registry, workflowStepRepository wfs.Repository) *engine {
+func NewEngine(botRegistry bot.Registry, workflowStepRepository wfs.Repository, runRepository run.Repository) *engine {
 	return &engine{
 		botRegistry:            botRegistry,
 		workflowStepRepository: workflowStepRepository,
+		runRepository:          runRepository,
 	}
 }
 
-func (e *engine) Run(w wf.Workflow, payload map[string]string) error {
+// StepError is the failure of a workflow step, after all its attempts.
+type StepError struct {
+	Position int // position of the step in the workflow, starting at 1
+	Step     wfs.WorkflowStep
+	Err      error
+}
+
+// RunError is returned by Run when workflow steps failed, it describes every failed step.
+type RunError struct {
+	Workflow    wf.Workflow
+	Steps       []StepError
+	Halted      bool  // whether the workflow was stopped by a failed step, rather than run to the end
+	Interrupted error // why the workflow was stopped before the end when its context was done, if it was
+}
+
+func (e RunError) Error() string {
+	var failures []string
+	for _, step := range e.Steps {
+		failures = append(failures, fmt.Sprintf("step %d (%s) failed: %s", step.Position, step.Step.Name, step.Err))
+	}
+
+	if e.Halted {
+		failures = append(failures, "workflow halted")
+	}
+	if e.Interrupted != nil {
+		failures = append(failures, fmt.Sprintf("workflow interrupted: %s", e.Interrupted))
+	}
+
+	return fmt.Sprintf("workflow %s: %s", e.Workflow.Identifier, strings.Join(failures, "; "))
+}
+
+// Unwrap makes the reason of an interruption, context.Canceled or context.DeadlineExceeded, available to errors.Is.
+func (e RunError) Unwrap() error {
+	return e.Interrupted
+}
+
+// stepResult is the outcome of a step, after all its attempts.
+type stepResult struct {
+	payload  payload.Payload
+	skipped  bool // whether the condition of the step didn't match
+	attempts int
+	err      error
+}
+
+func (e *engine) Run(ctx context.Context, w wf.Workflow, p payload.Payload) (payload.Payload, error) {
+	return e.RunRecorded(ctx, w, p, 0)
+}
+
+func (e *engine) RunRecorded(ctx context.Context, w wf.Workflow, p payload.Payload, runID uint64) (payload.Payload, error) {
 	logger := log.Log
 
+	timeout, err := parseTimeout(w.Timeout)
+	if err != nil {
+		return p, fmt.Errorf("invalid timeout of workflow %s: %w", w.Identifier, err)
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
 	// loop through all the steps inside of the workflow
 	steps, err := e.workflowStepRepository.FindByWorkflowID(w.ID)
 	if err != nil {
-		return fmt.Errorf("error fetching workflow steps while running workflow %d : %w", w.ID, err)
+		return p, fmt.Errorf("error fetching workflow steps while running workflow %d : %w", w.ID, err)
 	}
 
-	var runners []WorkflowStepRunner
+	runError := RunError{Workflow: w}
+
+	for i, step := range steps {
+		if ctx.Err() != nil {
+			break
+		}
+
+		stepLogger := logger.WithFields(log.Fields{
+			"Identifier": w.Identifier,
+			"step":       step.Name,
+		})
+
+		startedAt := time.Now()
+
+		result := stepResult{payload: p}
+		policy, err := policyFor(w, step)
+		if err == nil {
+			result = e.runStepWithPolicy(ctx, step, policy, p, stepLogger)
+			p, err = result.payload, result.err
+		}
+
+		if runID > 0 {
+			e.recordStep(runID, i+1, step, result, err, startedAt, stepLogger)
+		}
 
-	for _, step := range steps {
-		switch step.Variety {
-		case "postMatrixMessage":
-			runners = append(runners, s.NewPostMatrixMessageRunner(step.Meta, e.botRegistry))
-		case "stdOut":
-			runners = append(runners, s.NewStdOutRunner(step.Meta, e.botRegistry))
+		if errors.Is(err, s.ErrHalt) {
+			stepLogger.Info("workflow halted")
+			break
 		}
+
+		if err != nil {
+			stepLogger.WithError(err).Info("workflow step execution error")
+			runError.Steps = append(runError.Steps, StepError{Position: i + 1, Step: step, Err: err})
+
+			if policy.onError != continueOnError && ctx.Err() == nil {
+				runError.Halted = true
+				break
+			}
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		logger.WithError(err).WithFields(log.Fields{"Identifier": w.Identifier}).Info("workflow interrupted")
+		runError.Interrupted = err
 	}
 
-	for _, r := range runners {
-		payload, err = r.Run(payload)
+	if len(runError.Steps) > 0 || runError.Interrupted != nil {
+		return p, runError
+	}
+
+	return p, nil
+}
+
+// runStepWithPolicy runs a step, retrying it with an exponential backoff when its policy says so,
+// unless the workflow is interrupted in the meantime.
+func (e *engine) runStepWithPolicy(ctx context.Context, step wfs.WorkflowStep, policy errorPolicy, p payload.Payload, logger log.Interface) stepResult {
+	attempts := 1
+	if policy.onError == retryOnError {
+		attempts = int(policy.maxAttempts)
+	}
+
+	for attempt := 1; ; attempt++ {
+		result, skipped, err := e.runStepAttempt(ctx, step, policy.timeout, p)
+		if err == nil || errors.Is(err, s.ErrHalt) || attempt >= attempts || ctx.Err() != nil {
+			return stepResult{payload: result, skipped: skipped, attempts: attempt, err: failedAfter(err, attempt)}
+		}
+
+		backoff := policy.backoff(attempt)
+		logger.WithError(err).WithFields(log.Fields{"attempt": attempt, "backoff": backoff}).Info("retrying workflow step")
+		select {
+		case <-ctx.Done():
+			return stepResult{payload: result, skipped: skipped, attempts: attempt, err: failedAfter(err, attempt)}
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// failedAfter tells how many attempts a step failed after, when it was attempted more than once.
+func failedAfter(err error, attempts int) error {
+	if err == nil || attempts < 2 || errors.Is(err, s.ErrHalt) {
+		return err
+	}
+
+	return fmt.Errorf("failed after %d attempts: %w", attempts, err)
+}
+
+// runStepAttempt runs a step once, cancelling it when it takes longer than its timeout, if it has one.
+func (e *engine) runStepAttempt(ctx context.Context, step wfs.WorkflowStep, timeout time.Duration, p payload.Payload) (payload.Payload, bool, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	return e.runStep(ctx, step, p)
+}
+
+// recordStep keeps the outcome of a step in the history, failing to do so doesn't fail the workflow.
+func (e *engine) recordStep(runID uint64, position int, step wfs.WorkflowStep, result stepResult, err error, startedAt time.Time, logger log.Interface) {
+	stepRun := run.StepRun{
+		RunID:     runID,
+		StepID:    step.ID,
+		Position:  position,
+		Name:      step.Name,
+		Variety:   step.Variety,
+		Status:    run.StepStatusSucceeded,
+		Attempts:  result.attempts,
+		Output:    result.payload,
+		StartedAt: startedAt,
+		Duration:  time.Since(startedAt),
+	}
+
+	switch {
+	case errors.Is(err, s.ErrHalt):
+		stepRun.Status = run.StepStatusHalted
+	case err != nil:
+		stepRun.Status = run.StepStatusFailed
+		stepRun.Error = err.Error()
+	case result.skipped:
+		stepRun.Status = run.StepStatusSkipped
+	}
+
+	if saveErr := e.runRepository.SaveStep(&stepRun); saveErr != nil {
+		logger.WithError(saveErr).Error("Failed to record workflow step in history")
+	}
+}
+
+// runStep runs a step once, unless its condition doesn't match the payload, in which case it is skipped.
+func (e *engine) runStep(ctx context.Context, step wfs.WorkflowStep, p payload.Payload) (result payload.Payload, skipped bool, err error) {
+	if step.Condition != "" {
+		c, err := condition.Parse(step.Condition)
 		if err != nil {
-			// For now, we don't halt the workflow if a workflow step encounters an error
-			logger.WithError(err).WithFields(log.Fields{
-				"Identifier": w.Identifier,
-			}).Info("workflow step execution error")
+			return p, false, err
+		}
+
+		if !c.Evaluate(p) {
+			log.WithFields(log.Fields{"step": step.Name}).Debug("skipping workflow step as its condition doesn't match")
+			return p, true, nil
 		}
 	}
 
-	return nil
+	variety, ok := s.Lookup(step.Variety)
+	if !ok {
+		return p, false, fmt.Errorf("unknown workflow step variety %q", step.Variety)
+	}
+
+	// Meta is rendered right before running a step, so that it can use what previous steps added to the payload
+	meta, err := renderMeta(step.Meta, p)
+	if err != nil {
+		return p, false, err
+	}
+
+	if err = variety.Schema.Validate(meta); err != nil {
+		return p, false, err
+	}
+
+	var r WorkflowStepRunner = variety.New(variety.Schema.WithDefaults(meta), e.botRegistry)
+	result, err = r.Run(ctx, p)
+
+	return result, false, err
 }
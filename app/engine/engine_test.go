@@ -0,0 +1,328 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	runApp "neurobot/app/run"
+	"neurobot/model/payload"
+	"neurobot/model/run"
+	wf "neurobot/model/workflow"
+	wfs "neurobot/model/workflowstep"
+	"neurobot/resources/tests/database"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/upper/db/v4"
+)
+
+type workflowStepRepositoryMock struct {
+	wfs.Repository
+	steps []wfs.WorkflowStep
+}
+
+func (repository workflowStepRepositoryMock) FindByWorkflowID(ID uint64) ([]wfs.WorkflowStep, error) {
+	return repository.steps, nil
+}
+
+func TestRunConditions(t *testing.T) {
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+	}))
+	defer server.Close()
+
+	e := NewEngine(nil, workflowStepRepositoryMock{steps: []wfs.WorkflowStep{
+		{Variety: "httpRequest", Condition: `payload.severity == "critical"`, Meta: map[string]string{"url": server.URL + "/page"}},
+		{Variety: "halt", Condition: `payload.severity == "info"`},
+		{Variety: "httpRequest", Meta: map[string]string{"url": server.URL + "/{{ .payload.severity }}"}},
+	}}, nil)
+
+	tables := []struct {
+		severity  string
+		requested []string
+	}{
+		{severity: "critical", requested: []string{"/page", "/critical"}},
+		{severity: "warning", requested: []string{"/warning"}},
+		{severity: "info"},
+	}
+
+	for _, table := range tables {
+		requested = nil
+		if _, err := e.Run(context.Background(), wf.Workflow{}, payload.Payload{"severity": table.severity}); err != nil {
+			t.Errorf("%s: failed to run: %s", table.severity, err)
+		}
+
+		if !reflect.DeepEqual(requested, table.requested) {
+			t.Errorf("%s: expected requests to %v, got %v", table.severity, table.requested, requested)
+		}
+	}
+}
+
+func TestRunErrorPolicies(t *testing.T) {
+	var requested []string
+	failures := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		if r.URL.Path == "/flaky" && failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	tables := []struct {
+		name      string
+		workflow  wf.Workflow
+		steps     []wfs.WorkflowStep
+		failures  int
+		requested []string
+		failed    []int
+		halted    bool
+	}{
+		{
+			name: "continue by default",
+			steps: []wfs.WorkflowStep{
+				{Name: "broken", Variety: "httpRequest", Meta: map[string]string{"url": server.URL + "/broken"}},
+				{Name: "next", Variety: "httpRequest", Meta: map[string]string{"url": server.URL + "/next"}},
+			},
+			requested: []string{"/broken", "/next"},
+			failed:    []int{1},
+		},
+		{
+			name: "unknown variety",
+			steps: []wfs.WorkflowStep{
+				{Name: "unknown", Variety: "sendFax"},
+				{Name: "next", Variety: "httpRequest", Meta: map[string]string{"url": server.URL + "/next"}},
+			},
+			requested: []string{"/next"},
+			failed:    []int{1},
+		},
+		{
+			name: "invalid meta",
+			steps: []wfs.WorkflowStep{
+				{Name: "invalid", Variety: "httpRequest", Meta: map[string]string{"url": server.URL + "/next", "timeout": "{{ .payload.missing | default \"soon\" }}"}},
+			},
+			failed: []int{1},
+		},
+		{
+			name:     "halt inherited from workflow",
+			workflow: wf.Workflow{OnError: "halt"},
+			steps: []wfs.WorkflowStep{
+				{Name: "broken", Variety: "httpRequest", Meta: map[string]string{"url": server.URL + "/broken"}},
+				{Name: "next", Variety: "httpRequest", Meta: map[string]string{"url": server.URL + "/next"}},
+			},
+			requested: []string{"/broken"},
+			failed:    []int{1},
+			halted:    true,
+		},
+		{
+			name:     "step overrides workflow",
+			workflow: wf.Workflow{OnError: "halt"},
+			steps: []wfs.WorkflowStep{
+				{Name: "broken", Variety: "httpRequest", OnError: "continue", Meta: map[string]string{"url": server.URL + "/broken"}},
+				{Name: "next", Variety: "httpRequest", Meta: map[string]string{"url": server.URL + "/next"}},
+			},
+			requested: []string{"/broken", "/next"},
+			failed:    []int{1},
+		},
+		{
+			name:     "retry until success",
+			workflow: wf.Workflow{RetryBackoff: "1ms"},
+			steps: []wfs.WorkflowStep{
+				{Name: "flaky", Variety: "httpRequest", OnError: "retry", Meta: map[string]string{"url": server.URL + "/flaky"}},
+				{Name: "next", Variety: "httpRequest", Meta: map[string]string{"url": server.URL + "/next"}},
+			},
+			failures:  2,
+			requested: []string{"/flaky", "/flaky", "/flaky", "/next"},
+		},
+		{
+			name:     "retry until out of attempts",
+			workflow: wf.Workflow{OnError: "retry", MaxAttempts: 2, RetryBackoff: "1ms"},
+			steps: []wfs.WorkflowStep{
+				{Name: "flaky", Variety: "httpRequest", Meta: map[string]string{"url": server.URL + "/flaky"}},
+				{Name: "next", Variety: "httpRequest", Meta: map[string]string{"url": server.URL + "/next"}},
+			},
+			failures:  2,
+			requested: []string{"/flaky", "/flaky"},
+			failed:    []int{1},
+			halted:    true,
+		},
+	}
+
+	for _, table := range tables {
+		requested = nil
+		failures = table.failures
+
+		e := NewEngine(nil, workflowStepRepositoryMock{steps: table.steps}, nil)
+		_, err := e.Run(context.Background(), table.workflow, payload.Payload{})
+
+		if !reflect.DeepEqual(requested, table.requested) {
+			t.Errorf("%s: expected requests to %v, got %v", table.name, table.requested, requested)
+		}
+
+		if table.failed == nil {
+			if err != nil {
+				t.Errorf("%s: expected no error, got: %s", table.name, err)
+			}
+			continue
+		}
+
+		runError, ok := err.(RunError)
+		if !ok {
+			t.Errorf("%s: expected a RunError, got: %v", table.name, err)
+			continue
+		}
+
+		var failed []int
+		for _, step := range runError.Steps {
+			failed = append(failed, step.Position)
+		}
+
+		if !reflect.DeepEqual(failed, table.failed) || runError.Halted != table.halted {
+			t.Errorf("%s: unexpected error %s", table.name, runError)
+		}
+	}
+}
+
+func TestRunTimeouts(t *testing.T) {
+	var mutex sync.Mutex
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		requested = append(requested, r.URL.Path)
+		mutex.Unlock()
+		if r.URL.Path == "/slow" {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+		}
+	}))
+	defer server.Close()
+
+	steps := []wfs.WorkflowStep{
+		{Name: "slow", Variety: "httpRequest", Meta: map[string]string{"url": server.URL + "/slow"}},
+		{Name: "next", Variety: "httpRequest", Meta: map[string]string{"url": server.URL + "/next"}},
+	}
+	timedOutStep := append([]wfs.WorkflowStep{}, steps...)
+	timedOutStep[0].Timeout = "20ms"
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tables := []struct {
+		name        string
+		ctx         context.Context
+		workflow    wf.Workflow
+		steps       []wfs.WorkflowStep
+		requested   []string
+		interrupted error
+	}{
+		{
+			name:      "step timeout",
+			ctx:       context.Background(),
+			steps:     timedOutStep,
+			requested: []string{"/slow", "/next"},
+		},
+		{
+			name:        "workflow timeout",
+			ctx:         context.Background(),
+			workflow:    wf.Workflow{Timeout: "20ms"},
+			steps:       steps,
+			requested:   []string{"/slow"},
+			interrupted: context.DeadlineExceeded,
+		},
+		{
+			name:        "cancelled",
+			ctx:         cancelled,
+			steps:       steps,
+			interrupted: context.Canceled,
+		},
+	}
+
+	for _, table := range tables {
+		requested = nil
+
+		e := NewEngine(nil, workflowStepRepositoryMock{steps: table.steps}, nil)
+		_, err := e.Run(table.ctx, table.workflow, payload.Payload{})
+
+		mutex.Lock()
+		if !reflect.DeepEqual(requested, table.requested) {
+			t.Errorf("%s: expected requests to %v, got %v", table.name, table.requested, requested)
+		}
+		mutex.Unlock()
+
+		runError, ok := err.(RunError)
+		if !ok {
+			t.Errorf("%s: expected a RunError, got: %v", table.name, err)
+			continue
+		}
+
+		if runError.Interrupted != table.interrupted {
+			t.Errorf("%s: expected interruption %v, got %v", table.name, table.interrupted, runError.Interrupted)
+		}
+
+		if table.interrupted != nil && !errors.Is(err, table.interrupted) {
+			t.Errorf("%s: expected error to be %v, got: %s", table.name, table.interrupted, err)
+		}
+	}
+}
+
+func TestRunRecorded(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	database.Test(func(session db.Session) {
+		runRepository := runApp.NewRepository(session)
+		e := NewEngine(nil, workflowStepRepositoryMock{steps: []wfs.WorkflowStep{
+			{ID: 1, Name: "skipped", Variety: "httpRequest", Condition: "payload.missing", Meta: map[string]string{"url": server.URL}},
+			{ID: 2, Name: "ok", Variety: "httpRequest", Meta: map[string]string{"url": server.URL}},
+			{ID: 3, Name: "broken", Variety: "httpRequest", Meta: map[string]string{"url": server.URL + "/broken"}},
+			{ID: 4, Name: "halt", Variety: "halt"},
+			{ID: 5, Name: "never", Variety: "httpRequest", Meta: map[string]string{"url": server.URL}},
+		}}, runRepository)
+
+		r := run.Run{WorkflowID: 1, Trigger: "webhook", Status: run.StatusRunning, StartedAt: time.Now()}
+		if err := runRepository.Save(&r); err != nil {
+			t.Fatalf("failed to save run: %s", err)
+		}
+
+		output, err := e.RunRecorded(context.Background(), wf.Workflow{ID: 1}, payload.Payload{"message": "hello"}, r.ID)
+		if err == nil {
+			t.Errorf("expected the broken step to fail the run")
+		}
+		if output.String("response.status") != "500" || output.String("message") != "hello" {
+			t.Errorf("expected the payload the last step left, got %+v", output)
+		}
+
+		steps, err := runRepository.FindSteps(r.ID)
+		if err != nil {
+			t.Fatalf("failed to find step runs: %s", err)
+		}
+
+		var got []string
+		for _, step := range steps {
+			got = append(got, step.Name+":"+step.Status)
+		}
+
+		expected := []string{"skipped:skipped", "ok:succeeded", "broken:failed", "halt:halted"}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("expected step runs %v, got %v", expected, got)
+		}
+
+		if steps[1].Output.String("response.status") != "200" || steps[1].Output.String("message") != "hello" || steps[2].Error == "" {
+			t.Errorf("unexpected step runs %+v", steps)
+		}
+	})
+}
@@ -0,0 +1,97 @@
+package engine
+
+import (
+	"fmt"
+	wf "neurobot/model/workflow"
+	wfs "neurobot/model/workflowstep"
+	"time"
+)
+
+// What to do when a workflow step fails.
+const (
+	continueOnError = "continue" // run the next steps
+	haltOnError     = "halt"     // stop the workflow
+	retryOnError    = "retry"    // run the step again, stopping the workflow when all attempts failed
+)
+
+const defaultMaxAttempts = 3
+const defaultRetryBackoff = time.Second
+
+// maxRetryBackoff caps the delay between two attempts, so that many attempts don't end up waiting for days.
+const maxRetryBackoff = time.Hour
+
+type errorPolicy struct {
+	onError      string
+	maxAttempts  uint64        // how many times a step is run in total, when retrying
+	retryBackoff time.Duration // delay before the first retry, doubled for every following one up to maxRetryBackoff
+	timeout      time.Duration // how long every attempt can take, 0 means no limit
+}
+
+// policyFor returns the error policy of a step, anything the step doesn't define is inherited from its workflow.
+func policyFor(w wf.Workflow, step wfs.WorkflowStep) (policy errorPolicy, err error) {
+	policy.onError = firstNonEmpty(step.OnError, w.OnError, continueOnError)
+	switch policy.onError {
+	case continueOnError, haltOnError, retryOnError:
+	default:
+		return policy, fmt.Errorf("unknown onError policy %q", policy.onError)
+	}
+
+	policy.maxAttempts = step.MaxAttempts
+	if policy.maxAttempts == 0 {
+		policy.maxAttempts = w.MaxAttempts
+	}
+	if policy.maxAttempts == 0 {
+		policy.maxAttempts = defaultMaxAttempts
+	}
+
+	policy.retryBackoff = defaultRetryBackoff
+	if backoff := firstNonEmpty(step.RetryBackoff, w.RetryBackoff); backoff != "" {
+		if policy.retryBackoff, err = time.ParseDuration(backoff); err != nil {
+			return policy, fmt.Errorf("invalid retryBackoff: %w", err)
+		}
+	}
+
+	if policy.timeout, err = parseTimeout(step.Timeout); err != nil {
+		return policy, fmt.Errorf("invalid timeout: %w", err)
+	}
+
+	return
+}
+
+// backoff returns the delay before retrying after the given attempt, starting at 1.
+func (policy errorPolicy) backoff(attempt int) time.Duration {
+	backoff := policy.retryBackoff
+	for i := 1; i < attempt && backoff < maxRetryBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > maxRetryBackoff {
+		return maxRetryBackoff
+	}
+
+	return backoff
+}
+
+// parseTimeout parses the timeout of a workflow or a step, empty meaning no timeout.
+func parseTimeout(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	timeout, err := time.ParseDuration(value)
+	if err == nil && timeout <= 0 {
+		err = fmt.Errorf("timeout must be positive, got %q", value)
+	}
+
+	return timeout, err
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
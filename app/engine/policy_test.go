@@ -0,0 +1,27 @@
+package engine
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	tables := []struct {
+		retryBackoff time.Duration
+		attempt      int
+		expected     time.Duration
+	}{
+		{retryBackoff: time.Second, attempt: 1, expected: time.Second},
+		{retryBackoff: time.Second, attempt: 3, expected: 4 * time.Second},
+		{retryBackoff: 10 * time.Second, attempt: 19, expected: maxRetryBackoff},
+		{retryBackoff: 2 * time.Hour, attempt: 1, expected: maxRetryBackoff},
+		{retryBackoff: 0, attempt: 5, expected: 0},
+	}
+
+	for _, table := range tables {
+		policy := errorPolicy{retryBackoff: table.retryBackoff}
+		if got := policy.backoff(table.attempt); got != table.expected {
+			t.Errorf("backoff of %s after attempt %d: expected %s, got %s", table.retryBackoff, table.attempt, table.expected, got)
+		}
+	}
+}
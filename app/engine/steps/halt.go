@@ -0,0 +1,32 @@
+package steps
+
+import (
+	"context"
+	"errors"
+	botApp "neurobot/app/bot"
+	"neurobot/model/payload"
+	wfs "neurobot/model/workflowstep"
+)
+
+func init() {
+	Register(Variety{
+		Name:   "halt",
+		Schema: wfs.Schema{},
+		New: func(meta map[string]string, botRegistry botApp.Registry) Runner {
+			return NewHaltRunner(meta, botRegistry)
+		},
+	})
+}
+
+// ErrHalt is returned by a step to stop the workflow, the steps after it aren't run.
+var ErrHalt = errors.New("workflow halted")
+
+type haltWorkflowStepRunner struct{}
+
+func (runner haltWorkflowStepRunner) Run(ctx context.Context, p payload.Payload) (payload.Payload, error) {
+	return p, ErrHalt
+}
+
+func NewHaltRunner(meta map[string]string, botRegistry botApp.Registry) *haltWorkflowStepRunner {
+	return &haltWorkflowStepRunner{}
+}
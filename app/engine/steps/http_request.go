@@ -0,0 +1,199 @@
+package steps
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	netHttp "net/http"
+	"net/url"
+	botApp "neurobot/app/bot"
+	"neurobot/infrastructure/http"
+	"neurobot/model/payload"
+	wfs "neurobot/model/workflowstep"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultHttpRequestTimeout = 30 * time.Second
+const defaultHttpRequestMaxResponseSize = 1 << 20
+const defaultHttpRequestOutputPrefix = "response"
+
+func init() {
+	Register(Variety{
+		Name: "httpRequest",
+		Schema: wfs.Schema{
+			"method":            {Type: wfs.StringMeta, Default: netHttp.MethodGet},
+			"url":               {Type: wfs.URLMeta, Required: true},
+			"headers":           {Type: wfs.StringMeta},
+			"body":              {Type: wfs.StringMeta},
+			"timeout":           {Type: wfs.DurationMeta, Default: defaultHttpRequestTimeout.String()},
+			"maxResponseSize":   {Type: wfs.IntMeta, Default: strconv.Itoa(defaultHttpRequestMaxResponseSize)},
+			"decodeJSON":        {Type: wfs.BoolMeta, Default: "false"},
+			"ignoreErrorStatus": {Type: wfs.BoolMeta, Default: "false"},
+			"outputPrefix":      {Type: wfs.StringMeta, Default: defaultHttpRequestOutputPrefix},
+		},
+		New: func(meta map[string]string, botRegistry botApp.Registry) Runner {
+			return NewHttpRequestRunner(meta, botRegistry)
+		},
+	})
+}
+
+type httpRequestWorkflowStepMeta struct {
+	method            string        // HTTP method, GET when not specified
+	url               string        // URL to request
+	headers           string        // headers, one per line, as `Name: value`
+	body              string        // request body, nothing is sent when empty
+	timeout           time.Duration // how long to wait for the whole request
+	maxResponseSize   int64         // responses larger than this many bytes are an error
+	decodeJSON        bool          // whether the response body is JSON to be decoded into the payload
+	ignoreErrorStatus bool          // whether a non 2xx response is not to be an error
+	outputPrefix      string        // prefix of the payload keys under which the response is stored
+}
+
+type httpRequestWorkflowStepRunner struct {
+	meta   map[string]string
+	client *netHttp.Client
+}
+
+// Run sends the request and stores the response in the payload, under `response.status`, `response.headers.<Name>`
+// and `response.body`, which is the decoded JSON document when decoding JSON.
+func (runner httpRequestWorkflowStepRunner) Run(ctx context.Context, p payload.Payload) (payload.Payload, error) {
+	meta, err := parseHttpRequestMeta(runner.meta)
+	if err != nil {
+		return p, err
+	}
+
+	request, err := newHttpRequest(ctx, meta)
+	if err != nil {
+		return p, err
+	}
+
+	client := *runner.client
+	client.Timeout = meta.timeout
+
+	response, err := client.Do(request)
+	if err != nil {
+		return p, err
+	}
+	defer response.Body.Close()
+
+	body, err := http.ReadBody(response.Body, meta.maxResponseSize)
+	if err != nil {
+		return p, err
+	}
+
+	result := p.Clone()
+	if result == nil {
+		result = make(payload.Payload)
+	}
+
+	result.Set(meta.outputPrefix+".status", response.StatusCode)
+	for name, values := range response.Header {
+		result.Set(meta.outputPrefix+".headers."+name, strings.Join(values, ", "))
+	}
+
+	if meta.decodeJSON && len(bytes.TrimSpace(body)) > 0 {
+		var document interface{}
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		if err = decoder.Decode(&document); err != nil {
+			return p, fmt.Errorf("failed to decode JSON response: %w", err)
+		}
+		result.Set(meta.outputPrefix+".body", document)
+	} else {
+		result.Set(meta.outputPrefix+".body", string(body))
+	}
+
+	if !meta.ignoreErrorStatus && (response.StatusCode < 200 || response.StatusCode > 299) {
+		return result, fmt.Errorf("unexpected response status %s", response.Status)
+	}
+
+	return result, nil
+}
+
+func newHttpRequest(ctx context.Context, meta httpRequestWorkflowStepMeta) (*netHttp.Request, error) {
+	var body io.Reader
+	if meta.body != "" {
+		body = strings.NewReader(meta.body)
+	}
+
+	request, err := netHttp.NewRequestWithContext(ctx, meta.method, meta.url, body)
+	if err != nil {
+		return nil, err
+	}
+
+	headers, err := http.ParseHeaders(meta.headers)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, values := range headers {
+		for _, value := range values {
+			request.Header.Add(name, value)
+		}
+	}
+
+	return request, nil
+}
+
+func parseHttpRequestMeta(meta map[string]string) (m httpRequestWorkflowStepMeta, err error) {
+	m.method = strings.ToUpper(strings.TrimSpace(meta["method"]))
+	if m.method == "" {
+		m.method = netHttp.MethodGet
+	}
+
+	u, err := url.Parse(meta["url"])
+	if err != nil {
+		return
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return m, fmt.Errorf("url must be http or https, got %q", meta["url"])
+	}
+	m.url = u.String()
+
+	m.headers = meta["headers"]
+	m.body = meta["body"]
+
+	m.timeout = defaultHttpRequestTimeout
+	if meta["timeout"] != "" {
+		if m.timeout, err = time.ParseDuration(meta["timeout"]); err != nil {
+			return
+		}
+	}
+
+	m.maxResponseSize = defaultHttpRequestMaxResponseSize
+	if meta["maxResponseSize"] != "" {
+		if m.maxResponseSize, err = strconv.ParseInt(meta["maxResponseSize"], 10, 64); err != nil {
+			return
+		}
+	}
+
+	if meta["decodeJSON"] != "" {
+		if m.decodeJSON, err = strconv.ParseBool(meta["decodeJSON"]); err != nil {
+			return
+		}
+	}
+
+	if meta["ignoreErrorStatus"] != "" {
+		if m.ignoreErrorStatus, err = strconv.ParseBool(meta["ignoreErrorStatus"]); err != nil {
+			return
+		}
+	}
+
+	m.outputPrefix = meta["outputPrefix"]
+	if m.outputPrefix == "" {
+		m.outputPrefix = defaultHttpRequestOutputPrefix
+	}
+
+	return
+}
+
+func NewHttpRequestRunner(meta map[string]string, botRegistry botApp.Registry) *httpRequestWorkflowStepRunner {
+	return &httpRequestWorkflowStepRunner{
+		meta:   meta,
+		client: &netHttp.Client{},
+	}
+}
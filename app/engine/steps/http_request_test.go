@@ -0,0 +1,100 @@
+package steps
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"neurobot/model/payload"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHttpRequestWorkflowStep(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/echo":
+			body, _ := ioutil.ReadAll(r.Body)
+			w.Header().Set("X-Method", r.Method)
+			w.Header().Set("X-Token", r.Header.Get("X-Token"))
+			w.Write(body)
+		case "/json":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"user": {"login": "alice"}, "tags": ["a"]}`))
+		case "/slow":
+			time.Sleep(100 * time.Millisecond)
+		case "/large":
+			w.Write([]byte(strings.Repeat("a", 100)))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("not found"))
+		}
+	}))
+	defer server.Close()
+
+	runner := NewHttpRequestRunner(map[string]string{
+		"method":  "post",
+		"url":     server.URL + "/echo",
+		"headers": "X-Token: secret",
+		"body":    `{"text": "hello"}`,
+	}, nil)
+	got, err := runner.Run(context.Background(), payload.Payload{"message": "hello"})
+	if err != nil {
+		t.Fatalf("request should succeed, got: %s", err)
+	}
+	if got["message"] != "hello" || got.String("response.status") != "200" || got.String("response.body") != `{"text": "hello"}` {
+		t.Errorf("unexpected payload %+v", got)
+	}
+	if got.String("response.headers.X-Method") != "POST" || got.String("response.headers.X-Token") != "secret" {
+		t.Errorf("unexpected response headers in payload %+v", got)
+	}
+
+	original := payload.Payload{"github": map[string]interface{}{"event": "push"}}
+	runner = NewHttpRequestRunner(map[string]string{"url": server.URL + "/json", "decodeJSON": "true", "outputPrefix": "github"}, nil)
+	got, err = runner.Run(context.Background(), original)
+	if err != nil {
+		t.Fatalf("request should succeed, got: %s", err)
+	}
+	if got.String("github.body.user.login") != "alice" || got.String("github.body.tags.0") != "a" || got.String("github.event") != "push" {
+		t.Errorf("unexpected payload %+v", got)
+	}
+	if _, ok := got.Get("github.body.tags"); !ok {
+		t.Errorf("decoded JSON should be kept nested, got %+v", got)
+	}
+	if _, ok := original.Get("github.body"); ok {
+		t.Errorf("the payload the step is given should not be modified")
+	}
+
+	runner = NewHttpRequestRunner(map[string]string{"url": server.URL + "/missing"}, nil)
+	if got, err = runner.Run(context.Background(), payload.Payload{}); err == nil {
+		t.Errorf("non 2xx response should be an error")
+	}
+	if got.String("response.status") != "404" || got.String("response.body") != "not found" {
+		t.Errorf("unexpected payload %+v", got)
+	}
+
+	runner = NewHttpRequestRunner(map[string]string{"url": server.URL + "/missing", "ignoreErrorStatus": "true"}, nil)
+	if _, err = runner.Run(context.Background(), payload.Payload{}); err != nil {
+		t.Errorf("non 2xx response should not be an error when ignored, got: %s", err)
+	}
+
+	failing := []map[string]string{
+		{"url": server.URL + "/slow", "timeout": "10ms"},
+		{"url": server.URL + "/large", "maxResponseSize": "10"},
+		{"url": "ftp://example.com"},
+		{"url": server.URL + "/echo", "headers": "X-Token"},
+	}
+
+	for _, meta := range failing {
+		if _, err = NewHttpRequestRunner(meta, nil).Run(context.Background(), payload.Payload{}); err == nil {
+			t.Errorf("request with meta %+v should fail", meta)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err = NewHttpRequestRunner(map[string]string{"url": server.URL + "/slow"}, nil).Run(ctx, payload.Payload{}); err == nil {
+		t.Errorf("request should fail when its context is done")
+	}
+}
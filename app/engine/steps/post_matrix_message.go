@@ -1,16 +1,35 @@
 package steps
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	botApp "neurobot/app/bot"
 	"neurobot/infrastructure/matrix"
 	"neurobot/model/message"
+	"neurobot/model/payload"
 	r "neurobot/model/room"
+	wfs "neurobot/model/workflowstep"
 )
 
+func init() {
+	Register(Variety{
+		Name: "postMatrixMessage",
+		Schema: wfs.Schema{
+			"messagePrefix": {Type: wfs.StringMeta},
+			"message":       {Type: wfs.StringMeta},
+			"room":          {Type: wfs.StringMeta},
+			"asBot":         {Type: wfs.StringMeta},
+		},
+		New: func(meta map[string]string, botRegistry botApp.Registry) Runner {
+			return NewPostMatrixMessageRunner(meta, botRegistry)
+		},
+	})
+}
+
 type postMatrixMessageWorkflowStepMeta struct {
 	messagePrefix string // message prefix
+	message       string // message, overrides the one in payload
 	room          string // Matrix room
 	asBot         string // bot identifier, for matrix session
 }
@@ -29,13 +48,16 @@ func (runner postMatrixMessageWorkflowStepRunner) getMatrixClient() (mc matrix.C
 	return runner.botRegistry.GetClient(runner.asBot)
 }
 
-func (runner postMatrixMessageWorkflowStepRunner) Run(p map[string]string) (map[string]string, error) {
-	msg := p["message"]
+func (runner postMatrixMessageWorkflowStepRunner) Run(ctx context.Context, p payload.Payload) (payload.Payload, error) {
+	msg := p.String("message")
+	if runner.message != "" {
+		msg = runner.message
+	}
 
 	// Append message specified in definition of this step as a prefix to the payload
 	if runner.messagePrefix != "" {
-		if p["message"] != "" {
-			msg = fmt.Sprintf("%s %s", runner.messagePrefix, p["message"])
+		if msg != "" {
+			msg = fmt.Sprintf("%s %s", runner.messagePrefix, msg)
 		} else {
 			msg = runner.messagePrefix
 		}
@@ -43,8 +65,8 @@ func (runner postMatrixMessageWorkflowStepRunner) Run(p map[string]string) (map[
 
 	// Override room defined in meta, if provided in payload
 	room := runner.room
-	if p["room"] != "" {
-		room = p["room"]
+	if override := p.String("room"); override != "" {
+		room = override
 	}
 
 	// ensure we have data to work with
@@ -65,7 +87,7 @@ func (runner postMatrixMessageWorkflowStepRunner) Run(p map[string]string) (map[
 		return p, err
 	}
 
-	err = mc.SendMessage(roomID, message.NewMarkdownMessage(msg))
+	err = mc.SendMessage(ctx, roomID, message.NewMarkdownMessage(msg))
 
 	return p, err
 }
@@ -89,6 +111,11 @@ func NewPostMatrixMessageRunner(meta map[string]string, botRegistry botApp.Regis
 		stepMeta.messagePrefix = ""
 	}
 
+	stepMeta.message, ok = meta["message"]
+	if !ok {
+		stepMeta.message = ""
+	}
+
 	return &postMatrixMessageWorkflowStepRunner{
 		postMatrixMessageWorkflowStepMeta: stepMeta,
 		botRegistry:                       botRegistry,
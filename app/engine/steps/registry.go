@@ -0,0 +1,53 @@
+package steps
+
+import (
+	"context"
+	"fmt"
+	botApp "neurobot/app/bot"
+	"neurobot/model/payload"
+	wfs "neurobot/model/workflowstep"
+)
+
+// Runner runs a workflow step, it accepts the payload and returns it after modification (if desired).
+type Runner interface {
+	Run(context.Context, payload.Payload) (payload.Payload, error)
+}
+
+// Constructor makes the runner of a workflow step, from its meta once rendered, validated against the schema of its
+// variety and completed with the defaults of the schema.
+type Constructor func(meta map[string]string, botRegistry botApp.Registry) Runner
+
+// Variety is a variety of workflow steps, what the `variety` of a workflow step refers to.
+type Variety struct {
+	Name   string
+	Schema wfs.Schema
+	New    Constructor
+}
+
+var varieties = make(map[string]Variety)
+
+// Register makes a variety of workflow steps available to workflows, it panics when its name is already taken.
+func Register(variety Variety) {
+	if _, ok := varieties[variety.Name]; ok {
+		panic(fmt.Sprintf("workflow step variety %s is already registered", variety.Name))
+	}
+
+	varieties[variety.Name] = variety
+}
+
+// Lookup finds a variety of workflow steps by name.
+func Lookup(name string) (Variety, bool) {
+	variety, ok := varieties[name]
+
+	return variety, ok
+}
+
+// Schemas returns the meta schema of every variety of workflow steps, by name.
+func Schemas() map[string]wfs.Schema {
+	schemas := make(map[string]wfs.Schema, len(varieties))
+	for name, variety := range varieties {
+		schemas[name] = variety.Schema
+	}
+
+	return schemas
+}
@@ -0,0 +1,25 @@
+package steps
+
+import "testing"
+
+func TestRegistry(t *testing.T) {
+	for _, name := range []string{"stdout", "stdOut", "postMatrixMessage", "httpRequest", "halt"} {
+		variety, ok := Lookup(name)
+		if !ok {
+			t.Errorf("%s: expected variety to be registered", name)
+			continue
+		}
+
+		if variety.Name != name || variety.New == nil || variety.Schema == nil {
+			t.Errorf("%s: unexpected variety %+v", name, variety)
+		}
+
+		if _, ok = Schemas()[name]; !ok {
+			t.Errorf("%s: expected schema of variety", name)
+		}
+	}
+
+	if _, ok := Lookup("sendFax"); ok {
+		t.Errorf("expected unknown variety not to be found")
+	}
+}
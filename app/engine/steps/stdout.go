@@ -1,19 +1,33 @@
 package steps
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
 
 	botApp "neurobot/app/bot"
+	"neurobot/model/payload"
+	wfs "neurobot/model/workflowstep"
 )
 
 var out io.Writer = os.Stdout
 
+func init() {
+	newRunner := func(meta map[string]string, botRegistry botApp.Registry) Runner {
+		return NewStdOutRunner(meta, botRegistry)
+	}
+
+	Register(Variety{Name: "stdout", Schema: wfs.Schema{}, New: newRunner})
+
+	// The variety used to be named stdOut, workflows which still use that name keep working
+	Register(Variety{Name: "stdOut", Schema: wfs.Schema{}, New: newRunner})
+}
+
 type stdoutWorkflowStepRunner struct{}
 
-func (runner stdoutWorkflowStepRunner) Run(p map[string]string) (map[string]string, error) {
-	msg := p["message"]
+func (runner stdoutWorkflowStepRunner) Run(ctx context.Context, p payload.Payload) (payload.Payload, error) {
+	msg := p.String("message")
 	if msg == "" {
 		msg = "[Empty line]"
 	}
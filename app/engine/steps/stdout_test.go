@@ -2,6 +2,8 @@ package steps
 
 import (
 	"bytes"
+	"context"
+	"neurobot/model/payload"
 	"testing"
 )
 
@@ -27,7 +29,7 @@ func TestStdoutWorkflowStep(t *testing.T) {
 
 	for _, table := range tables {
 		s := &stdoutWorkflowStepRunner{}
-		s.Run(map[string]string{"message": table.input})
+		s.Run(context.Background(), payload.Payload{"message": table.input})
 
 		got := out.(*bytes.Buffer).String()
 		if got != table.output+"\n" {
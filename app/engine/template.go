@@ -0,0 +1,178 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"neurobot/model/payload"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// templateFuncs are the helper functions available in templates of step meta.
+var templateFuncs = template.FuncMap{
+	"upper":          strings.ToUpper,
+	"lower":          strings.ToLower,
+	"default":        defaultValue,
+	"split":          split,
+	"join":           join,
+	"date":           formatDate,
+	"escapeMarkdown": escapeMarkdown,
+	"json":           toJSON,
+	"index":          index,
+}
+
+// renderMeta evaluates every meta value of a step as a template, with the payload available as `.payload`,
+// nested values being reached with their path, like `{{ .payload.pull_request.user.login }}`.
+// Using a key which isn't in the payload, like `{{ .payload.missing }}`, is an error.
+func renderMeta(meta map[string]string, p payload.Payload) (map[string]string, error) {
+	rendered := make(map[string]string, len(meta))
+	data := map[string]interface{}{"payload": map[string]interface{}(p)}
+
+	for key, value := range meta {
+		if !strings.Contains(value, "{{") {
+			rendered[key] = value
+			continue
+		}
+
+		tmpl, err := template.New(key).Funcs(templateFuncs).Option("missingkey=error").Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template in meta %s: %w", key, err)
+		}
+
+		var buffer bytes.Buffer
+		if err = tmpl.Execute(&buffer, data); err != nil {
+			return nil, fmt.Errorf("failed to render meta %s: %w", key, err)
+		}
+
+		rendered[key] = buffer.String()
+	}
+
+	return rendered, nil
+}
+
+// defaultValue returns the value, or fallback when it is empty, as in `{{ index .payload "user" | default "someone" }}`.
+func defaultValue(fallback string, value interface{}) string {
+	if formatted := payload.Format(value); formatted != "" {
+		return formatted
+	}
+
+	return fallback
+}
+
+// index gives the item of an object at a key, or of an array at a position, keys being followed one after the other,
+// as in `{{ index .payload.commits 0 "id" }}`. Unlike the index of templates, a missing key gives an empty value
+// rather than `<no value>`.
+func index(item interface{}, keys ...interface{}) (interface{}, error) {
+	value := reflect.ValueOf(item)
+	for _, key := range keys {
+		if value.Kind() == reflect.Interface {
+			value = value.Elem()
+		}
+
+		switch value.Kind() {
+		case reflect.Invalid:
+			return "", nil
+		case reflect.Map:
+			k := reflect.ValueOf(key)
+			if !k.IsValid() || !k.Type().AssignableTo(value.Type().Key()) {
+				return nil, fmt.Errorf("can't index %s with %v", value.Type(), key)
+			}
+			if value = value.MapIndex(k); !value.IsValid() {
+				return "", nil
+			}
+		case reflect.Slice, reflect.Array:
+			k := reflect.ValueOf(key)
+			if k.Kind() < reflect.Int || k.Kind() > reflect.Int64 {
+				return nil, fmt.Errorf("can't index %s with %v", value.Type(), key)
+			}
+			if i := k.Int(); i < 0 || i >= int64(value.Len()) {
+				return nil, fmt.Errorf("index %d out of range", i)
+			}
+			value = value.Index(int(k.Int()))
+		default:
+			return nil, fmt.Errorf("can't index %s", value.Type())
+		}
+	}
+
+	if !value.IsValid() || (value.Kind() == reflect.Interface && value.IsNil()) {
+		return "", nil
+	}
+
+	return value.Interface(), nil
+}
+
+// split splits a value on a separator, ignoring the whitespace around items, as in `{{ split "," .payload.tags }}`.
+func split(separator string, value interface{}) (items []string) {
+	for _, item := range strings.Split(payload.Format(value), separator) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return
+}
+
+// join joins values with a separator, values can be strings or lists of strings,
+// as in `{{ join ", " .payload.first .payload.second }}` or `{{ split "," .payload.tags | join " #" }}`.
+func join(separator string, values ...interface{}) string {
+	var items []string
+	for _, value := range values {
+		switch v := value.(type) {
+		case []string:
+			items = append(items, v...)
+		case []interface{}:
+			for _, item := range v {
+				items = append(items, payload.Format(item))
+			}
+		default:
+			items = append(items, payload.Format(v))
+		}
+	}
+
+	return strings.Join(items, separator)
+}
+
+// toJSON encodes a value as JSON, strings being quoted and escaped, so that it can be put in a JSON document,
+// as in `{"text": {{ json .payload.message }}}`.
+func toJSON(value interface{}) (string, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	return string(encoded), nil
+}
+
+// formatDate formats a time given as RFC 3339 or as Unix seconds, with a Go layout,
+// as in `{{ .payload.firedAt | date "Monday, January 2" }}`. An empty value stays empty.
+func formatDate(layout string, raw interface{}) (string, error) {
+	value := payload.Format(raw)
+	if value == "" {
+		return "", nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.Format(layout), nil
+	}
+
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC().Format(layout), nil
+	}
+
+	return "", fmt.Errorf("expected a RFC 3339 time or Unix seconds, got %q", value)
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", `*`, `\*`, `_`, `\_`, `{`, `\{`, `}`, `\}`, `[`, `\[`, `]`, `\]`,
+	`(`, `\(`, `)`, `\)`, `#`, `\#`, `+`, `\+`, `-`, `\-`, `.`, `\.`, `!`, `\!`, `|`, `\|`, `<`, `\<`, `>`, `\>`,
+)
+
+// escapeMarkdown escapes the characters which have a meaning in markdown, so that a value is posted as is,
+// as in `{{ .payload.title | escapeMarkdown }}`.
+func escapeMarkdown(value interface{}) string {
+	return markdownEscaper.Replace(payload.Format(value))
+}
@@ -0,0 +1,84 @@
+package engine
+
+import (
+	"encoding/json"
+	"neurobot/model/payload"
+	"reflect"
+	"testing"
+)
+
+func TestRenderMeta(t *testing.T) {
+	p := payload.Payload{
+		"user":        "alice",
+		"tags":        "a, b,c",
+		"firedAt":     "2022-05-13T09:00:00Z",
+		"title":       "*Big* news",
+		"user.login":  "bob",
+		"empty":       "",
+		"quote":       "say \"hi\"\n",
+		"unixSeconds": json.Number("1652432400"),
+		"pull_request": map[string]interface{}{
+			"number": json.Number("42"),
+			"merged": true,
+			"labels": []interface{}{"bug", "urgent"},
+			"user":   map[string]interface{}{"login": "carol"},
+		},
+	}
+
+	tables := []struct {
+		template string
+		expected string
+	}{
+		{template: "no template", expected: "no template"},
+		{template: "Hello {{ .payload.user }}", expected: "Hello alice"},
+		{template: "{{ .payload.user | upper }}", expected: "ALICE"},
+		{template: `{{ index .payload "missing" | default "someone" }}`, expected: "someone"},
+		{template: `{{ .payload.empty | default "nothing" }}`, expected: "nothing"},
+		{template: `{{ index .payload "user.login" }}`, expected: "bob"},
+		{template: `{{ split "," .payload.tags | join " #" }}`, expected: "a #b #c"},
+		{template: `{{ join ", " .payload.user .payload.empty "carol" }}`, expected: "alice, , carol"},
+		{template: `{{ .payload.firedAt | date "Jan 2 15:04" }}`, expected: "May 13 09:00"},
+		{template: `{{ .payload.unixSeconds | date "2006-01-02" }}`, expected: "2022-05-13"},
+		{template: `{{ .payload.title | escapeMarkdown }}`, expected: `\*Big\* news`},
+		{template: "{{ .payload.pull_request.user.login }}", expected: "carol"},
+		{template: "#{{ .payload.pull_request.number }}", expected: "#42"},
+		{template: `{{ if .payload.pull_request.merged }}merged{{ end }}`, expected: "merged"},
+		{template: `{{ index .payload.pull_request.labels 1 }}`, expected: "urgent"},
+		{template: `{{ join ", " .payload.pull_request.labels }}`, expected: "bug, urgent"},
+		{template: `{{ json .payload.pull_request.user }}`, expected: `{"login":"carol"}`},
+		{template: `{{ index .payload.pull_request "missing" | default "none" }}`, expected: "none"},
+		{template: `{{ index .payload "missing" }}`, expected: ""},
+		{template: `{{ index .payload "pull_request" "labels" 0 }}`, expected: "bug"},
+		{template: `{{ index .payload "missing" "deeper" }}`, expected: ""},
+		{template: `{"text": {{ json .payload.quote }}}`, expected: `{"text": "say \"hi\"\n"}`},
+		{template: `{{ json .payload.pull_request.number }}`, expected: "42"},
+	}
+
+	for _, table := range tables {
+		got, err := renderMeta(map[string]string{"message": table.template}, p)
+		if err != nil {
+			t.Errorf("%s: failed to render: %s", table.template, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, map[string]string{"message": table.expected}) {
+			t.Errorf("%s: expected %q, got %q", table.template, table.expected, got["message"])
+		}
+	}
+
+	invalid := []string{
+		"{{ .payload.missing }}",
+		"{{ .payload.pull_request.missing }}",
+		"{{ .payload.user",
+		"{{ unknown .payload.user }}",
+		`{{ .payload.user | date "2006" }}`,
+		`{{ index .payload.pull_request.labels 2 }}`,
+		`{{ index .payload.user 0 }}`,
+	}
+
+	for _, template := range invalid {
+		if _, err := renderMeta(map[string]string{"message": template}, p); err == nil {
+			t.Errorf("%s: rendering should fail", template)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package queue
+
+import (
+	"context"
+	"neurobot/model/run"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+)
+
+// pollInterval is how often idle workers look for queued runs, in case they weren't woken up.
+const pollInterval = 5 * time.Second
+
+// Queue runs workflows in the background, with a pool of workers. Runs are persisted in the history before being run,
+// so that the ones which were queued or running when the program stopped are run again when it starts: a run is
+// never lost, but it may happen more than once.
+type Queue interface {
+	// Enqueue persists a run as queued, for a worker to run it.
+	Enqueue(r *run.Run) error
+
+	// Start queues again the runs which were interrupted, and starts the workers.
+	Start() error
+
+	// Stop stops the workers, waiting for the runs in progress to finish until ctx is done. The context of the runs
+	// still in progress then is cancelled, and Stop waits for them to return.
+	Stop(ctx context.Context)
+}
+
+type queue struct {
+	runRepository run.Repository
+	workers       int
+	handler       func(ctx context.Context, r run.Run)
+	wake          chan struct{}
+	done          chan struct{}
+	ctx           context.Context // of the runs, cancelled when stopping
+	cancel        context.CancelFunc
+	running       sync.WaitGroup
+}
+
+// NewQueue makes a queue whose workers call handler for every run, which is responsible for finishing the run.
+// The context given to handler is cancelled when the queue is stopped.
+func NewQueue(runRepository run.Repository, workers int, handler func(ctx context.Context, r run.Run)) Queue {
+	if workers <= 0 {
+		log.Warnf("Invalid number of workers %d, defaulting to 1", workers)
+		workers = 1
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &queue{
+		runRepository: runRepository,
+		workers:       workers,
+		handler:       handler,
+		wake:          make(chan struct{}, workers),
+		done:          make(chan struct{}),
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+}
+
+func (q *queue) Enqueue(r *run.Run) error {
+	r.Status = run.StatusQueued
+	if err := q.runRepository.Save(r); err != nil {
+		return err
+	}
+
+	// Wake up an idle worker, if any, all of them being busy is fine as they look for queued runs when done
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+func (q *queue) Start() error {
+	if err := q.runRepository.Requeue(); err != nil {
+		return err
+	}
+
+	for i := 0; i < q.workers; i++ {
+		q.running.Add(1)
+		go q.work()
+	}
+
+	return nil
+}
+
+func (q *queue) Stop(ctx context.Context) {
+	close(q.done)
+
+	stopped := make(chan struct{})
+	go func() {
+		q.running.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		log.Warn("Cancelling workflow runs still in progress")
+	}
+
+	q.cancel()
+	<-stopped
+}
+
+func (q *queue) work() {
+	defer q.running.Done()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-q.done:
+			return
+		default:
+		}
+
+		r, found, err := q.runRepository.Claim()
+		if err != nil {
+			log.WithError(err).Error("Failed to claim queued run")
+		}
+
+		if found {
+			q.handler(q.ctx, r)
+			continue
+		}
+
+		select {
+		case <-q.done:
+			return
+		case <-q.wake:
+		case <-ticker.C:
+		}
+	}
+}
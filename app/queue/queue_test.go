@@ -0,0 +1,145 @@
+package queue
+
+import (
+	"context"
+	runApp "neurobot/app/run"
+	"neurobot/model/run"
+	"neurobot/resources/tests/database"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/upper/db/v4"
+)
+
+func TestQueue(t *testing.T) {
+	database.Test(func(session db.Session) {
+		runRepository := runApp.NewRepository(session)
+
+		// Runs which were queued, or interrupted while running, before a restart.
+		interrupted := run.Run{WorkflowID: 1, Trigger: "webhook", Status: run.StatusRunning, Attempts: 1, StartedAt: time.Now()}
+		queued := run.Run{WorkflowID: 2, Trigger: "webhook", Status: run.StatusQueued, StartedAt: time.Now()}
+		finished := run.Run{WorkflowID: 3, Trigger: "webhook", Status: run.StatusSucceeded, Attempts: 1, StartedAt: time.Now()}
+		for _, r := range []*run.Run{&interrupted, &queued, &finished} {
+			if err := runRepository.Save(r); err != nil {
+				t.Fatalf("failed to save run: %s", err)
+			}
+		}
+
+		var mutex sync.Mutex
+		handled := make(map[uint64]int) // attempts by workflow ID
+		done := make(chan struct{}, 10)
+
+		q := NewQueue(runRepository, 3, func(ctx context.Context, r run.Run) {
+			mutex.Lock()
+			handled[r.WorkflowID] = r.Attempts
+			mutex.Unlock()
+
+			r.Status = run.StatusSucceeded
+			r.FinishedAt = time.Now()
+			_ = runRepository.Save(&r)
+			done <- struct{}{}
+		})
+
+		if err := q.Start(); err != nil {
+			t.Fatalf("failed to start queue: %s", err)
+		}
+
+		for i := uint64(4); i <= 6; i++ {
+			if err := q.Enqueue(&run.Run{WorkflowID: i, Trigger: "schedule", StartedAt: time.Now()}); err != nil {
+				t.Fatalf("failed to enqueue run: %s", err)
+			}
+		}
+
+		for i := 0; i < 5; i++ {
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatalf("expected 5 runs to be handled, got %d", i)
+			}
+		}
+
+		q.Stop(context.Background())
+
+		var workflowIDs []int
+		for workflowID := range handled {
+			workflowIDs = append(workflowIDs, int(workflowID))
+		}
+		sort.Ints(workflowIDs)
+
+		if len(workflowIDs) != 5 || workflowIDs[0] != 1 || workflowIDs[1] != 2 || workflowIDs[4] != 6 {
+			t.Errorf("unexpected handled runs %v", workflowIDs)
+		}
+
+		if handled[1] != 2 || handled[2] != 1 {
+			t.Errorf("expected interrupted run to be on its second attempt, got %v", handled)
+		}
+
+		if _, found, _ := runRepository.Claim(); found {
+			t.Errorf("expected no run left in queue")
+		}
+	})
+}
+
+func TestStop(t *testing.T) {
+	tables := []struct {
+		name      string
+		duration  time.Duration // how long a run takes, unless cancelled
+		deadline  time.Duration // to stop the queue
+		cancelled bool
+	}{
+		{name: "drained", duration: 50 * time.Millisecond, deadline: time.Second, cancelled: false},
+		{name: "cancelled", duration: time.Minute, deadline: 50 * time.Millisecond, cancelled: true},
+	}
+
+	for _, table := range tables {
+		database.Test(func(session db.Session) {
+			runRepository := runApp.NewRepository(session)
+
+			started := make(chan struct{})
+			var cancelled bool
+			q := NewQueue(runRepository, 1, func(ctx context.Context, r run.Run) {
+				close(started)
+				select {
+				case <-ctx.Done():
+					cancelled = true
+				case <-time.After(table.duration):
+				}
+			})
+
+			if err := q.Start(); err != nil {
+				t.Fatalf("%s: failed to start queue: %s", table.name, err)
+			}
+
+			if err := q.Enqueue(&run.Run{WorkflowID: 1, Trigger: "webhook", StartedAt: time.Now()}); err != nil {
+				t.Fatalf("%s: failed to enqueue run: %s", table.name, err)
+			}
+
+			select {
+			case <-started:
+			case <-time.After(time.Second):
+				t.Fatalf("%s: expected run to be handled", table.name)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), table.deadline)
+			defer cancel()
+
+			stopped := make(chan struct{})
+			go func() {
+				q.Stop(ctx)
+				close(stopped)
+			}()
+
+			select {
+			case <-stopped:
+			case <-time.After(2 * time.Second):
+				t.Fatalf("%s: expected stopping to return", table.name)
+			}
+
+			if cancelled != table.cancelled {
+				t.Errorf("%s: expected run to be cancelled: %t, got %t", table.name, table.cancelled, cancelled)
+			}
+		})
+	}
+}
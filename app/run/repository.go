@@ -0,0 +1,276 @@
+package run
+
+import (
+	"encoding/json"
+	"errors"
+	"neurobot/model/payload"
+	model "neurobot/model/run"
+	"time"
+
+	"github.com/upper/db/v4"
+)
+
+const runTableName = "workflow_runs"
+const stepRunTableName = "workflow_step_runs"
+
+type runRow struct {
+	ID         uint64     `db:"id,omitempty"`
+	WorkflowID uint64     `db:"workflow_id"`
+	Trigger    string     `db:"trigger"`
+	Payload    string     `db:"payload"` // JSON encoded
+	Output     string     `db:"output"`  // JSON encoded
+	Status     string     `db:"status"`
+	Error      string     `db:"error"`
+	Attempts   int        `db:"attempts"`
+	StartedAt  time.Time  `db:"started_at"`
+	FinishedAt *time.Time `db:"finished_at"`
+}
+
+type stepRunRow struct {
+	ID         uint64    `db:"id,omitempty"`
+	RunID      uint64    `db:"run_id"`
+	StepID     uint64    `db:"step_id"`
+	Position   int       `db:"position"`
+	Name       string    `db:"name"`
+	Variety    string    `db:"variety"`
+	Status     string    `db:"status"`
+	Attempts   int       `db:"attempts"`
+	Output     string    `db:"output"` // JSON encoded
+	Error      string    `db:"error"`
+	StartedAt  time.Time `db:"started_at"`
+	DurationMs int64     `db:"duration_ms"`
+}
+
+type repository struct {
+	collection      db.Collection
+	collectionSteps db.Collection
+}
+
+func NewRepository(session db.Session) model.Repository {
+	return &repository{
+		collection:      session.Collection(runTableName),
+		collectionSteps: session.Collection(stepRunTableName),
+	}
+}
+
+func (repository *repository) Save(run *model.Run) (err error) {
+	row, err := toRunRow(*run)
+	if err != nil {
+		return
+	}
+
+	if run.ID > 0 {
+		return repository.collection.Find(db.Cond{"id": run.ID}).Update(row)
+	}
+
+	result, err := repository.collection.Insert(row)
+	if err == nil {
+		run.ID = uint64(result.ID().(int64))
+	}
+
+	return
+}
+
+func (repository *repository) SaveStep(step *model.StepRun) (err error) {
+	output, err := json.Marshal(step.Output)
+	if err != nil {
+		return
+	}
+
+	row := stepRunRow{
+		ID:         step.ID,
+		RunID:      step.RunID,
+		StepID:     step.StepID,
+		Position:   step.Position,
+		Name:       step.Name,
+		Variety:    step.Variety,
+		Status:     step.Status,
+		Attempts:   step.Attempts,
+		Output:     string(output),
+		Error:      step.Error,
+		StartedAt:  step.StartedAt.UTC(),
+		DurationMs: step.Duration.Milliseconds(),
+	}
+
+	if step.ID > 0 {
+		return repository.collectionSteps.Find(db.Cond{"id": step.ID}).Update(row)
+	}
+
+	result, err := repository.collectionSteps.Insert(row)
+	if err == nil {
+		step.ID = uint64(result.ID().(int64))
+	}
+
+	return
+}
+
+func (repository *repository) FindByID(ID uint64) (run model.Run, err error) {
+	var row runRow
+	if err = repository.collection.Find(db.Cond{"id": ID}).One(&row); err != nil {
+		return
+	}
+
+	return fromRunRow(row)
+}
+
+func (repository *repository) FindByWorkflowID(ID uint64, since time.Time) (runs []model.Run, err error) {
+	var rows []runRow
+	result := repository.collection.Find(db.Cond{"workflow_id": ID, "started_at >=": since.UTC()}).OrderBy("-started_at", "-id")
+	if err = result.All(&rows); err != nil {
+		return
+	}
+
+	for _, row := range rows {
+		run, err := fromRunRow(row)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+
+	return
+}
+
+func (repository *repository) FindSteps(runID uint64) (steps []model.StepRun, err error) {
+	var rows []stepRunRow
+	result := repository.collectionSteps.Find(db.Cond{"run_id": runID}).OrderBy("id")
+	if err = result.All(&rows); err != nil {
+		return
+	}
+
+	for _, row := range rows {
+		step := model.StepRun{
+			ID:        row.ID,
+			RunID:     row.RunID,
+			StepID:    row.StepID,
+			Position:  row.Position,
+			Name:      row.Name,
+			Variety:   row.Variety,
+			Status:    row.Status,
+			Attempts:  row.Attempts,
+			Error:     row.Error,
+			StartedAt: row.StartedAt,
+			Duration:  time.Duration(row.DurationMs) * time.Millisecond,
+		}
+
+		if step.Output, err = decodePayload(row.Output); err != nil {
+			return nil, err
+		}
+
+		steps = append(steps, step)
+	}
+
+	return
+}
+
+func (repository *repository) Claim() (run model.Run, found bool, err error) {
+	for {
+		var row runRow
+		err = repository.collection.Find(db.Cond{"status": model.StatusQueued}).OrderBy("id").One(&row)
+		if errors.Is(err, db.ErrNoMoreRows) {
+			return run, false, nil
+		}
+		if err != nil {
+			return
+		}
+
+		// The run keeps its start time, when it was queued, however late and however many times it is claimed
+		result, err := repository.collection.Session().SQL().
+			Update(runTableName).
+			Set("status", model.StatusRunning, "attempts", db.Raw("attempts + 1")).
+			Where("id = ? AND status = ?", row.ID, model.StatusQueued).
+			Exec()
+		if err != nil {
+			return run, false, err
+		}
+
+		// Only the one which changed the status claimed the run, others try the next one
+		claimed, err := result.RowsAffected()
+		if err != nil {
+			return run, false, err
+		}
+		if claimed == 0 {
+			continue
+		}
+
+		row.Status = model.StatusRunning
+		row.Attempts++
+		run, err = fromRunRow(row)
+
+		return run, err == nil, err
+	}
+}
+
+func (repository *repository) Requeue() error {
+	_, err := repository.collection.Session().SQL().
+		Update(runTableName).
+		Set("status", model.StatusQueued).
+		Where("status = ?", model.StatusRunning).
+		Exec()
+
+	return err
+}
+
+func toRunRow(run model.Run) (row runRow, err error) {
+	encoded, err := json.Marshal(run.Payload)
+	if err != nil {
+		return
+	}
+
+	output, err := json.Marshal(run.Output)
+	if err != nil {
+		return
+	}
+
+	row = runRow{
+		ID:         run.ID,
+		WorkflowID: run.WorkflowID,
+		Trigger:    run.Trigger,
+		Payload:    string(encoded),
+		Output:     string(output),
+		Status:     run.Status,
+		Error:      run.Error,
+		Attempts:   run.Attempts,
+		StartedAt:  run.StartedAt.UTC(),
+	}
+
+	if !run.FinishedAt.IsZero() {
+		finishedAt := run.FinishedAt.UTC()
+		row.FinishedAt = &finishedAt
+	}
+
+	return
+}
+
+func fromRunRow(row runRow) (run model.Run, err error) {
+	run = model.Run{
+		ID:         row.ID,
+		WorkflowID: row.WorkflowID,
+		Trigger:    row.Trigger,
+		Status:     row.Status,
+		Error:      row.Error,
+		Attempts:   row.Attempts,
+		StartedAt:  row.StartedAt,
+	}
+
+	if row.FinishedAt != nil {
+		run.FinishedAt = *row.FinishedAt
+	}
+
+	if run.Payload, err = decodePayload(row.Payload); err != nil {
+		return
+	}
+
+	run.Output, err = decodePayload(row.Output)
+
+	return
+}
+
+// decodePayload decodes a payload the way it was stored, keeping numbers as they were.
+func decodePayload(data string) (payload.Payload, error) {
+	if data == "" || data == "null" {
+		return nil, nil
+	}
+
+	return payload.Decode([]byte(data))
+}
@@ -0,0 +1,127 @@
+package run
+
+import (
+	"encoding/json"
+	"neurobot/model/payload"
+	model "neurobot/model/run"
+	"neurobot/resources/tests/database"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/upper/db/v4"
+)
+
+func TestSaveAndFind(t *testing.T) {
+	database.Test(func(session db.Session) {
+		repository := NewRepository(session)
+		startedAt := time.Date(2022, 5, 13, 9, 0, 0, 0, time.UTC)
+
+		run := model.Run{
+			WorkflowID: 1,
+			Trigger:    "webhook",
+			Payload: payload.Payload{
+				"message": "hello",
+				"issue":   map[string]interface{}{"number": json.Number("42"), "open": true, "labels": []interface{}{"bug"}},
+			},
+			Status:    model.StatusRunning,
+			StartedAt: startedAt,
+		}
+		if err := repository.Save(&run); err != nil {
+			t.Fatalf("failed to insert run: %s", err)
+		}
+
+		got, err := repository.FindByID(run.ID)
+		if err != nil {
+			t.Fatalf("failed to find run: %s", err)
+		}
+		if !reflect.DeepEqual(got, run) {
+			t.Errorf("unexpected run\n%+v\n%+v", got, run)
+		}
+
+		step := model.StepRun{
+			RunID:     run.ID,
+			StepID:    3,
+			Position:  1,
+			Name:      "Post message",
+			Variety:   "postMatrixMessage",
+			Status:    model.StepStatusFailed,
+			Attempts:  2,
+			Output:    payload.Payload{"message": "hello"},
+			Error:     "no room to post",
+			StartedAt: startedAt,
+			Duration:  1500 * time.Millisecond,
+		}
+		if err = repository.SaveStep(&step); err != nil {
+			t.Fatalf("failed to insert step run: %s", err)
+		}
+
+		steps, err := repository.FindSteps(run.ID)
+		if err != nil {
+			t.Fatalf("failed to find step runs: %s", err)
+		}
+		if !reflect.DeepEqual(steps, []model.StepRun{step}) {
+			t.Errorf("unexpected step runs\n%+v\n%+v", steps, step)
+		}
+
+		run.Status = model.StatusFailed
+		run.Error = "workflow failed"
+		run.FinishedAt = startedAt.Add(2 * time.Second)
+		if err = repository.Save(&run); err != nil {
+			t.Fatalf("failed to update run: %s", err)
+		}
+
+		later := model.Run{WorkflowID: 1, Trigger: "schedule", Status: model.StatusRunning, StartedAt: startedAt.Add(time.Hour)}
+		other := model.Run{WorkflowID: 2, Trigger: "schedule", Status: model.StatusRunning, StartedAt: startedAt.Add(time.Hour)}
+		for _, r := range []*model.Run{&later, &other} {
+			if err = repository.Save(r); err != nil {
+				t.Fatalf("failed to insert run: %s", err)
+			}
+		}
+
+		runs, err := repository.FindByWorkflowID(1, startedAt)
+		if err != nil {
+			t.Fatalf("failed to find runs: %s", err)
+		}
+		if !reflect.DeepEqual(runs, []model.Run{later, run}) {
+			t.Errorf("unexpected runs\n%+v\n%+v", runs, []model.Run{later, run})
+		}
+
+		runs, err = repository.FindByWorkflowID(1, startedAt.Add(time.Minute))
+		if err != nil || len(runs) != 1 || runs[0].ID != later.ID {
+			t.Errorf("expected only the later run, got %+v (%v)", runs, err)
+		}
+	})
+}
+
+func TestClaim(t *testing.T) {
+	database.Test(func(session db.Session) {
+		repository := NewRepository(session)
+		startedAt := time.Date(2022, 5, 13, 9, 0, 0, 0, time.UTC)
+
+		queued := model.Run{WorkflowID: 1, Trigger: "webhook", Status: model.StatusQueued, StartedAt: startedAt}
+		if err := repository.Save(&queued); err != nil {
+			t.Fatalf("failed to insert run: %s", err)
+		}
+
+		for attempt := 1; attempt <= 2; attempt++ {
+			run, found, err := repository.Claim()
+			if err != nil || !found {
+				t.Fatalf("expected the queued run to be claimed, got %v (%v)", found, err)
+			}
+			if run.ID != queued.ID || run.Status != model.StatusRunning || run.Attempts != attempt || !run.StartedAt.Equal(startedAt) {
+				t.Errorf("claimed run should keep when it was queued, got %+v", run)
+			}
+
+			// Interrupted by a restart
+			if err = repository.Requeue(); err != nil {
+				t.Fatalf("failed to requeue: %s", err)
+			}
+		}
+
+		runs, _ := repository.FindByWorkflowID(1, startedAt)
+		if len(runs) != 1 || !runs[0].StartedAt.Equal(startedAt) {
+			t.Errorf("claimed run should keep when it was queued, got %+v", runs)
+		}
+	})
+}
@@ -1,9 +1,11 @@
 package afk_notifier
 
 import (
+	"context"
 	r "neurobot/app/runner"
 	"neurobot/infrastructure/matrix"
 	m "neurobot/model/message"
+	"neurobot/model/payload"
 	"neurobot/model/room"
 	"neurobot/model/workflow"
 )
@@ -16,13 +18,13 @@ func NewRunner(matrixClient matrix.Client) r.Runner {
 	return &runner{matrixClient: matrixClient}
 }
 
-func (r *runner) Run(workflow workflow.Workflow, payload map[string]string) error {
-	roomID, err := room.NewID(payload["room"])
+func (r *runner) Run(ctx context.Context, workflow workflow.Workflow, p payload.Payload) (payload.Payload, error) {
+	roomID, err := room.NewID(p.String("room"))
 	if err != nil {
-		return err
+		return p, err
 	}
 
-	message := m.NewMarkdownMessage(payload["message"])
+	message := m.NewMarkdownMessage(p.String("message"))
 
-	return r.matrixClient.SendMessage(roomID, message)
+	return p, r.matrixClient.SendMessage(ctx, roomID, message)
 }
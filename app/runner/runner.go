@@ -1,10 +1,13 @@
 package runner
 
 import (
+	"context"
+	"neurobot/model/payload"
 	"neurobot/model/workflow"
 )
 
-// Runner runs a Workflow with an incoming payload.
+// Runner runs a Workflow with an incoming payload, giving up when the context is done. It returns the payload once the
+// workflow ran, which callers waiting for the workflow are given.
 type Runner interface {
-	Run(workflow workflow.Workflow, payload map[string]string) error
+	Run(ctx context.Context, workflow workflow.Workflow, p payload.Payload) (payload.Payload, error)
 }
@@ -0,0 +1,246 @@
+package trigger
+
+import (
+	"fmt"
+	"neurobot/app/bot"
+	"neurobot/infrastructure/event"
+	"neurobot/infrastructure/matrix"
+	"neurobot/model/message"
+	"neurobot/model/payload"
+	"neurobot/model/room"
+	model "neurobot/model/trigger"
+	w "neurobot/model/workflow"
+	"strings"
+	"sync"
+
+	"github.com/apex/log"
+)
+
+// CommandVariety is the variety of triggers that start a workflow when a chat command such as `!afk back at 3pm`
+// is sent to a room a bot is a member of.
+const CommandVariety = "matrixCommand"
+
+// reservedArguments are the names of what the payload of a command holds besides its arguments, which arguments can't
+// be named after.
+var reservedArguments = []string{"command", "args", "room", "sender"}
+
+// CommandListener starts workflows which have a matrixCommand trigger, when their command is sent in a room.
+// Listening for a command means watching the messages received by the bots it listens as.
+type CommandListener interface {
+	Listener
+}
+
+type commandListener struct {
+	triggerRepository  model.Repository
+	workflowRepository w.Repository
+	botRegistry        bot.Registry
+	eventBus           event.Bus
+	mutex              sync.RWMutex
+	commands           map[string][]command     // by name of the bot they listen as
+	clients            map[string]matrix.Client // by bot name, the clients whose messages are watched
+}
+
+type command struct {
+	workflow  w.Workflow
+	prefix    string
+	arguments []argument
+	rooms     map[string]bool // empty means any room
+}
+
+type argument struct {
+	name     string
+	optional bool
+}
+
+func NewCommandListener(
+	triggerRepository model.Repository,
+	workflowRepository w.Repository,
+	botRegistry bot.Registry,
+	eventBus event.Bus,
+) CommandListener {
+	return &commandListener{
+		triggerRepository:  triggerRepository,
+		workflowRepository: workflowRepository,
+		botRegistry:        botRegistry,
+		eventBus:           eventBus,
+		clients:            make(map[string]matrix.Client),
+	}
+}
+
+func (l *commandListener) Start() error {
+	return start(l, l.triggerRepository, l.workflowRepository)
+}
+
+// Load watches the messages of the bots the commands listen as right away, but only handles the commands once swapped.
+func (l *commandListener) Load(triggerRepository model.Repository, workflowRepository w.Repository) (func(), error) {
+	triggers, err := findActive(triggerRepository, workflowRepository, CommandVariety)
+	if err != nil {
+		return nil, err
+	}
+
+	commandsByBot := make(map[string][]command)
+	for _, wt := range triggers {
+		c, err := newCommand(wt.workflow, wt.trigger)
+		if err != nil {
+			return nil, fmt.Errorf("invalid command trigger for workflow %s: %w", wt.workflow.Identifier, err)
+		}
+
+		for _, botName := range splitList(wt.trigger.Meta["asBot"], true) {
+			commandsByBot[botName] = append(commandsByBot[botName], c)
+		}
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for botName := range commandsByBot {
+		client, err := getClient(l.botRegistry, botName)
+		if err != nil {
+			return nil, err
+		}
+
+		// Messages of a client are watched once, whatever the commands
+		if l.clients[botName] == client {
+			continue
+		}
+		if err = client.OnMessage(l.handle(botName, client)); err != nil {
+			return nil, err
+		}
+		l.clients[botName] = client
+	}
+
+	return func() {
+		l.mutex.Lock()
+		l.commands = commandsByBot
+		l.mutex.Unlock()
+	}, nil
+}
+
+func (l *commandListener) Stop() {
+	l.mutex.Lock()
+	l.commands = nil
+	l.mutex.Unlock()
+}
+
+// handle handles what the client of a bot receives, until the bot logs in again with another client.
+func (l *commandListener) handle(botName string, client matrix.Client) func(roomID room.ID, sender string, message message.Message) {
+	return func(roomID room.ID, sender string, message message.Message) {
+		l.mutex.RLock()
+		commands := l.commands[botName]
+		current := l.clients[botName] == client
+		l.mutex.RUnlock()
+
+		if !current {
+			return
+		}
+
+		for _, c := range commands {
+			if !c.acceptsRoom(roomID) {
+				continue
+			}
+
+			p, matched, err := c.parse(message.String())
+			if !matched {
+				continue
+			}
+
+			if err != nil {
+				log.WithError(err).WithFields(log.Fields{
+					"workflow": c.workflow.Identifier,
+					"room":     roomID.ID(),
+					"sender":   sender,
+				}).Warn("Ignoring invalid command")
+				continue
+			}
+
+			p["room"] = roomID.ID()
+			p["sender"] = sender
+
+			publish(l.eventBus, c.workflow, CommandVariety, p)
+		}
+	}
+}
+
+func newCommand(workflow w.Workflow, trigger model.Trigger) (c command, err error) {
+	c.workflow = workflow
+	c.prefix = strings.TrimSpace(trigger.Meta["command"])
+	if c.prefix == "" || strings.ContainsAny(c.prefix, " \t\n") {
+		return c, fmt.Errorf("command must be a single word, got %q", c.prefix)
+	}
+
+	// Arguments are declared as a list of names, optional ones are suffixed with a question mark.
+	for _, name := range splitList(trigger.Meta["arguments"], false) {
+		arg := argument{name: strings.TrimSuffix(name, "?"), optional: strings.HasSuffix(name, "?")}
+		if contains(reservedArguments, arg.name) {
+			return c, fmt.Errorf("argument must not be named %s, which is reserved for the command itself", arg.name)
+		}
+		if len(c.arguments) > 0 && c.arguments[len(c.arguments)-1].optional && !arg.optional {
+			return c, fmt.Errorf("required argument %s must not follow an optional argument", arg.name)
+		}
+
+		c.arguments = append(c.arguments, arg)
+	}
+
+	c.rooms, err = parseRooms(trigger.Meta["rooms"])
+
+	return
+}
+
+func (c command) acceptsRoom(roomID room.ID) bool {
+	return len(c.rooms) == 0 || c.rooms[roomID.ID()]
+}
+
+// parse tells whether a message invokes the command, and if so, returns the payload holding the command,
+// the raw arguments and each declared argument by name. Arguments are separated by whitespace and can be
+// double-quoted, the last declared argument receives the remainder of the message.
+func (c command) parse(text string) (p payload.Payload, matched bool, err error) {
+	name, rest := nextWord(strings.TrimSpace(text))
+	if !strings.EqualFold(name, c.prefix) {
+		return nil, false, nil
+	}
+
+	p = payload.Payload{
+		"command": c.prefix,
+		"args":    rest,
+	}
+
+	for i, arg := range c.arguments {
+		var value string
+		if i == len(c.arguments)-1 {
+			value = unquote(rest)
+		} else {
+			value, rest = nextWord(rest)
+		}
+
+		if value == "" && !arg.optional {
+			return p, true, fmt.Errorf("missing argument %s for command %s", arg.name, c.prefix)
+		}
+
+		p[arg.name] = value
+	}
+
+	return p, true, nil
+}
+
+// nextWord splits the first, possibly double-quoted, word from the rest of the text.
+func nextWord(text string) (word string, rest string) {
+	if strings.HasPrefix(text, `"`) {
+		if end := strings.Index(text[1:], `"`); end >= 0 {
+			return text[1 : end+1], strings.TrimSpace(text[end+2:])
+		}
+	}
+
+	if i := strings.IndexAny(text, " \t\n"); i >= 0 {
+		return text[:i], strings.TrimSpace(text[i:])
+	}
+
+	return text, ""
+}
+
+func unquote(text string) string {
+	if len(text) >= 2 && strings.HasPrefix(text, `"`) && strings.HasSuffix(text, `"`) {
+		return text[1 : len(text)-1]
+	}
+
+	return text
+}
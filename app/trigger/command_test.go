@@ -0,0 +1,155 @@
+package trigger
+
+import (
+	"neurobot/model/message"
+	"neurobot/model/payload"
+	"neurobot/model/room"
+	model "neurobot/model/trigger"
+	w "neurobot/model/workflow"
+	"reflect"
+	"testing"
+)
+
+func TestNewCommand(t *testing.T) {
+	tables := []struct {
+		meta  map[string]string
+		valid bool
+	}{
+		{meta: map[string]string{"command": "!afk"}, valid: true},
+		{meta: map[string]string{"command": "!afk", "arguments": "message?", "rooms": "!foo:matrix.test"}, valid: true},
+		{meta: map[string]string{}, valid: false},
+		{meta: map[string]string{"command": "!a fk"}, valid: false},
+		{meta: map[string]string{"command": "!afk", "arguments": "until?, message"}, valid: false},
+		{meta: map[string]string{"command": "!afk", "arguments": "room, message"}, valid: false},
+		{meta: map[string]string{"command": "!afk", "arguments": "sender?"}, valid: false},
+		{meta: map[string]string{"command": "!afk", "rooms": "foo"}, valid: false},
+	}
+
+	for _, table := range tables {
+		_, err := newCommand(w.Workflow{}, model.Trigger{Variety: CommandVariety, Meta: table.meta})
+		if table.valid && err != nil {
+			t.Errorf("command %+v should be valid, got: %s", table.meta, err)
+		}
+		if !table.valid && err == nil {
+			t.Errorf("command %+v should not be valid", table.meta)
+		}
+	}
+}
+
+func TestParseCommand(t *testing.T) {
+	c, err := newCommand(w.Workflow{}, model.Trigger{
+		Variety: CommandVariety,
+		Meta: map[string]string{
+			"command":   "!polyglots",
+			"arguments": "language, note?",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tables := []struct {
+		text     string
+		matched  bool
+		valid    bool
+		expected payload.Payload
+	}{
+		{
+			text:    "hello !polyglots",
+			matched: false,
+		},
+		{
+			text:    "!polyglotsx spanish",
+			matched: false,
+		},
+		{
+			text:    "!polyglots",
+			matched: true,
+			valid:   false,
+		},
+		{
+			text:    "!polyglots spanish",
+			matched: true,
+			valid:   true,
+			expected: payload.Payload{
+				"command":  "!polyglots",
+				"args":     "spanish",
+				"language": "spanish",
+				"note":     "",
+			},
+		},
+		{
+			text:    `!Polyglots "brazilian portuguese" need a review  today`,
+			matched: true,
+			valid:   true,
+			expected: payload.Payload{
+				"command":  "!polyglots",
+				"args":     `"brazilian portuguese" need a review  today`,
+				"language": "brazilian portuguese",
+				"note":     "need a review  today",
+			},
+		},
+	}
+
+	for _, table := range tables {
+		p, matched, err := c.parse(table.text)
+		if matched != table.matched {
+			t.Errorf("%q: expected matched to be %t", table.text, table.matched)
+			continue
+		}
+		if !matched {
+			continue
+		}
+		if table.valid != (err == nil) {
+			t.Errorf("%q: expected valid to be %t, got error: %v", table.text, table.valid, err)
+			continue
+		}
+		if table.valid && !reflect.DeepEqual(p, table.expected) {
+			t.Errorf("%q: unexpected payload\n%+v\n%+v", table.text, p, table.expected)
+		}
+	}
+}
+
+func TestHandleCommand(t *testing.T) {
+	bus, got := collectTriggers()
+	l := &commandListener{eventBus: bus}
+
+	c, _ := newCommand(w.Workflow{Identifier: "afk_notifier"}, model.Trigger{
+		Variety: CommandVariety,
+		Meta: map[string]string{
+			"command":   "!afk",
+			"arguments": "message",
+			"rooms":     "!foo:matrix.test",
+		},
+	})
+	l.commands = map[string][]command{"neurobot": {c}}
+	handle := l.handle("neurobot", nil)
+
+	foo, _ := room.NewID("!foo:matrix.test")
+	bar, _ := room.NewID("!bar:matrix.test")
+
+	handle(bar, "@alice:matrix.test", message.NewPlainTextMessage("!afk back at 3pm"))
+	handle(foo, "@alice:matrix.test", message.NewPlainTextMessage("!afk"))
+	handle(foo, "@alice:matrix.test", message.NewPlainTextMessage("!afk back at 3pm"))
+
+	if len(*got) != 1 {
+		t.Fatalf("expected 1 trigger, got %d", len(*got))
+	}
+
+	expected := payload.Payload{
+		"command": "!afk",
+		"args":    "back at 3pm",
+		"message": "back at 3pm",
+		"room":    "!foo:matrix.test",
+		"sender":  "@alice:matrix.test",
+	}
+
+	trigger := (*got)[0]
+	if trigger.WorkflowIdentifier != "afk_notifier" || trigger.Variety != CommandVariety {
+		t.Errorf("unexpected trigger %+v", trigger)
+	}
+
+	if !reflect.DeepEqual(trigger.Payload, expected) {
+		t.Errorf("unexpected payload\n%+v\n%+v", trigger.Payload, expected)
+	}
+}
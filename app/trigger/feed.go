@@ -0,0 +1,214 @@
+package trigger
+
+import (
+	"encoding/json"
+	"fmt"
+	netHttp "net/http"
+	"net/url"
+	"neurobot/infrastructure/event"
+	"neurobot/infrastructure/feed"
+	"neurobot/infrastructure/http"
+	"neurobot/model/payload"
+	model "neurobot/model/trigger"
+	w "neurobot/model/workflow"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+)
+
+// FeedVariety is the variety of triggers that start a workflow for every new entry of an RSS or Atom feed.
+const FeedVariety = "feed"
+
+// FeedListener starts workflows which have a feed trigger, when new entries are published in the feed.
+// Listening for a feed trigger means polling its feed at its interval.
+type FeedListener interface {
+	Listener
+}
+
+type feedListener struct {
+	triggerRepository  model.Repository
+	stateRepository    model.StateRepository
+	workflowRepository w.Repository
+	eventBus           event.Bus
+	mutex              sync.Mutex
+	pollers            []http.Poller
+	running            sync.WaitGroup // the pollers which didn't stop running yet
+}
+
+type feedWorkflow struct {
+	workflow w.Workflow
+	url      *url.URL
+	interval time.Duration
+	headers  netHttp.Header
+	stateKey string // under which the GUIDs of the entries seen are stored
+}
+
+func NewFeedListener(
+	triggerRepository model.Repository,
+	stateRepository model.StateRepository,
+	workflowRepository w.Repository,
+	eventBus event.Bus,
+) FeedListener {
+	return &feedListener{
+		triggerRepository:  triggerRepository,
+		stateRepository:    stateRepository,
+		workflowRepository: workflowRepository,
+		eventBus:           eventBus,
+	}
+}
+
+func (l *feedListener) Start() error {
+	return start(l, l.triggerRepository, l.workflowRepository)
+}
+
+func (l *feedListener) Load(triggerRepository model.Repository, workflowRepository w.Repository) (func(), error) {
+	triggers, err := findActive(triggerRepository, workflowRepository, FeedVariety)
+	if err != nil {
+		return nil, err
+	}
+
+	var pollers []http.Poller
+	for _, wt := range triggers {
+		fw, err := newFeedWorkflow(wt.workflow, wt.trigger)
+		if err != nil {
+			return nil, fmt.Errorf("invalid feed trigger for workflow %s: %w", wt.workflow.Identifier, err)
+		}
+
+		pollers = append(pollers, http.NewHttpPoller(fw.interval, fw.url, fw.headers, func(body []byte) {
+			l.handle(fw, body)
+		}))
+	}
+
+	return func() {
+		// The previous pollers are done handling what they polled once stopped, so they can't overwrite the state
+		l.Stop()
+
+		l.mutex.Lock()
+		l.pollers = pollers
+		l.mutex.Unlock()
+
+		l.running.Add(len(pollers))
+		for _, poller := range pollers {
+			go func(poller http.Poller) {
+				defer l.running.Done()
+				poller.Run()
+			}(poller)
+		}
+	}, nil
+}
+
+// Stop stops the pollers, and waits for them to be done with what they polled.
+func (l *feedListener) Stop() {
+	l.mutex.Lock()
+	for _, poller := range l.pollers {
+		poller.Stop()
+	}
+	l.pollers = nil
+	l.mutex.Unlock()
+
+	l.running.Wait()
+}
+
+func newFeedWorkflow(workflow w.Workflow, trigger model.Trigger) (fw feedWorkflow, err error) {
+	fw.workflow = workflow
+
+	if fw.url, err = parsePollURL(trigger.Meta["url"]); err != nil {
+		return
+	}
+
+	fw.interval = 15 * time.Minute
+	if trigger.Meta["interval"] != "" {
+		if fw.interval, err = time.ParseDuration(trigger.Meta["interval"]); err != nil {
+			return
+		}
+	}
+
+	if fw.headers, err = http.ParseHeaders(trigger.Meta["headers"]); err != nil {
+		return
+	}
+
+	fw.stateKey = fmt.Sprintf("feed:%s", fw.url)
+
+	return
+}
+
+// handle starts the workflow for every entry which wasn't in the feed when it was previously polled, oldest first.
+// Nothing is started on the very first poll, as everything would be new.
+func (l *feedListener) handle(fw feedWorkflow, body []byte) {
+	logger := log.WithFields(log.Fields{"workflow": fw.workflow.Identifier, "url": fw.url.String()})
+
+	items, err := feed.Parse(body)
+	if err != nil {
+		logger.WithError(err).Error("Failed to parse feed")
+		return
+	}
+
+	seen, err := l.loadSeen(fw)
+	if err != nil {
+		logger.WithError(err).Error("Failed to load seen feed entries")
+		return
+	}
+
+	// Feeds list their newest entries first.
+	var guids []string
+	for i := len(items) - 1; i >= 0; i-- {
+		item := items[i]
+		guids = append(guids, item.GUID)
+
+		if seen == nil || seen[item.GUID] {
+			continue
+		}
+
+		p := payload.Payload{
+			"guid":      item.GUID,
+			"title":     item.Title,
+			"link":      item.Link,
+			"author":    item.Author,
+			"summary":   item.Summary,
+			"published": "",
+		}
+		if !item.Published.IsZero() {
+			p["published"] = item.Published.Format(time.RFC3339)
+		}
+
+		publish(l.eventBus, fw.workflow, FeedVariety, p)
+	}
+
+	if err = l.saveSeen(fw, guids); err != nil {
+		logger.WithError(err).Error("Failed to save seen feed entries")
+	}
+}
+
+// loadSeen returns the GUIDs of the entries seen in the previous poll, nil when it never polled before.
+func (l *feedListener) loadSeen(fw feedWorkflow) (map[string]bool, error) {
+	value, err := l.stateRepository.Get(fw.workflow.ID, fw.stateKey)
+	if err != nil || value == "" {
+		return nil, err
+	}
+
+	var guids []string
+	if err = json.Unmarshal([]byte(value), &guids); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	for _, guid := range guids {
+		seen[guid] = true
+	}
+
+	return seen, nil
+}
+
+func (l *feedListener) saveSeen(fw feedWorkflow, guids []string) error {
+	if guids == nil {
+		guids = []string{}
+	}
+
+	value, err := json.Marshal(guids)
+	if err != nil {
+		return err
+	}
+
+	return l.stateRepository.Set(fw.workflow.ID, fw.stateKey, string(value))
+}
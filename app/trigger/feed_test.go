@@ -0,0 +1,167 @@
+package trigger
+
+import (
+	"fmt"
+	netHttp "net/http"
+	"net/http/httptest"
+	"neurobot/app/workflow"
+	"neurobot/infrastructure/http"
+	"neurobot/model/payload"
+	model "neurobot/model/trigger"
+	w "neurobot/model/workflow"
+	"neurobot/resources/tests/database"
+	"neurobot/resources/tests/fixtures"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/upper/db/v4"
+)
+
+func TestHandleFeed(t *testing.T) {
+	entries := []string{
+		`<item><guid>1</guid><title>First</title><link>https://example.com/1</link></item>`,
+	}
+
+	server := httptest.NewServer(netHttp.HandlerFunc(func(w netHttp.ResponseWriter, r *netHttp.Request) {
+		fmt.Fprintf(w, `<rss version="2.0"><channel>%s</channel></rss>`, strings.Join(entries, ""))
+	}))
+	defer server.Close()
+
+	database.Test(func(session db.Session) {
+		bus, got := collectTriggers()
+		l := &feedListener{
+			stateRepository: NewStateRepository(session),
+			eventBus:        bus,
+		}
+
+		fw, err := newFeedWorkflow(w.Workflow{ID: 1, Identifier: "blog"}, model.Trigger{
+			Variety: FeedVariety,
+			Meta:    map[string]string{"url": server.URL},
+		})
+		if err != nil {
+			t.Fatalf("feed trigger should be valid, got: %s", err)
+		}
+
+		poller := http.NewHttpPoller(fw.interval, fw.url, fw.headers, nil)
+		poll := func() {
+			body, err := poller.Poll()
+			if err != nil {
+				t.Fatalf("failed to poll feed: %s", err)
+			}
+			l.handle(fw, body)
+		}
+
+		// First poll only remembers what was seen.
+		poll()
+		if len(*got) != 0 {
+			t.Fatalf("expected no triggers on first poll, got %d", len(*got))
+		}
+
+		// Newest entries come first in feeds.
+		entries = append([]string{
+			`<item><guid>3</guid><title>Third</title><author>alice@example.com</author><description>Summary</description><pubDate>Tue, 10 May 2022 10:00:00 +0000</pubDate></item>`,
+			`<item><guid>2</guid><title>Second</title></item>`,
+		}, entries...)
+		poll()
+
+		expected := []payload.Payload{
+			{"guid": "2", "title": "Second", "link": "", "author": "", "summary": "", "published": ""},
+			{"guid": "3", "title": "Third", "link": "", "author": "alice@example.com", "summary": "Summary", "published": "2022-05-10T10:00:00Z"},
+		}
+
+		if len(*got) != len(expected) {
+			t.Fatalf("expected %d triggers, got %d", len(expected), len(*got))
+		}
+
+		for i, trigger := range *got {
+			if trigger.WorkflowIdentifier != "blog" || trigger.Variety != FeedVariety {
+				t.Errorf("unexpected trigger %+v", trigger)
+			}
+
+			if !reflect.DeepEqual(trigger.Payload, expected[i]) {
+				t.Errorf("unexpected payload\n%+v\n%+v", trigger.Payload, expected[i])
+			}
+		}
+
+		// Nothing new.
+		*got = nil
+		poll()
+		if len(*got) != 0 {
+			t.Errorf("expected no triggers when nothing is new, got %d", len(*got))
+		}
+	})
+}
+
+// blockingStateRepository blocks reading the state until released, telling when it is read.
+type blockingStateRepository struct {
+	read    chan struct{}
+	release chan struct{}
+}
+
+func (r blockingStateRepository) Get(workflowID uint64, key string) (string, error) {
+	r.read <- struct{}{}
+	<-r.release
+	return "", nil
+}
+
+func (r blockingStateRepository) Set(workflowID uint64, key string, value string) error {
+	return nil
+}
+
+func TestStopFeedListener(t *testing.T) {
+	server := httptest.NewServer(netHttp.HandlerFunc(func(w netHttp.ResponseWriter, r *netHttp.Request) {
+		fmt.Fprint(w, `<rss version="2.0"><channel><item><guid>1</guid></item></channel></rss>`)
+	}))
+	defer server.Close()
+
+	database.Test(func(session db.Session) {
+		fixtures.Workflows(session)
+		triggerRepository := NewRepository(session)
+		triggerRepository.Save(&model.Trigger{WorkflowID: 1, Variety: FeedVariety, Meta: map[string]string{"url": server.URL}})
+
+		bus, _ := collectTriggers()
+		stateRepository := blockingStateRepository{read: make(chan struct{}), release: make(chan struct{})}
+		l := NewFeedListener(triggerRepository, stateRepository, workflow.NewRepository(session), bus)
+		if err := l.Start(); err != nil {
+			t.Fatalf("failed to start feed listener: %s", err)
+		}
+
+		// The feed is being handled when the listener is stopped
+		<-stateRepository.read
+		stopped := make(chan struct{})
+		go func() {
+			l.Stop()
+			close(stopped)
+		}()
+
+		select {
+		case <-stopped:
+			t.Fatal("stopping should wait for the feed to be handled")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		close(stateRepository.release)
+		select {
+		case <-stopped:
+		case <-time.After(time.Second):
+			t.Fatal("stopping should be done once the feed is handled")
+		}
+	})
+}
+
+func TestNewFeedWorkflow(t *testing.T) {
+	fw, err := newFeedWorkflow(w.Workflow{}, model.Trigger{Meta: map[string]string{"url": "https://example.com/feed"}})
+	if err != nil {
+		t.Fatalf("feed trigger should be valid, got: %s", err)
+	}
+
+	if fw.interval != 15*time.Minute {
+		t.Errorf("interval should default to 15 minutes, got %s", fw.interval)
+	}
+
+	if _, err = newFeedWorkflow(w.Workflow{}, model.Trigger{Meta: map[string]string{}}); err == nil {
+		t.Errorf("feed trigger without url should not be valid")
+	}
+}
@@ -0,0 +1,257 @@
+package trigger
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	netHttp "net/http"
+	"net/url"
+	"neurobot/infrastructure/event"
+	"neurobot/infrastructure/http"
+	"neurobot/model/payload"
+	model "neurobot/model/trigger"
+	w "neurobot/model/workflow"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+)
+
+// PollVariety is the variety of triggers that start a workflow for every new or changed item of a JSON document,
+// requested at a regular interval.
+const PollVariety = "poll"
+
+// PollListener starts workflows which have a poll trigger, when new or changed items are polled.
+// Listening for a poll trigger means polling its URL at its interval.
+type PollListener interface {
+	Listener
+}
+
+type pollListener struct {
+	triggerRepository  model.Repository
+	stateRepository    model.StateRepository
+	workflowRepository w.Repository
+	eventBus           event.Bus
+	mutex              sync.Mutex
+	pollers            []http.Poller
+	running            sync.WaitGroup // the pollers which didn't stop running yet
+}
+
+type polledWorkflow struct {
+	workflow  w.Workflow
+	url       *url.URL
+	interval  time.Duration
+	headers   netHttp.Header
+	itemsPath string // path to the array of items in the document, empty means the document itself
+	keyPath   string // path to the key of an item, empty means the item is identified by its content
+	stateKey  string // under which the seen items are stored
+}
+
+func NewPollListener(
+	triggerRepository model.Repository,
+	stateRepository model.StateRepository,
+	workflowRepository w.Repository,
+	eventBus event.Bus,
+) PollListener {
+	return &pollListener{
+		triggerRepository:  triggerRepository,
+		stateRepository:    stateRepository,
+		workflowRepository: workflowRepository,
+		eventBus:           eventBus,
+	}
+}
+
+func (l *pollListener) Start() error {
+	return start(l, l.triggerRepository, l.workflowRepository)
+}
+
+func (l *pollListener) Load(triggerRepository model.Repository, workflowRepository w.Repository) (func(), error) {
+	triggers, err := findActive(triggerRepository, workflowRepository, PollVariety)
+	if err != nil {
+		return nil, err
+	}
+
+	var pollers []http.Poller
+	for _, wt := range triggers {
+		pw, err := newPolledWorkflow(wt.workflow, wt.trigger)
+		if err != nil {
+			return nil, fmt.Errorf("invalid poll trigger for workflow %s: %w", wt.workflow.Identifier, err)
+		}
+
+		pollers = append(pollers, http.NewHttpPoller(pw.interval, pw.url, pw.headers, func(body []byte) {
+			l.handle(pw, body)
+		}))
+	}
+
+	return func() {
+		// The previous pollers are done handling what they polled once stopped, so they can't overwrite the state
+		l.Stop()
+
+		l.mutex.Lock()
+		l.pollers = pollers
+		l.mutex.Unlock()
+
+		l.running.Add(len(pollers))
+		for _, poller := range pollers {
+			go func(poller http.Poller) {
+				defer l.running.Done()
+				poller.Run()
+			}(poller)
+		}
+	}, nil
+}
+
+// Stop stops the pollers, and waits for them to be done with what they polled.
+func (l *pollListener) Stop() {
+	l.mutex.Lock()
+	for _, poller := range l.pollers {
+		poller.Stop()
+	}
+	l.pollers = nil
+	l.mutex.Unlock()
+
+	l.running.Wait()
+}
+
+func newPolledWorkflow(workflow w.Workflow, trigger model.Trigger) (pw polledWorkflow, err error) {
+	pw.workflow = workflow
+
+	if pw.url, err = parsePollURL(trigger.Meta["url"]); err != nil {
+		return
+	}
+
+	pw.interval = time.Minute
+	if trigger.Meta["interval"] != "" {
+		if pw.interval, err = time.ParseDuration(trigger.Meta["interval"]); err != nil {
+			return
+		}
+	}
+
+	if pw.headers, err = http.ParseHeaders(trigger.Meta["headers"]); err != nil {
+		return
+	}
+
+	pw.itemsPath = trigger.Meta["items"]
+	pw.keyPath = trigger.Meta["key"]
+	pw.stateKey = fmt.Sprintf("poll:%s:%s:%s", pw.url, pw.itemsPath, pw.keyPath)
+
+	return
+}
+
+func parsePollURL(value string) (*url.URL, error) {
+	u, err := url.Parse(value)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("url must be http or https, got %q", value)
+	}
+
+	return u, nil
+}
+
+// handle starts the workflow for every item which wasn't in the previously polled document, or has changed since.
+// Nothing is started on the very first poll, as everything would be new.
+func (l *pollListener) handle(pw polledWorkflow, body []byte) {
+	logger := log.WithFields(log.Fields{"workflow": pw.workflow.Identifier, "url": pw.url.String()})
+
+	items, err := extractItems(body, pw.itemsPath)
+	if err != nil {
+		logger.WithError(err).Error("Failed to extract items from polled document")
+		return
+	}
+
+	seen, err := l.loadSeen(pw)
+	if err != nil {
+		logger.WithError(err).Error("Failed to load seen items")
+		return
+	}
+
+	current := make(map[string]string)
+	for _, item := range items {
+		key, hash := identify(item, pw.keyPath)
+		current[key] = hash
+
+		if seen != nil && seen[key] != hash {
+			// An object is the payload itself, anything else is under `item`
+			p, ok := item.(map[string]interface{})
+			if !ok {
+				p = map[string]interface{}{"item": item}
+			}
+			publish(l.eventBus, pw.workflow, PollVariety, payload.Payload(p))
+		}
+	}
+
+	if err = l.saveSeen(pw, current); err != nil {
+		logger.WithError(err).Error("Failed to save seen items")
+	}
+}
+
+// loadSeen returns the hashes of the items seen in the previous poll by their key, nil when it never polled before.
+func (l *pollListener) loadSeen(pw polledWorkflow) (seen map[string]string, err error) {
+	value, err := l.stateRepository.Get(pw.workflow.ID, pw.stateKey)
+	if err != nil || value == "" {
+		return
+	}
+
+	err = json.Unmarshal([]byte(value), &seen)
+
+	return
+}
+
+func (l *pollListener) saveSeen(pw polledWorkflow, seen map[string]string) error {
+	value, err := json.Marshal(seen)
+	if err != nil {
+		return err
+	}
+
+	return l.stateRepository.Set(pw.workflow.ID, pw.stateKey, string(value))
+}
+
+// extractItems decodes a JSON document and returns the items found at a path, which must be an array or an object.
+// An object is handled as a single item.
+func extractItems(body []byte, path string) ([]interface{}, error) {
+	var document interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+
+	value, ok := payload.Lookup(document, path)
+	if !ok {
+		return nil, fmt.Errorf("nothing found at path %q", path)
+	}
+
+	switch v := value.(type) {
+	case []interface{}:
+		return v, nil
+	case map[string]interface{}:
+		return []interface{}{v}, nil
+	}
+
+	return nil, fmt.Errorf("expected an array or an object at path %q", path)
+}
+
+// identify returns the key of an item, and a hash of its content to detect changes.
+func identify(item interface{}, keyPath string) (key string, hash string) {
+	content, _ := json.Marshal(item) // keys of maps are sorted, so the encoding is stable
+	sum := sha256.Sum256(content)
+	hash = hex.EncodeToString(sum[:])
+
+	if keyPath == "" {
+		return hash, hash
+	}
+
+	value, _ := payload.Lookup(item, keyPath)
+	if s, ok := value.(string); ok {
+		return s, hash
+	}
+
+	encodedKey, _ := json.Marshal(value)
+
+	return string(encodedKey), hash
+}
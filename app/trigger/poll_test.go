@@ -0,0 +1,130 @@
+package trigger
+
+import (
+	"encoding/json"
+	"neurobot/model/payload"
+	model "neurobot/model/trigger"
+	w "neurobot/model/workflow"
+	"neurobot/resources/tests/database"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/upper/db/v4"
+)
+
+func TestNewPolledWorkflow(t *testing.T) {
+	pw, err := newPolledWorkflow(w.Workflow{}, model.Trigger{
+		Variety: PollVariety,
+		Meta: map[string]string{
+			"url":     "https://example.com/api/items",
+			"headers": "Authorization: Bearer secret\nAccept: application/json\n",
+		},
+	})
+	if err != nil {
+		t.Fatalf("poll trigger should be valid, got: %s", err)
+	}
+
+	if pw.interval != time.Minute {
+		t.Errorf("interval should default to 1 minute, got %s", pw.interval)
+	}
+
+	if pw.headers.Get("Authorization") != "Bearer secret" || pw.headers.Get("Accept") != "application/json" {
+		t.Errorf("unexpected headers %+v", pw.headers)
+	}
+
+	invalid := []map[string]string{
+		{},
+		{"url": "ftp://example.com"},
+		{"url": "https://example.com", "interval": "often"},
+		{"url": "https://example.com", "headers": "Authorization"},
+	}
+
+	for _, meta := range invalid {
+		if _, err := newPolledWorkflow(w.Workflow{}, model.Trigger{Variety: PollVariety, Meta: meta}); err == nil {
+			t.Errorf("poll trigger %+v should not be valid", meta)
+		}
+	}
+}
+
+func TestHandlePoll(t *testing.T) {
+	database.Test(func(session db.Session) {
+		bus, got := collectTriggers()
+		l := &pollListener{
+			stateRepository: NewStateRepository(session),
+			eventBus:        bus,
+		}
+
+		pw, _ := newPolledWorkflow(w.Workflow{ID: 1, Identifier: "deploys"}, model.Trigger{
+			Variety: PollVariety,
+			Meta: map[string]string{
+				"url":   "https://example.com/api/deploys",
+				"items": "data.deploys",
+				"key":   "id",
+			},
+		})
+
+		// First poll only remembers what was seen.
+		l.handle(pw, []byte(`{"data": {"deploys": [{"id": 1, "status": "running"}, {"id": 2, "status": "done"}]}}`))
+		if len(*got) != 0 {
+			t.Fatalf("expected no triggers on first poll, got %d", len(*got))
+		}
+
+		// Deploy 1 changed, deploy 2 is the same, deploy 3 is new.
+		l.handle(pw, []byte(`{"data": {"deploys": [
+			{"id": 1, "status": "done", "user": {"login": "alice"}},
+			{"id": 2, "status": "done"},
+			{"id": 3, "status": "running", "tags": ["a", "b"]}
+		]}}`))
+
+		expected := []payload.Payload{
+			{"id": json.Number("1"), "status": "done", "user": map[string]interface{}{"login": "alice"}},
+			{"id": json.Number("3"), "status": "running", "tags": []interface{}{"a", "b"}},
+		}
+
+		if len(*got) != len(expected) {
+			t.Fatalf("expected %d triggers, got %d", len(expected), len(*got))
+		}
+
+		for i, trigger := range *got {
+			if trigger.WorkflowIdentifier != "deploys" || trigger.Variety != PollVariety {
+				t.Errorf("unexpected trigger %+v", trigger)
+			}
+
+			if !reflect.DeepEqual(trigger.Payload, expected[i]) {
+				t.Errorf("unexpected payload\n%+v\n%+v", trigger.Payload, expected[i])
+			}
+		}
+
+		// Nothing changed.
+		*got = nil
+		l.handle(pw, []byte(`{"data": {"deploys": [
+			{"id": 1, "status": "done", "user": {"login": "alice"}},
+			{"id": 2, "status": "done"},
+			{"id": 3, "status": "running", "tags": ["a", "b"]}
+		]}}`))
+
+		if len(*got) != 0 {
+			t.Errorf("expected no triggers when nothing changed, got %d", len(*got))
+		}
+	})
+}
+
+func TestExtractItems(t *testing.T) {
+	items, err := extractItems([]byte(`{"id": 1}`), "")
+	if err != nil || len(items) != 1 {
+		t.Errorf("an object should be a single item, got %+v (%v)", items, err)
+	}
+
+	if _, err = extractItems([]byte(`{"data": "foo"}`), "data"); err == nil {
+		t.Errorf("a string should not be accepted as items")
+	}
+
+	if _, err = extractItems([]byte(`{"data": []}`), "items"); err == nil {
+		t.Errorf("a missing path should not be accepted")
+	}
+
+	if _, err = extractItems([]byte(`<html>`), ""); err == nil {
+		t.Errorf("invalid JSON should not be accepted")
+	}
+}
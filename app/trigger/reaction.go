@@ -0,0 +1,164 @@
+package trigger
+
+import (
+	"fmt"
+	"neurobot/app/bot"
+	"neurobot/infrastructure/event"
+	"neurobot/infrastructure/matrix"
+	"neurobot/model/payload"
+	"neurobot/model/room"
+	model "neurobot/model/trigger"
+	w "neurobot/model/workflow"
+	"strings"
+	"sync"
+)
+
+// ReactionVariety is the variety of triggers that start a workflow when someone reacts to a message with an emoji,
+// in a room a bot is a member of.
+const ReactionVariety = "matrixReaction"
+
+// variationSelector is appended to some emojis by clients to request their emoji presentation,
+// it is ignored when comparing emojis.
+const variationSelector = "\ufe0f"
+
+// ReactionListener starts workflows which have a matrixReaction trigger, when a matching reaction is sent in a room.
+type ReactionListener interface {
+	Listener
+}
+
+type reactionListener struct {
+	triggerRepository  model.Repository
+	workflowRepository w.Repository
+	botRegistry        bot.Registry
+	eventBus           event.Bus
+	mutex              sync.RWMutex
+	reactions          map[string][]reaction    // by name of the bot they listen as
+	clients            map[string]matrix.Client // by bot name, the clients whose reactions are watched
+}
+
+type reaction struct {
+	workflow w.Workflow
+	emojis   map[string]bool // empty means any emoji
+	rooms    map[string]bool // empty means any room
+}
+
+func NewReactionListener(
+	triggerRepository model.Repository,
+	workflowRepository w.Repository,
+	botRegistry bot.Registry,
+	eventBus event.Bus,
+) ReactionListener {
+	return &reactionListener{
+		triggerRepository:  triggerRepository,
+		workflowRepository: workflowRepository,
+		botRegistry:        botRegistry,
+		eventBus:           eventBus,
+		clients:            make(map[string]matrix.Client),
+	}
+}
+
+func (l *reactionListener) Start() error {
+	return start(l, l.triggerRepository, l.workflowRepository)
+}
+
+// Load watches the reactions received by the bots the reactions listen as right away, but only handles the reactions
+// once swapped.
+func (l *reactionListener) Load(triggerRepository model.Repository, workflowRepository w.Repository) (func(), error) {
+	triggers, err := findActive(triggerRepository, workflowRepository, ReactionVariety)
+	if err != nil {
+		return nil, err
+	}
+
+	reactionsByBot := make(map[string][]reaction)
+	for _, wt := range triggers {
+		r, err := newReaction(wt.workflow, wt.trigger)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reaction trigger for workflow %s: %w", wt.workflow.Identifier, err)
+		}
+
+		for _, botName := range splitList(wt.trigger.Meta["asBot"], true) {
+			reactionsByBot[botName] = append(reactionsByBot[botName], r)
+		}
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for botName := range reactionsByBot {
+		client, err := getClient(l.botRegistry, botName)
+		if err != nil {
+			return nil, err
+		}
+
+		// Reactions of a client are watched once, whatever the reactions
+		if l.clients[botName] == client {
+			continue
+		}
+		if err = client.OnReaction(l.handle(botName, client)); err != nil {
+			return nil, err
+		}
+		l.clients[botName] = client
+	}
+
+	return func() {
+		l.mutex.Lock()
+		l.reactions = reactionsByBot
+		l.mutex.Unlock()
+	}, nil
+}
+
+func (l *reactionListener) Stop() {
+	l.mutex.Lock()
+	l.reactions = nil
+	l.mutex.Unlock()
+}
+
+// handle handles what the client of a bot receives, until the bot logs in again with another client.
+func (l *reactionListener) handle(botName string, client matrix.Client) func(roomID room.ID, sender string, eventID string, key string) {
+	return func(roomID room.ID, sender string, eventID string, key string) {
+		l.mutex.RLock()
+		reactions := l.reactions[botName]
+		current := l.clients[botName] == client
+		l.mutex.RUnlock()
+
+		if !current {
+			return
+		}
+
+		for _, r := range reactions {
+			if !r.matches(roomID, key) {
+				continue
+			}
+
+			p := payload.Payload{
+				"room":     roomID.ID(),
+				"sender":   sender,
+				"eventID":  eventID,
+				"reaction": key,
+			}
+
+			publish(l.eventBus, r.workflow, ReactionVariety, p)
+		}
+	}
+}
+
+func newReaction(workflow w.Workflow, trigger model.Trigger) (r reaction, err error) {
+	r.workflow = workflow
+
+	r.emojis = make(map[string]bool)
+	for _, emoji := range splitList(trigger.Meta["emojis"], false) {
+		r.emojis[strings.TrimSuffix(emoji, variationSelector)] = true
+	}
+
+	r.rooms, err = parseRooms(trigger.Meta["rooms"])
+
+	return
+}
+
+func (r reaction) matches(roomID room.ID, key string) bool {
+	if len(r.rooms) > 0 && !r.rooms[roomID.ID()] {
+		return false
+	}
+
+	return len(r.emojis) == 0 || r.emojis[strings.TrimSuffix(key, variationSelector)]
+}
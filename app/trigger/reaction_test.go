@@ -0,0 +1,62 @@
+package trigger
+
+import (
+	"neurobot/model/payload"
+	"neurobot/model/room"
+	model "neurobot/model/trigger"
+	w "neurobot/model/workflow"
+	"reflect"
+	"testing"
+)
+
+func TestNewReaction(t *testing.T) {
+	if _, err := newReaction(w.Workflow{}, model.Trigger{Meta: map[string]string{"emojis": "✅, 👍"}}); err != nil {
+		t.Errorf("reaction should be valid, got: %s", err)
+	}
+
+	if _, err := newReaction(w.Workflow{}, model.Trigger{Meta: map[string]string{"rooms": "foo"}}); err == nil {
+		t.Errorf("reaction with invalid room should not be valid")
+	}
+}
+
+func TestHandleReaction(t *testing.T) {
+	bus, got := collectTriggers()
+	l := &reactionListener{eventBus: bus}
+
+	r, _ := newReaction(w.Workflow{Identifier: "done"}, model.Trigger{
+		Variety: ReactionVariety,
+		Meta: map[string]string{
+			"emojis": "✅",
+			"rooms":  "!foo:matrix.test",
+		},
+	})
+	l.reactions = map[string][]reaction{"neurobot": {r}}
+	handle := l.handle("neurobot", nil)
+
+	foo, _ := room.NewID("!foo:matrix.test")
+	bar, _ := room.NewID("!bar:matrix.test")
+
+	handle(bar, "@alice:matrix.test", "$event1", "✅")
+	handle(foo, "@alice:matrix.test", "$event1", "👍")
+	handle(foo, "@alice:matrix.test", "$event1", "✅️")
+
+	if len(*got) != 1 {
+		t.Fatalf("expected 1 trigger, got %d", len(*got))
+	}
+
+	expected := payload.Payload{
+		"room":     "!foo:matrix.test",
+		"sender":   "@alice:matrix.test",
+		"eventID":  "$event1",
+		"reaction": "✅️",
+	}
+
+	trigger := (*got)[0]
+	if trigger.WorkflowIdentifier != "done" || trigger.Variety != ReactionVariety {
+		t.Errorf("unexpected trigger %+v", trigger)
+	}
+
+	if !reflect.DeepEqual(trigger.Payload, expected) {
+		t.Errorf("unexpected payload\n%+v\n%+v", trigger.Payload, expected)
+	}
+}
@@ -0,0 +1,181 @@
+package trigger
+
+import (
+	"fmt"
+	model "neurobot/model/trigger"
+	"strings"
+
+	"github.com/upper/db/v4"
+)
+
+const triggerTableName = "workflow_triggers"
+const triggerMetaTableName = "workflow_trigger_meta"
+
+type meta struct {
+	ID        uint64 `db:"id,omitempty"`
+	TriggerID uint64 `db:"trigger_id"`
+	Key       string `db:"key"`
+	Value     string `db:"value"`
+}
+
+type repository struct {
+	collection     db.Collection
+	collectionMeta db.Collection
+}
+
+func NewRepository(session db.Session) model.Repository {
+	return &repository{
+		collection:     session.Collection(triggerTableName),
+		collectionMeta: session.Collection(triggerMetaTableName),
+	}
+}
+
+func (repository *repository) FindByID(ID uint64) (trigger model.Trigger, err error) {
+	if err = repository.collection.Find(db.Cond{"id": ID}).One(&trigger); err != nil {
+		return
+	}
+
+	err = repository.loadMeta(&trigger)
+
+	return
+}
+
+func (repository *repository) FindByVariety(variety string) (triggers []model.Trigger, err error) {
+	result := repository.collection.Find(db.Cond{"variety": variety}).OrderBy("id")
+	if err = result.All(&triggers); err != nil {
+		return
+	}
+
+	for i := range triggers {
+		if err = repository.loadMeta(&triggers[i]); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func (repository *repository) FindByWorkflowID(ID uint64) (triggers []model.Trigger, err error) {
+	result := repository.collection.Find(db.Cond{"workflow_id": ID}).OrderBy("id")
+	if err = result.All(&triggers); err != nil {
+		return
+	}
+
+	for i := range triggers {
+		if err = repository.loadMeta(&triggers[i]); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func (repository *repository) Save(trigger *model.Trigger) error {
+	if trigger.ID > 0 {
+		return repository.update(trigger)
+	}
+
+	return repository.insert(trigger)
+}
+
+func (repository *repository) insert(trigger *model.Trigger) (err error) {
+	result, err := repository.collection.Insert(trigger)
+	if err != nil {
+		return
+	}
+
+	trigger.ID = uint64(result.ID().(int64))
+
+	return repository.saveMeta(trigger)
+}
+
+func (repository *repository) update(trigger *model.Trigger) (err error) {
+	var existing model.Trigger
+
+	result := repository.collection.Find(trigger.ID)
+	if err = result.One(&existing); err != nil {
+		return
+	}
+
+	existing.WorkflowID = trigger.WorkflowID
+	existing.Variety = trigger.Variety
+
+	if err = result.Update(existing); err != nil {
+		return
+	}
+
+	return repository.saveMeta(trigger)
+}
+
+func (repository *repository) loadMeta(trigger *model.Trigger) (err error) {
+	var metas []meta
+	result := repository.collectionMeta.Find(db.Cond{"trigger_id": trigger.ID})
+	if err := result.All(&metas); err != nil {
+		return err
+	}
+
+	trigger.Meta = make(map[string]string)
+	for _, meta := range metas {
+		trigger.Meta[meta.Key] = meta.Value
+	}
+
+	return
+}
+
+func (repository *repository) saveMeta(trigger *model.Trigger) (err error) {
+	// delete existing entries
+	res := repository.collectionMeta.Find(db.Cond{"trigger_id": trigger.ID})
+	if err = res.Delete(); err != nil {
+		return
+	}
+
+	// insert new entries
+	for k, v := range trigger.Meta {
+		_, err = repository.collectionMeta.Insert(meta{
+			TriggerID: trigger.ID,
+			Key:       k,
+			Value:     v,
+		})
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func (repository *repository) RemoveByWorkflowID(ID uint64) (err error) {
+	var triggers []model.Trigger
+	res := repository.collection.Find(db.Cond{"workflow_id": ID})
+	if err = res.All(&triggers); err != nil {
+		return
+	}
+
+	if len(triggers) == 0 {
+		return
+	}
+
+	var triggerIDs []string // need string for SQL
+	for _, t := range triggers {
+		triggerIDs = append(triggerIDs, fmt.Sprintf("%d", t.ID))
+	}
+
+	// Delete from workflow_triggers table
+	if err = res.Delete(); err != nil {
+		return
+	}
+
+	// Delete from workflow_trigger_meta table
+	deleteTriggerMetaQuery := fmt.Sprintf("DELETE FROM "+triggerMetaTableName+" WHERE trigger_id IN (%s)", strings.Join(triggerIDs, ","))
+	_, err = repository.collectionMeta.Session().SQL().Exec(deleteTriggerMetaQuery)
+
+	return
+}
+
+func (repository *repository) Remove(ID uint64) (err error) {
+	if err = repository.collection.Find(db.Cond{"id": ID}).Delete(); err != nil {
+		return
+	}
+
+	return repository.collectionMeta.Find(db.Cond{"trigger_id": ID}).Delete()
+}
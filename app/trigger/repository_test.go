@@ -0,0 +1,195 @@
+package trigger
+
+import (
+	"errors"
+	model "neurobot/model/trigger"
+	"neurobot/resources/tests/database"
+	"neurobot/resources/tests/fixtures"
+	"reflect"
+	"testing"
+
+	"github.com/upper/db/v4"
+)
+
+func TestFindByVariety(t *testing.T) {
+	database.Test(func(session db.Session) {
+		triggers := fixtures.Triggers(session)
+		repository := NewRepository(session)
+
+		got, err := repository.FindByVariety("schedule")
+		if err != nil {
+			t.Errorf("failed to get triggers by variety: %s", err)
+		}
+
+		expected := []model.Trigger{
+			triggers["Daily"],
+			triggers["Weekly"],
+		}
+
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("unexpected result triggers by variety\n%+v\n%+v", got, expected)
+		}
+	})
+}
+
+func TestFindByWorkflowID(t *testing.T) {
+	database.Test(func(session db.Session) {
+		triggers := fixtures.Triggers(session)
+		repository := NewRepository(session)
+
+		got, err := repository.FindByWorkflowID(1)
+		if err != nil {
+			t.Errorf("failed to get triggers by workflow ID: %s", err)
+		}
+
+		expected := []model.Trigger{
+			triggers["Daily"],
+			triggers["Webhook"],
+		}
+
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("unexpected result triggers by workflow ID\n%+v\n%+v", got, expected)
+		}
+	})
+}
+
+func TestInsert(t *testing.T) {
+	database.Test(func(session db.Session) {
+		repository := NewRepository(session)
+
+		trigger := model.Trigger{
+			WorkflowID: 1,
+			Variety:    "schedule",
+			Meta: map[string]string{
+				"cron": "@daily",
+			},
+		}
+
+		if err := repository.Save(&trigger); err != nil {
+			t.Errorf("failed to insert trigger: %s", err)
+		}
+
+		got, err := repository.FindByWorkflowID(1)
+		if err != nil {
+			t.Errorf("failed to find trigger: %s", err)
+		}
+
+		if !reflect.DeepEqual(got, []model.Trigger{trigger}) {
+			t.Errorf("unexpected result insert trigger\n%+v\n%+v", got, trigger)
+		}
+	})
+}
+
+func TestUpdate(t *testing.T) {
+	database.Test(func(session db.Session) {
+		triggers := fixtures.Triggers(session)
+		repository := NewRepository(session)
+
+		trigger := triggers["Weekly"]
+		trigger.Meta["cron"] = "@monthly"
+		delete(trigger.Meta, "catchUp")
+
+		if err := repository.Save(&trigger); err != nil {
+			t.Errorf("failed to update trigger: %s", err)
+		}
+
+		got, err := repository.FindByWorkflowID(trigger.WorkflowID)
+		if err != nil {
+			t.Errorf("failed to find trigger: %s", err)
+		}
+
+		if !reflect.DeepEqual(got, []model.Trigger{trigger}) {
+			t.Errorf("unexpected result update trigger\n%+v\n%+v", got, trigger)
+		}
+	})
+}
+
+func TestRemoveByWorkflowID(t *testing.T) {
+	database.Test(func(session db.Session) {
+		fixtures.Triggers(session)
+		repository := NewRepository(session)
+
+		if err := repository.RemoveByWorkflowID(1); err != nil {
+			t.Errorf("unable to remove triggers based on workflow ID: %s", err)
+		}
+
+		got, err := repository.FindByWorkflowID(1)
+		if err != nil {
+			t.Errorf("error querying for triggers: %s", err)
+		}
+
+		if len(got) > 0 {
+			t.Errorf("triggers were not deleted")
+		}
+
+		gotCount, err := session.Collection(triggerMetaTableName).Find(db.Cond{"trigger_id": []uint64{1, 3}}).Count()
+		if err != nil {
+			t.Errorf("could not get data out of trigger meta table")
+		}
+
+		if gotCount > 0 {
+			t.Errorf("trigger meta was not deleted")
+		}
+	})
+}
+
+func TestFindByIDAndRemove(t *testing.T) {
+	database.Test(func(session db.Session) {
+		triggers := fixtures.Triggers(session)
+		repository := NewRepository(session)
+
+		expected := triggers["Daily"]
+		got, err := repository.FindByID(expected.ID)
+		if err != nil {
+			t.Fatalf("failed to find trigger: %s", err)
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("unexpected trigger\n%+v\n%+v", got, expected)
+		}
+
+		if err = repository.Remove(expected.ID); err != nil {
+			t.Fatalf("failed to remove trigger: %s", err)
+		}
+		if _, err = repository.FindByID(expected.ID); !errors.Is(err, db.ErrNoMoreRows) {
+			t.Errorf("expected removed trigger not to be found, got: %v", err)
+		}
+
+		count, _ := session.Collection(triggerMetaTableName).Find(db.Cond{"trigger_id": expected.ID}).Count()
+		if count > 0 {
+			t.Errorf("trigger meta was not removed")
+		}
+	})
+}
+
+func TestStateRepository(t *testing.T) {
+	database.Test(func(session db.Session) {
+		repository := NewStateRepository(session)
+
+		got, err := repository.Get(1, "foo")
+		if err != nil {
+			t.Errorf("failed to get missing state: %s", err)
+		}
+		if got != "" {
+			t.Errorf("missing state should be empty, got: %s", got)
+		}
+
+		for _, value := range []string{"value1", "value2"} {
+			if err := repository.Set(1, "foo", value); err != nil {
+				t.Errorf("failed to set state: %s", err)
+			}
+
+			got, err = repository.Get(1, "foo")
+			if err != nil {
+				t.Errorf("failed to get state: %s", err)
+			}
+			if got != value {
+				t.Errorf("did not get expected value %s, got: %s", value, got)
+			}
+		}
+
+		got, _ = repository.Get(2, "foo")
+		if got != "" {
+			t.Errorf("state should be scoped to the workflow, got: %s", got)
+		}
+	})
+}
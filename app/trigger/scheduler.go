@@ -0,0 +1,228 @@
+package trigger
+
+import (
+	"fmt"
+	"neurobot/infrastructure/cron"
+	"neurobot/infrastructure/event"
+	"neurobot/model/payload"
+	model "neurobot/model/trigger"
+	w "neurobot/model/workflow"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+)
+
+// ScheduleVariety is the variety of triggers that start a workflow at the times defined by a cron expression.
+const ScheduleVariety = "schedule"
+
+// Catch up policies for fires that were missed while the program was not running.
+const (
+	CatchUpSkip = "skip" // forget about missed fires
+	CatchUpOnce = "once" // fire once for the latest missed fire
+	CatchUpAll  = "all"  // fire for every missed fire, up to maxCatchUpFires
+)
+
+// maxCatchUpFires limits the CatchUpAll policy, so that a long downtime doesn't flood the rooms with messages.
+const maxCatchUpFires = 100
+
+// Scheduler starts workflows which have a schedule trigger, at the times defined by their cron expression.
+// Listening for a schedule means catching up on its missed fires, and waiting for the upcoming ones.
+type Scheduler interface {
+	Listener
+}
+
+type scheduler struct {
+	triggerRepository  model.Repository
+	stateRepository    model.StateRepository
+	workflowRepository w.Repository
+	eventBus           event.Bus
+	mutex              sync.Mutex
+	done               chan struct{} // closed to stop waiting for the fires of the schedules listened for
+}
+
+type scheduledWorkflow struct {
+	workflow w.Workflow
+	schedule cron.Schedule
+	catchUp  string
+	stateKey string // under which the last fire time is stored
+}
+
+func NewScheduler(
+	triggerRepository model.Repository,
+	stateRepository model.StateRepository,
+	workflowRepository w.Repository,
+	eventBus event.Bus,
+) Scheduler {
+	return &scheduler{
+		triggerRepository:  triggerRepository,
+		stateRepository:    stateRepository,
+		workflowRepository: workflowRepository,
+		eventBus:           eventBus,
+	}
+}
+
+func (s *scheduler) Start() error {
+	return start(s, s.triggerRepository, s.workflowRepository)
+}
+
+func (s *scheduler) Load(triggerRepository model.Repository, workflowRepository w.Repository) (func(), error) {
+	triggers, err := findActive(triggerRepository, workflowRepository, ScheduleVariety)
+	if err != nil {
+		return nil, err
+	}
+
+	var scheduled []scheduledWorkflow
+	for _, wt := range triggers {
+		sw, err := newScheduledWorkflow(wt.workflow, wt.trigger)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule trigger for workflow %s: %w", wt.workflow.Identifier, err)
+		}
+
+		scheduled = append(scheduled, sw)
+	}
+
+	return func() {
+		s.Stop()
+
+		done := make(chan struct{})
+		s.mutex.Lock()
+		s.done = done
+		s.mutex.Unlock()
+
+		// Fires missed while reloading are caught up on, as the last fire of schedules which didn't change is known
+		for _, sw := range scheduled {
+			s.catchUpMissedFires(sw, time.Now())
+
+			go s.wait(sw, done)
+		}
+	}, nil
+}
+
+func (s *scheduler) Stop() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.done != nil {
+		close(s.done)
+		s.done = nil
+	}
+}
+
+func newScheduledWorkflow(workflow w.Workflow, trigger model.Trigger) (sw scheduledWorkflow, err error) {
+	location := time.UTC
+	if trigger.Meta["timezone"] != "" {
+		if location, err = time.LoadLocation(trigger.Meta["timezone"]); err != nil {
+			return
+		}
+	}
+
+	schedule, err := cron.Parse(trigger.Meta["cron"], location)
+	if err != nil {
+		return
+	}
+
+	catchUp := trigger.Meta["catchUp"]
+	switch catchUp {
+	case "":
+		catchUp = CatchUpSkip
+	case CatchUpSkip, CatchUpOnce, CatchUpAll:
+	default:
+		return sw, fmt.Errorf("unknown catch up policy %s", catchUp)
+	}
+
+	return scheduledWorkflow{
+		workflow: workflow,
+		schedule: schedule,
+		catchUp:  catchUp,
+		stateKey: fmt.Sprintf("schedule:%s:%s", trigger.Meta["cron"], location),
+	}, nil
+}
+
+func (s *scheduler) catchUpMissedFires(sw scheduledWorkflow, now time.Time) {
+	logger := log.WithFields(log.Fields{"workflow": sw.workflow.Identifier})
+
+	value, err := s.stateRepository.Get(sw.workflow.ID, sw.stateKey)
+	if err != nil {
+		logger.WithError(err).Error("Failed to load last fire time of schedule")
+		return
+	}
+
+	if value == "" {
+		// First time we see this schedule, nothing could have been missed.
+		s.saveLastFire(sw, now)
+		return
+	}
+
+	lastFire, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		logger.WithError(err).Error("Failed to parse last fire time of schedule")
+		return
+	}
+
+	fires, latest := missedFires(sw.schedule, sw.catchUp, lastFire, now)
+	for _, fire := range fires {
+		s.fire(sw, fire, true)
+	}
+
+	// Skipped fires are saved as fired all the same, or switching to another policy later would catch up on them
+	if sw.catchUp == CatchUpSkip && !latest.IsZero() {
+		s.saveLastFire(sw, latest)
+	}
+}
+
+// missedFires returns the fire times between last and now that should be caught up on according to the policy, along
+// with the latest fire time missed, whether it is caught up on or not.
+func missedFires(schedule cron.Schedule, policy string, last time.Time, now time.Time) (fires []time.Time, latest time.Time) {
+	for next := schedule.Next(last); !next.IsZero() && !next.After(now); next = schedule.Next(next) {
+		latest = next
+		if policy == CatchUpAll {
+			if fires = append(fires, next); len(fires) > maxCatchUpFires {
+				fires = fires[1:]
+			}
+		}
+	}
+
+	if policy == CatchUpOnce && !latest.IsZero() {
+		fires = []time.Time{latest}
+	}
+
+	return
+}
+
+func (s *scheduler) wait(sw scheduledWorkflow, done chan struct{}) {
+	for {
+		next := sw.schedule.Next(time.Now())
+		if next.IsZero() {
+			return
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-done:
+			timer.Stop()
+			return
+		case <-timer.C:
+			s.fire(sw, next, false)
+		}
+	}
+}
+
+func (s *scheduler) fire(sw scheduledWorkflow, at time.Time, missed bool) {
+	p := payload.Payload{
+		"firedAt": at.Format(time.RFC3339),
+		"missed":  missed,
+	}
+
+	publish(s.eventBus, sw.workflow, ScheduleVariety, p)
+
+	s.saveLastFire(sw, at)
+}
+
+func (s *scheduler) saveLastFire(sw scheduledWorkflow, at time.Time) {
+	if err := s.stateRepository.Set(sw.workflow.ID, sw.stateKey, at.Format(time.RFC3339)); err != nil {
+		log.WithError(err).WithFields(log.Fields{
+			"workflow": sw.workflow.Identifier,
+		}).Error("Failed to save last fire time of schedule")
+	}
+}
@@ -0,0 +1,146 @@
+package trigger
+
+import (
+	"neurobot/infrastructure/cron"
+	w "neurobot/model/workflow"
+	"neurobot/resources/tests/database"
+	"neurobot/resources/tests/fixtures"
+	"testing"
+	"time"
+
+	"github.com/upper/db/v4"
+)
+
+func TestMissedFires(t *testing.T) {
+	schedule, _ := cron.Parse("0 * * * *", time.UTC)
+	last := time.Date(2022, time.May, 10, 10, 0, 0, 0, time.UTC)
+	now := time.Date(2022, time.May, 10, 13, 30, 0, 0, time.UTC)
+
+	tables := []struct {
+		policy   string
+		expected []time.Time
+	}{
+		{
+			policy: CatchUpSkip,
+		},
+		{
+			policy:   CatchUpOnce,
+			expected: []time.Time{time.Date(2022, time.May, 10, 13, 0, 0, 0, time.UTC)},
+		},
+		{
+			policy: CatchUpAll,
+			expected: []time.Time{
+				time.Date(2022, time.May, 10, 11, 0, 0, 0, time.UTC),
+				time.Date(2022, time.May, 10, 12, 0, 0, 0, time.UTC),
+				time.Date(2022, time.May, 10, 13, 0, 0, 0, time.UTC),
+			},
+		},
+	}
+
+	for _, table := range tables {
+		got, latest := missedFires(schedule, table.policy, last, now)
+		if !latest.Equal(time.Date(2022, time.May, 10, 13, 0, 0, 0, time.UTC)) {
+			t.Errorf("policy %s: expected the latest missed fire at 13:00, got %s", table.policy, latest)
+		}
+		if len(got) != len(table.expected) {
+			t.Errorf("policy %s: expected %d fires, got %d", table.policy, len(table.expected), len(got))
+			continue
+		}
+
+		for i := range got {
+			if !got[i].Equal(table.expected[i]) {
+				t.Errorf("policy %s: expected fire at %s, got %s", table.policy, table.expected[i], got[i])
+			}
+		}
+	}
+
+	if got, _ := missedFires(schedule, CatchUpAll, last, last.Add(365*24*time.Hour)); len(got) != maxCatchUpFires {
+		t.Errorf("catch up should be limited to %d fires, got %d", maxCatchUpFires, len(got))
+	}
+}
+
+func TestNewScheduledWorkflow(t *testing.T) {
+	database.Test(func(session db.Session) {
+		triggers := fixtures.Triggers(session)
+		workflow := w.Workflow{ID: 1, Identifier: "QUICKSTART"}
+
+		sw, err := newScheduledWorkflow(workflow, triggers["Daily"])
+		if err != nil {
+			t.Errorf("failed to make scheduled workflow: %s", err)
+		}
+		if sw.catchUp != CatchUpSkip {
+			t.Errorf("catch up policy should default to %s, got %s", CatchUpSkip, sw.catchUp)
+		}
+
+		trigger := triggers["Weekly"]
+		trigger.Meta["catchUp"] = "sometimes"
+		if _, err := newScheduledWorkflow(workflow, trigger); err == nil {
+			t.Errorf("unknown catch up policy should not be accepted")
+		}
+
+		trigger.Meta["catchUp"] = CatchUpOnce
+		trigger.Meta["timezone"] = "Mars/Olympus_Mons"
+		if _, err := newScheduledWorkflow(workflow, trigger); err == nil {
+			t.Errorf("unknown timezone should not be accepted")
+		}
+	})
+}
+
+func TestCatchUpMissedFires(t *testing.T) {
+	database.Test(func(session db.Session) {
+		stateRepository := NewStateRepository(session)
+
+		bus, triggers := collectTriggers()
+		s := &scheduler{
+			stateRepository: stateRepository,
+			eventBus:        bus,
+		}
+
+		schedule, _ := cron.Parse("0 9 * * *", time.UTC)
+		sw := scheduledWorkflow{
+			workflow: w.Workflow{ID: 1, Identifier: "QUICKSTART"},
+			schedule: schedule,
+			catchUp:  CatchUpOnce,
+			stateKey: "schedule:test",
+		}
+
+		// Nothing can be missed the first time a schedule is seen.
+		s.catchUpMissedFires(sw, time.Date(2022, time.May, 10, 10, 0, 0, 0, time.UTC))
+		if len(*triggers) != 0 {
+			t.Errorf("expected no fires on first start, got %d", len(*triggers))
+		}
+
+		s.catchUpMissedFires(sw, time.Date(2022, time.May, 13, 10, 0, 0, 0, time.UTC))
+		if len(*triggers) != 1 {
+			t.Fatalf("expected 1 fire, got %d", len(*triggers))
+		}
+
+		trigger := (*triggers)[0]
+		if trigger.WorkflowIdentifier != "QUICKSTART" || trigger.Variety != ScheduleVariety {
+			t.Errorf("unexpected trigger %+v", trigger)
+		}
+
+		if trigger.Payload["firedAt"] != "2022-05-13T09:00:00Z" || trigger.Payload["missed"] != true {
+			t.Errorf("unexpected payload %+v", trigger.Payload)
+		}
+
+		got, _ := stateRepository.Get(1, "schedule:test")
+		if got != "2022-05-13T09:00:00Z" {
+			t.Errorf("last fire time was not saved, got: %s", got)
+		}
+
+		// Skipped fires aren't caught up on once the policy changes
+		sw.catchUp = CatchUpSkip
+		s.catchUpMissedFires(sw, time.Date(2022, time.May, 15, 10, 0, 0, 0, time.UTC))
+		sw.catchUp = CatchUpAll
+		s.catchUpMissedFires(sw, time.Date(2022, time.May, 15, 11, 0, 0, 0, time.UTC))
+		if len(*triggers) != 1 {
+			t.Errorf("expected skipped fires not to be caught up on, got %d fires", len(*triggers))
+		}
+
+		got, _ = stateRepository.Get(1, "schedule:test")
+		if got != "2022-05-15T09:00:00Z" {
+			t.Errorf("last skipped fire time was not saved, got: %s", got)
+		}
+	})
+}
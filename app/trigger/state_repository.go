@@ -0,0 +1,59 @@
+package trigger
+
+import (
+	"errors"
+	model "neurobot/model/trigger"
+
+	"github.com/upper/db/v4"
+)
+
+const triggerStateTableName = "workflow_trigger_state"
+
+type state struct {
+	WorkflowID uint64 `db:"workflow_id"`
+	Key        string `db:"key"`
+	Value      string `db:"value"`
+}
+
+type stateRepository struct {
+	collection db.Collection
+}
+
+func NewStateRepository(session db.Session) model.StateRepository {
+	return &stateRepository{
+		collection: session.Collection(triggerStateTableName),
+	}
+}
+
+func (repository *stateRepository) Get(workflowID uint64, key string) (string, error) {
+	var s state
+	result := repository.collection.Find(db.Cond{"workflow_id": workflowID, "key": key})
+	if err := result.One(&s); err != nil {
+		if !errors.Is(err, db.ErrNoMoreRows) {
+			return "", err
+		}
+	}
+
+	return s.Value, nil
+}
+
+func (repository *stateRepository) Set(workflowID uint64, key string, value string) error {
+	s := state{
+		WorkflowID: workflowID,
+		Key:        key,
+		Value:      value,
+	}
+
+	result := repository.collection.Find(db.Cond{"workflow_id": workflowID, "key": key})
+	exists, err := result.Exists()
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		_, err = repository.collection.Insert(s)
+		return err
+	}
+
+	return result.Update(s)
+}
@@ -0,0 +1,142 @@
+package trigger
+
+import (
+	"fmt"
+	"neurobot/app/bot"
+	"neurobot/infrastructure/event"
+	"neurobot/infrastructure/matrix"
+	"neurobot/model/payload"
+	"neurobot/model/room"
+	model "neurobot/model/trigger"
+	w "neurobot/model/workflow"
+	"strings"
+)
+
+// Varieties are the varieties of triggers which are listened for, the ones workflows can be started by besides being
+// started through the admin API.
+var Varieties = []string{ScheduleVariety, PollVariety, FeedVariety, WebhookVariety, CommandVariety, ReactionVariety}
+
+// Checks returns how triggers are checked, by variety: their meta is parsed the way their listener parses it when
+// loading them, so that a trigger which passes its check can be loaded.
+func Checks() map[string]model.Check {
+	return map[string]model.Check{
+		ScheduleVariety: func(workflow w.Workflow, trigger model.Trigger) error {
+			_, err := newScheduledWorkflow(workflow, trigger)
+			return err
+		},
+		PollVariety: func(workflow w.Workflow, trigger model.Trigger) error {
+			_, err := newPolledWorkflow(workflow, trigger)
+			return err
+		},
+		FeedVariety: func(workflow w.Workflow, trigger model.Trigger) error {
+			_, err := newFeedWorkflow(workflow, trigger)
+			return err
+		},
+		WebhookVariety: func(workflow w.Workflow, trigger model.Trigger) error {
+			_, err := newWebhookRoute(workflow, trigger, nil)
+			return err
+		},
+		CommandVariety: func(workflow w.Workflow, trigger model.Trigger) error {
+			_, err := newCommand(workflow, trigger)
+			return err
+		},
+		ReactionVariety: func(workflow w.Workflow, trigger model.Trigger) error {
+			_, err := newReaction(workflow, trigger)
+			return err
+		},
+	}
+}
+
+// Listener listens for the triggers of a variety, and starts their workflow when they fire.
+type Listener interface {
+	// Load reads the triggers of active workflows from the repositories, and returns the function which makes the
+	// listener listen for them instead of the triggers it listened for until then. Nothing changes until then, nor
+	// when loading fails, so that the triggers of every listener can be replaced at once.
+	Load(triggerRepository model.Repository, workflowRepository w.Repository) (swap func(), err error)
+
+	// Start loads the triggers from the repositories the listener was made with, and listens for them.
+	Start() error
+
+	// Stop stops listening.
+	Stop()
+}
+
+// start loads the triggers of a listener and listens for them.
+func start(l Listener, triggerRepository model.Repository, workflowRepository w.Repository) error {
+	swap, err := l.Load(triggerRepository, workflowRepository)
+	if err != nil {
+		return err
+	}
+
+	swap()
+
+	return nil
+}
+
+type workflowTrigger struct {
+	workflow w.Workflow
+	trigger  model.Trigger
+}
+
+// findActive retrieves the triggers of a variety, along with their workflow, skipping the ones of inactive workflows.
+func findActive(triggerRepository model.Repository, workflowRepository w.Repository, variety string) (active []workflowTrigger, err error) {
+	triggers, err := triggerRepository.FindByVariety(variety)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching %s triggers: %w", variety, err)
+	}
+
+	for _, trigger := range triggers {
+		workflow, err := workflowRepository.FindByID(trigger.WorkflowID)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching workflow %d of %s trigger: %w", trigger.WorkflowID, variety, err)
+		}
+
+		if workflow.Active {
+			active = append(active, workflowTrigger{workflow: workflow, trigger: trigger})
+		}
+	}
+
+	return
+}
+
+// publish asks for a workflow to be started, by publishing a trigger event on the event bus.
+func publish(eventBus event.Bus, workflow w.Workflow, variety string, p payload.Payload) {
+	eventBus.Publish(event.TriggerTopic(), event.NewTrigger(workflow.Identifier, variety, p))
+}
+
+// getClient returns the client of a bot, an empty bot name stands for the primary bot.
+func getClient(botRegistry bot.Registry, botName string) (matrix.Client, error) {
+	if botName == "" {
+		return botRegistry.GetPrimaryClient()
+	}
+
+	return botRegistry.GetClient(botName)
+}
+
+// splitList splits a comma separated meta value, an empty value results in a single empty item when keepEmpty is set.
+func splitList(value string, keepEmpty bool) (items []string) {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	if len(items) == 0 && keepEmpty {
+		items = []string{""}
+	}
+
+	return
+}
+
+// parseRooms parses a comma separated list of room IDs into a set, an empty set means any room.
+func parseRooms(value string) (map[string]bool, error) {
+	rooms := make(map[string]bool)
+	for _, roomID := range splitList(value, false) {
+		if _, err := room.NewID(roomID); err != nil {
+			return nil, err
+		}
+		rooms[roomID] = true
+	}
+
+	return rooms, nil
+}
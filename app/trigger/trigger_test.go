@@ -0,0 +1,93 @@
+package trigger
+
+import (
+	"neurobot/app/workflow"
+	"neurobot/infrastructure/event"
+	model "neurobot/model/trigger"
+	w "neurobot/model/workflow"
+	"neurobot/resources/tests/database"
+	"neurobot/resources/tests/fixtures"
+	"testing"
+
+	"github.com/upper/db/v4"
+)
+
+// collectTriggers makes an event bus which collects the trigger events published on it, telling the ones which ask
+// for it that they are queued, their position being their run ID.
+func collectTriggers() (event.Bus, *[]event.Trigger) {
+	var triggers []event.Trigger
+
+	bus := event.NewMemoryBus()
+	bus.Subscribe(event.TriggerTopic(), func(e interface{}) {
+		trigger := e.(event.Trigger)
+		triggers = append(triggers, trigger)
+		if trigger.Queued != nil {
+			trigger.Queued <- uint64(len(triggers))
+		}
+	})
+
+	return bus, &triggers
+}
+
+func TestFindActive(t *testing.T) {
+	database.Test(func(session db.Session) {
+		fixtures.Workflows(session)
+		triggers := fixtures.Triggers(session)
+
+		workflowRepository := workflow.NewRepository(session)
+		workflow, _ := workflowRepository.FindByID(11)
+		workflow.Active = false
+		workflowRepository.Save(&workflow)
+
+		got, err := findActive(NewRepository(session), workflowRepository, ScheduleVariety)
+		if err != nil {
+			t.Errorf("failed to find active triggers: %s", err)
+		}
+
+		if len(got) != 1 {
+			t.Fatalf("expected 1 active trigger, got %d", len(got))
+		}
+
+		if got[0].trigger.ID != triggers["Daily"].ID || got[0].workflow.Identifier != "QUICKSTART" {
+			t.Errorf("unexpected active trigger %+v", got[0])
+		}
+	})
+}
+
+func TestChecks(t *testing.T) {
+	checks := Checks()
+	if len(checks) != len(Varieties) {
+		t.Errorf("expected a check for each of the %d varieties, got %d", len(Varieties), len(checks))
+	}
+
+	tables := []struct {
+		variety string
+		meta    map[string]string
+		valid   bool
+	}{
+		{ScheduleVariety, map[string]string{"cron": "0 9 * * mon-fri"}, true},
+		{ScheduleVariety, map[string]string{"cron": "0 9 * *"}, false},
+		{ScheduleVariety, map[string]string{"cron": "0 9 * * *", "catchUp": "sometimes"}, false},
+		{PollVariety, map[string]string{"url": "https://example.com/items.json", "interval": "5m"}, true},
+		{PollVariety, map[string]string{"url": "https://example.com/items.json", "interval": "often"}, false},
+		{FeedVariety, map[string]string{"url": "ftp://example.com/feed.xml"}, false},
+		{WebhookVariety, map[string]string{"path": "/deploy/{env}"}, true},
+		{WebhookVariety, map[string]string{"path": "deploy"}, false},
+		{WebhookVariety, map[string]string{"mode": "later"}, false},
+		{CommandVariety, map[string]string{"command": "!deploy", "arguments": "env"}, true},
+		{CommandVariety, map[string]string{"command": "!deploy now"}, false},
+		{ReactionVariety, map[string]string{"rooms": "ops"}, false},
+	}
+
+	for _, table := range tables {
+		check, ok := checks[table.variety]
+		if !ok {
+			t.Fatalf("no check for variety %s", table.variety)
+		}
+
+		err := check(w.Workflow{Identifier: "CHECKED"}, model.Trigger{Variety: table.variety, Meta: table.meta})
+		if table.valid != (err == nil) {
+			t.Errorf("%s trigger with meta %v: expected valid: %t, got error: %v", table.variety, table.meta, table.valid, err)
+		}
+	}
+}
@@ -0,0 +1,346 @@
+package trigger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	netHttp "net/http"
+	"neurobot/infrastructure/event"
+	"neurobot/infrastructure/http"
+	"neurobot/model/payload"
+	"neurobot/model/run"
+	model "neurobot/model/trigger"
+	w "neurobot/model/workflow"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+)
+
+// WebhookVariety is the variety of triggers that start a workflow when a request is received by the webhook listener.
+const WebhookVariety = "webhook"
+
+// RunIDHeader is the response header holding the ID of the run a webhook request started, to look it up later.
+const RunIDHeader = "X-Run-ID"
+
+// Modes of webhook triggers: whether the response is sent once the workflow is queued, or once it ran.
+const (
+	AsyncWebhookMode = "async"
+	SyncWebhookMode  = "sync"
+)
+
+const defaultWebhookTimeout = 30 * time.Second
+
+// WebhookListener starts workflows which have a webhook trigger, when a request is made to one of their paths.
+type WebhookListener interface {
+	Listener
+
+	// Handle handles a request received by the webhook listener, with the payload parsed from it.
+	Handle(response netHttp.ResponseWriter, request *netHttp.Request, p payload.Payload)
+}
+
+type webhookListener struct {
+	triggerRepository  model.Repository
+	workflowRepository w.Repository
+	eventBus           event.Bus
+	replays            *http.ReplayCache // signed requests received recently, to refuse replays
+	mutex              sync.RWMutex
+	routes             []webhookRoute
+}
+
+// webhookRoute is where a webhook trigger listens for requests.
+type webhookRoute struct {
+	workflow      w.Workflow
+	path          http.PathPattern
+	methods       []string
+	authenticator http.Authenticator // nil when requests aren't authenticated
+	sync          bool               // whether the response waits for the run to finish
+	timeout       time.Duration      // how long to wait for the run
+	responseField string             // path of the payload value responded with in sync mode, empty for all of it
+}
+
+func NewWebhookListener(triggerRepository model.Repository, workflowRepository w.Repository, eventBus event.Bus) WebhookListener {
+	return &webhookListener{
+		triggerRepository:  triggerRepository,
+		workflowRepository: workflowRepository,
+		eventBus:           eventBus,
+		replays:            http.NewReplayCache(),
+	}
+}
+
+func (l *webhookListener) Start() error {
+	return start(l, l.triggerRepository, l.workflowRepository)
+}
+
+// Load builds the routes from the webhook triggers of active workflows, and for active workflows without any trigger,
+// requests are routed with them once swapped.
+func (l *webhookListener) Load(triggerRepository model.Repository, workflowRepository w.Repository) (func(), error) {
+	triggers, err := findActive(triggerRepository, workflowRepository, WebhookVariety)
+	if err != nil {
+		return nil, err
+	}
+
+	var routes []webhookRoute
+	for _, wt := range triggers {
+		route, err := newWebhookRoute(wt.workflow, wt.trigger, l.replays)
+		if err != nil {
+			return nil, fmt.Errorf("invalid webhook trigger for workflow %s: %w", wt.workflow.Identifier, err)
+		}
+
+		routes = append(routes, route)
+	}
+
+	defaults, err := defaultWebhookRoutes(triggerRepository, workflowRepository, l.replays)
+	if err != nil {
+		return nil, err
+	}
+	routes = append(routes, defaults...)
+
+	// Paths with fewer parameters are more specific, they are matched first
+	sort.SliceStable(routes, func(i, j int) bool {
+		return routes[i].path.Params() < routes[j].path.Params()
+	})
+
+	return func() {
+		l.mutex.Lock()
+		l.routes = routes
+		l.mutex.Unlock()
+	}, nil
+}
+
+// defaultWebhookRoutes routes requests to the identifier of active workflows which have no trigger at all, as every
+// workflow was started that way before workflows had triggers.
+func defaultWebhookRoutes(triggerRepository model.Repository, workflowRepository w.Repository, replays *http.ReplayCache) (routes []webhookRoute, err error) {
+	workflows, err := workflowRepository.FindActive()
+	if err != nil {
+		return nil, fmt.Errorf("error fetching active workflows: %w", err)
+	}
+
+	for _, workflow := range workflows {
+		triggers, err := triggerRepository.FindByWorkflowID(workflow.ID)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching triggers of workflow %s: %w", workflow.Identifier, err)
+		}
+		if len(triggers) > 0 {
+			continue
+		}
+
+		route, err := newWebhookRoute(workflow, model.Trigger{Variety: WebhookVariety}, replays)
+		if err != nil {
+			// The workflow didn't ask for a route, it isn't worth failing to load the others
+			log.WithError(err).WithField("workflow", workflow.Identifier).Warn("Workflow without triggers can't be started by requests to its identifier")
+			continue
+		}
+
+		routes = append(routes, route)
+	}
+
+	return
+}
+
+func (l *webhookListener) Stop() {
+	l.mutex.Lock()
+	l.routes = nil
+	l.mutex.Unlock()
+}
+
+func newWebhookRoute(workflow w.Workflow, trigger model.Trigger, replays *http.ReplayCache) (route webhookRoute, err error) {
+	route.workflow = workflow
+
+	if route.path, err = parseWebhookPath(workflow.Identifier, trigger.Meta); err != nil {
+		return
+	}
+
+	route.methods = []string{netHttp.MethodGet, netHttp.MethodPost}
+	if trigger.Meta["methods"] != "" {
+		route.methods = nil
+		for _, method := range splitList(trigger.Meta["methods"], false) {
+			route.methods = append(route.methods, strings.ToUpper(method))
+		}
+	}
+
+	switch trigger.Meta["mode"] {
+	case "", AsyncWebhookMode:
+	case SyncWebhookMode:
+		route.sync = true
+	default:
+		return route, fmt.Errorf("mode must be %s or %s, got %q", AsyncWebhookMode, SyncWebhookMode, trigger.Meta["mode"])
+	}
+
+	route.timeout = defaultWebhookTimeout
+	if trigger.Meta["timeout"] != "" {
+		if route.timeout, err = time.ParseDuration(trigger.Meta["timeout"]); err != nil {
+			return
+		}
+	}
+
+	route.responseField = trigger.Meta["response"]
+
+	route.authenticator, err = http.ParseAuthenticator(trigger.Meta, replays)
+
+	return
+}
+
+// parseWebhookPath returns the path a webhook trigger listens on: its `path`, or its `urlSuffix`, or the identifier of
+// its workflow.
+func parseWebhookPath(workflowIdentifier string, meta map[string]string) (http.PathPattern, error) {
+	path := meta["path"]
+	if path == "" && meta["urlSuffix"] != "" {
+		path = "/" + strings.TrimPrefix(meta["urlSuffix"], "/")
+	}
+	if path == "" {
+		path = "/" + workflowIdentifier
+	}
+
+	return http.ParsePathPattern(path)
+}
+
+// Handle starts the workflow of the route a request is made to, once the request is authenticated. When routes of more
+// than one trigger match the request, the first one which authenticates it is used. The parameters of the path are
+// added to the payload.
+func (l *webhookListener) Handle(response netHttp.ResponseWriter, request *netHttp.Request, p payload.Payload) {
+	l.mutex.RLock()
+	routes := l.routes
+	l.mutex.RUnlock()
+
+	var allowed []string
+	var body []byte
+	var err error
+	found := false
+	for _, route := range routes {
+		params, ok := route.path.Match(request.URL.Path)
+		if !ok {
+			continue
+		}
+		found = true
+
+		if !contains(route.methods, request.Method) {
+			allowed = append(allowed, route.methods...)
+			continue
+		}
+
+		if route.authenticator != nil {
+			if body == nil {
+				if body, err = ioutil.ReadAll(request.Body); err != nil {
+					netHttp.Error(response, "failed to read request body", netHttp.StatusBadRequest)
+					return
+				}
+			}
+
+			if err = route.authenticator.Authenticate(request, body); err != nil {
+				continue
+			}
+			if token, ok := route.authenticator.(http.QueryToken); ok {
+				delete(p, token.Parameter)
+			}
+		}
+
+		for name, value := range params {
+			p[name] = value
+		}
+
+		l.start(response, request, route, p)
+		return
+	}
+
+	switch {
+	case err != nil:
+		log.WithFields(log.Fields{"path": request.URL.Path}).WithError(err).Warn("Refused unauthenticated webhook request")
+		netHttp.Error(response, "unauthorized", netHttp.StatusUnauthorized)
+	case found:
+		response.Header().Set("Allow", strings.Join(unique(allowed), ", "))
+		netHttp.Error(response, fmt.Sprintf("method %s not allowed", request.Method), netHttp.StatusMethodNotAllowed)
+	default:
+		netHttp.Error(response, fmt.Sprintf("no workflow found for `%s`", request.URL.Path), netHttp.StatusNotFound)
+	}
+}
+
+// start starts the workflow of a route and responds with the ID of its run, once queued. In sync mode, the response
+// waits for the run to finish, and contains the payload it left, or a field of it, as JSON.
+func (l *webhookListener) start(response netHttp.ResponseWriter, request *netHttp.Request, route webhookRoute, p payload.Payload) {
+	queued := make(chan uint64, 1)
+	finished := make(chan run.Run, 1)
+
+	e := event.NewTrigger(route.workflow.Identifier, WebhookVariety, p)
+	e.Queued = queued
+	if route.sync {
+		e.Finished = finished
+	}
+	l.eventBus.Publish(event.TriggerTopic(), e)
+
+	ctx, cancel := context.WithTimeout(request.Context(), route.timeout)
+	defer cancel()
+
+	var runID uint64
+	select {
+	case runID = <-queued:
+	case <-ctx.Done():
+	}
+
+	if runID == 0 {
+		netHttp.Error(response, "failed to start workflow", netHttp.StatusInternalServerError)
+		return
+	}
+
+	response.Header().Set(RunIDHeader, strconv.FormatUint(runID, 10))
+	if !route.sync {
+		return
+	}
+
+	select {
+	case r := <-finished:
+		switch r.Status {
+		case run.StatusSucceeded:
+		case run.StatusQueued:
+			writeJSON(response, netHttp.StatusGatewayTimeout, map[string]interface{}{"run": r.ID, "status": r.Status, "error": "workflow was interrupted, it will run again once restarted"})
+			return
+		default:
+			writeJSON(response, netHttp.StatusInternalServerError, map[string]interface{}{"run": r.ID, "status": r.Status, "error": r.Error})
+			return
+		}
+
+		var result interface{} = r.Output
+		if route.responseField != "" {
+			result, _ = r.Output.Get(route.responseField)
+		}
+		writeJSON(response, netHttp.StatusOK, result)
+	case <-ctx.Done():
+		writeJSON(response, netHttp.StatusGatewayTimeout, map[string]interface{}{"run": runID, "status": run.StatusRunning, "error": "workflow didn't finish in time"})
+	}
+}
+
+func writeJSON(response netHttp.ResponseWriter, status int, value interface{}) {
+	body, err := json.Marshal(value)
+	if err != nil {
+		netHttp.Error(response, "failed to encode response", netHttp.StatusInternalServerError)
+		return
+	}
+
+	response.Header().Set("Content-Type", "application/json")
+	response.WriteHeader(status)
+	response.Write(body)
+}
+
+func contains(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+
+	return false
+}
+
+func unique(items []string) (result []string) {
+	for _, item := range items {
+		if !contains(result, item) {
+			result = append(result, item)
+		}
+	}
+
+	return
+}
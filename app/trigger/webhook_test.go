@@ -0,0 +1,298 @@
+package trigger
+
+import (
+	"encoding/json"
+	netHttp "net/http"
+	"net/http/httptest"
+	"neurobot/app/workflow"
+	"neurobot/infrastructure/event"
+	"neurobot/model/payload"
+	"neurobot/model/run"
+	model "neurobot/model/trigger"
+	w "neurobot/model/workflow"
+	"neurobot/resources/tests/database"
+	"neurobot/resources/tests/fixtures"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/upper/db/v4"
+)
+
+func TestNewWebhookRoute(t *testing.T) {
+	route, err := newWebhookRoute(w.Workflow{Identifier: "deploy"}, model.Trigger{Variety: WebhookVariety, Meta: map[string]string{}}, nil)
+	if err != nil {
+		t.Fatalf("webhook trigger should be valid, got: %s", err)
+	}
+	if route.path.String() != "/deploy" || !reflect.DeepEqual(route.methods, []string{"GET", "POST"}) || route.authenticator != nil {
+		t.Errorf("unexpected route %+v", route)
+	}
+	if route.sync || route.timeout != 30*time.Second {
+		t.Errorf("unexpected route %+v", route)
+	}
+
+	route, err = newWebhookRoute(w.Workflow{Identifier: "deploy"}, model.Trigger{Variety: WebhookVariety, Meta: map[string]string{
+		"urlSuffix": "ship",
+		"methods":   "put, post",
+		"mode":      "sync",
+		"timeout":   "5s",
+		"response":  "response.body",
+	}}, nil)
+	if err != nil {
+		t.Fatalf("webhook trigger should be valid, got: %s", err)
+	}
+	if route.path.String() != "/ship" || !reflect.DeepEqual(route.methods, []string{"PUT", "POST"}) {
+		t.Errorf("unexpected route %+v", route)
+	}
+	if !route.sync || route.timeout != 5*time.Second || route.responseField != "response.body" {
+		t.Errorf("unexpected route %+v", route)
+	}
+
+	invalid := []map[string]string{
+		{"path": "deploy"},
+		{"path": "/deploy/{env"},
+		{"path": "/deploy/{env}/{env}"},
+		{"auth": "bearer"},
+		{"mode": "later"},
+		{"timeout": "soon"},
+	}
+
+	for _, meta := range invalid {
+		if _, err = newWebhookRoute(w.Workflow{Identifier: "deploy"}, model.Trigger{Variety: WebhookVariety, Meta: meta}, nil); err == nil {
+			t.Errorf("webhook trigger with meta %+v should be invalid", meta)
+		}
+	}
+}
+
+func TestHandleWebhook(t *testing.T) {
+	database.Test(func(session db.Session) {
+		fixtures.Workflows(session)
+		triggerRepository := NewRepository(session)
+
+		for _, trigger := range []model.Trigger{
+			{WorkflowID: 1, Variety: WebhookVariety, Meta: map[string]string{"urlSuffix": "quickstart"}},
+			{WorkflowID: 11, Variety: WebhookVariety, Meta: map[string]string{"path": "/deploy/{env}", "methods": "POST", "auth": "bearer", "secret": "s3cret"}},
+			{WorkflowID: 11, Variety: WebhookVariety, Meta: map[string]string{"path": "/deploy/{env}", "methods": "POST", "auth": "query", "secret": "t0ken"}},
+			{WorkflowID: 1, Variety: WebhookVariety, Meta: map[string]string{"path": "/deploy/staging", "methods": "POST"}},
+			{WorkflowID: 11, Variety: WebhookVariety, Meta: map[string]string{}},
+			{WorkflowID: 12, Variety: WebhookVariety, Meta: map[string]string{"path": "/deactivated"}},
+		} {
+			if err := triggerRepository.Save(&trigger); err != nil {
+				t.Fatalf("failed to save trigger: %s", err)
+			}
+		}
+
+		bus, got := collectTriggers()
+		l := NewWebhookListener(triggerRepository, workflow.NewRepository(session), bus)
+		if err := l.Start(); err != nil {
+			t.Fatalf("failed to start webhook listener: %s", err)
+		}
+
+		tables := []struct {
+			name     string
+			method   string
+			target   string
+			headers  map[string]string
+			status   int
+			workflow string
+			expected payload.Payload
+		}{
+			{name: "url suffix", method: "POST", target: "/quickstart", status: 200, workflow: "QUICKSTART", expected: payload.Payload{"message": "hello"}},
+			{name: "trailing slash", method: "GET", target: "/quickstart/", status: 200, workflow: "QUICKSTART", expected: payload.Payload{"message": "hello"}},
+			{name: "identifier", method: "POST", target: "/MVP", status: 200, workflow: "MVP", expected: payload.Payload{"message": "hello"}},
+			{
+				name:     "path parameter",
+				method:   "POST",
+				target:   "/deploy/production",
+				headers:  map[string]string{"Authorization": "Bearer s3cret"},
+				status:   200,
+				workflow: "MVP",
+				expected: payload.Payload{"message": "hello", "env": "production"},
+			},
+			{
+				name:     "path parameter with query token",
+				method:   "POST",
+				target:   "/deploy/production?token=t0ken",
+				status:   200,
+				workflow: "MVP",
+				expected: payload.Payload{"message": "hello", "env": "production"},
+			},
+			{name: "static path first", method: "POST", target: "/deploy/staging", status: 200, workflow: "QUICKSTART", expected: payload.Payload{"message": "hello"}},
+			{name: "unauthenticated", method: "POST", target: "/deploy/production", status: 401},
+			{name: "method not allowed", method: "GET", target: "/deploy/production", status: 405},
+			{name: "inactive workflow", method: "POST", target: "/deactivated", status: 404},
+			{name: "workflow without triggers", method: "POST", target: "/TOMLTEST1", status: 200, workflow: "TOMLTEST1", expected: payload.Payload{"message": "hello"}},
+			{name: "inactive workflow without triggers", method: "POST", target: "/TOMLTEST2", status: 404},
+			{name: "unknown path", method: "POST", target: "/deploy", status: 404},
+		}
+
+		for _, table := range tables {
+			*got = nil
+
+			request := httptest.NewRequest(table.method, table.target, strings.NewReader(`{"message": "hello"}`))
+			for name, value := range table.headers {
+				request.Header.Set(name, value)
+			}
+			p := payload.Payload{"message": "hello"}
+			for key, values := range request.URL.Query() {
+				p[key] = values[0]
+			}
+
+			recorder := httptest.NewRecorder()
+			l.Handle(recorder, request, p)
+
+			if recorder.Code != table.status {
+				t.Errorf("%s: expected status %d, got %d", table.name, table.status, recorder.Code)
+				continue
+			}
+
+			if table.expected == nil {
+				if len(*got) > 0 {
+					t.Errorf("%s: expected no workflow to start, got %+v", table.name, *got)
+				}
+				continue
+			}
+
+			if len(*got) != 1 || (*got)[0].WorkflowIdentifier != table.workflow || !reflect.DeepEqual((*got)[0].Payload, table.expected) {
+				t.Errorf("%s: expected %s to start with %+v, got %+v", table.name, table.workflow, table.expected, *got)
+			}
+			if recorder.Header().Get(RunIDHeader) != "1" {
+				t.Errorf("%s: expected the run ID in the response, got %q", table.name, recorder.Header().Get(RunIDHeader))
+			}
+		}
+
+		// Routes of triggers saved since the listener started are only known once loaded and swapped.
+		trigger := model.Trigger{WorkflowID: 11, Variety: WebhookVariety, Meta: map[string]string{"path": "/mvp/{action}"}}
+		if err := triggerRepository.Save(&trigger); err != nil {
+			t.Fatalf("failed to save trigger: %s", err)
+		}
+
+		handle := func() int {
+			recorder := httptest.NewRecorder()
+			l.Handle(recorder, httptest.NewRequest(netHttp.MethodGet, "/mvp/start", nil), payload.Payload{})
+			return recorder.Code
+		}
+
+		swap, err := l.Load(triggerRepository, workflow.NewRepository(session))
+		if err != nil {
+			t.Fatalf("failed to load webhook listener: %s", err)
+		}
+		if code := handle(); code != netHttp.StatusNotFound {
+			t.Errorf("expected status 404 before swapping, got %d", code)
+		}
+
+		swap()
+		if code := handle(); code != netHttp.StatusOK {
+			t.Errorf("expected status 200 once swapped, got %d", code)
+		}
+
+		// A trigger failing to load leaves the routes as they were
+		invalid := model.Trigger{WorkflowID: 1, Variety: WebhookVariety, Meta: map[string]string{"path": "/broken", "mode": "later"}}
+		if err = triggerRepository.Save(&invalid); err != nil {
+			t.Fatalf("failed to save trigger: %s", err)
+		}
+		if _, err = l.Load(triggerRepository, workflow.NewRepository(session)); err == nil {
+			t.Errorf("expected loading an invalid trigger to fail")
+		}
+		if code := handle(); code != netHttp.StatusOK {
+			t.Errorf("expected status 200 after a failed load, got %d", code)
+		}
+	})
+}
+
+func TestHandleSyncWebhook(t *testing.T) {
+	workflow := w.Workflow{ID: 1, Identifier: "deploy"}
+	newRoute := func(meta map[string]string) webhookRoute {
+		meta["mode"] = SyncWebhookMode
+		route, err := newWebhookRoute(workflow, model.Trigger{Variety: WebhookVariety, Meta: meta}, nil)
+		if err != nil {
+			t.Fatalf("webhook trigger should be valid, got: %s", err)
+		}
+		return route
+	}
+
+	tables := []struct {
+		name     string
+		route    webhookRoute
+		finish   func(e event.Trigger) // how the run ends, it never does when nil
+		status   int
+		expected string
+	}{
+		{
+			name:  "whole payload",
+			route: newRoute(map[string]string{}),
+			finish: func(e event.Trigger) {
+				output := e.Payload.Clone()
+				output.Set("response.status", json.Number("201"))
+				e.Finished <- run.Run{ID: 7, Status: run.StatusSucceeded, Output: output}
+			},
+			status:   200,
+			expected: `{"env":"production","response":{"status":201}}`,
+		},
+		{
+			name:  "field",
+			route: newRoute(map[string]string{"response": "response"}),
+			finish: func(e event.Trigger) {
+				e.Finished <- run.Run{ID: 7, Status: run.StatusSucceeded, Output: payload.Payload{"response": map[string]interface{}{"url": "https://example.com"}}}
+			},
+			status:   200,
+			expected: `{"url":"https://example.com"}`,
+		},
+		{
+			name:  "missing field",
+			route: newRoute(map[string]string{"response": "missing"}),
+			finish: func(e event.Trigger) {
+				e.Finished <- run.Run{ID: 7, Status: run.StatusSucceeded, Output: payload.Payload{}}
+			},
+			status:   200,
+			expected: `null`,
+		},
+		{
+			name:  "failed",
+			route: newRoute(map[string]string{}),
+			finish: func(e event.Trigger) {
+				e.Finished <- run.Run{ID: 7, Status: run.StatusFailed, Error: "step 1 failed"}
+			},
+			status:   500,
+			expected: `{"error":"step 1 failed","run":7,"status":"failed"}`,
+		},
+		{
+			name:  "interrupted",
+			route: newRoute(map[string]string{}),
+			finish: func(e event.Trigger) {
+				e.Finished <- run.Run{ID: 7, Status: run.StatusQueued}
+			},
+			status:   504,
+			expected: `{"error":"workflow was interrupted, it will run again once restarted","run":7,"status":"queued"}`,
+		},
+		{
+			name:     "timeout",
+			route:    newRoute(map[string]string{"timeout": "10ms"}),
+			status:   504,
+			expected: `{"error":"workflow didn't finish in time","run":7,"status":"running"}`,
+		},
+	}
+
+	for _, table := range tables {
+		bus := event.NewMemoryBus()
+		bus.Subscribe(event.TriggerTopic(), func(e interface{}) {
+			trigger := e.(event.Trigger)
+			trigger.Queued <- 7
+			if table.finish != nil {
+				go table.finish(trigger)
+			}
+		})
+
+		l := &webhookListener{eventBus: bus, routes: []webhookRoute{table.route}}
+		recorder := httptest.NewRecorder()
+		l.Handle(recorder, httptest.NewRequest(netHttp.MethodPost, "/deploy", nil), payload.Payload{"env": "production"})
+
+		if recorder.Code != table.status || strings.TrimSpace(recorder.Body.String()) != table.expected {
+			t.Errorf("%s: expected %d %s, got %d %s", table.name, table.status, table.expected, recorder.Code, recorder.Body)
+		}
+		if recorder.Header().Get(RunIDHeader) != "7" {
+			t.Errorf("%s: expected the run ID in the response, got %q", table.name, recorder.Header().Get(RunIDHeader))
+		}
+	}
+}
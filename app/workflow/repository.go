@@ -16,6 +16,15 @@ func NewRepository(session db.Session) model.Repository {
 	}
 }
 
+func (repository *repository) FindAll() (workflows []model.Workflow, err error) {
+	result := repository.collection.Find().OrderBy("id")
+	if err := result.All(&workflows); err != nil {
+		return nil, err
+	}
+
+	return
+}
+
 func (repository *repository) FindActive() (workflows []model.Workflow, err error) {
 	result := repository.collection.Find(db.Cond{"active": 1})
 	if err := result.All(&workflows); err != nil {
@@ -39,6 +48,10 @@ func (repository *repository) FindByIdentifier(identifier string) (workflow mode
 	return
 }
 
+func (repository *repository) Remove(ID uint64) error {
+	return repository.collection.Find(db.Cond{"id": ID}).Delete()
+}
+
 func (repository *repository) Save(workflow *model.Workflow) error {
 	if workflow.ID > 0 {
 		return repository.update(workflow)
@@ -59,6 +72,10 @@ func (repository *repository) update(workflow *model.Workflow) (err error) {
 	existing.Description = workflow.Description
 	existing.Active = workflow.Active
 	existing.Identifier = workflow.Identifier
+	existing.OnError = workflow.OnError
+	existing.MaxAttempts = workflow.MaxAttempts
+	existing.RetryBackoff = workflow.RetryBackoff
+	existing.Timeout = workflow.Timeout
 
 	err = result.Update(existing)
 
@@ -125,3 +125,42 @@ func TestUpdate(t *testing.T) {
 		}
 	})
 }
+
+func TestFindAll(t *testing.T) {
+	database.Test(func(session db.Session) {
+		workflows := fixtures.Workflows(session)
+		repository := NewRepository(session)
+
+		got, err := repository.FindAll()
+		if err != nil {
+			t.Errorf("failed to get workflows: %s", err)
+		}
+
+		if len(got) != len(workflows) {
+			t.Errorf("expected %d workflows, got %d", len(workflows), len(got))
+		}
+
+		if len(got) > 2 && !reflect.DeepEqual(got[2], workflows["Deactivated Workflow"]) {
+			t.Errorf("inactive workflows should be found, in the order of their ID")
+		}
+	})
+}
+
+func TestRemove(t *testing.T) {
+	database.Test(func(session db.Session) {
+		fixtures.Workflows(session)
+		repository := NewRepository(session)
+
+		if err := repository.Remove(11); err != nil {
+			t.Errorf("failed to remove workflow: %s", err)
+		}
+
+		if _, err := repository.FindByID(11); err == nil {
+			t.Errorf("workflow was not removed")
+		}
+
+		if _, err := repository.FindByID(1); err != nil {
+			t.Errorf("other workflows should not be removed, got: %s", err)
+		}
+	})
+}
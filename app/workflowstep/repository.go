@@ -89,6 +89,11 @@ func (repository *repository) update(step *model.WorkflowStep) (err error) {
 	existing.Active = step.Active
 	existing.Variety = step.Variety
 	existing.SortOrder = step.SortOrder
+	existing.Condition = step.Condition
+	existing.OnError = step.OnError
+	existing.MaxAttempts = step.MaxAttempts
+	existing.RetryBackoff = step.RetryBackoff
+	existing.Timeout = step.Timeout
 
 	if err = result.Update(existing); err != nil {
 		return
@@ -172,3 +177,11 @@ func (repository *repository) RemoveByWorkflowID(ID uint64) (err error) {
 
 	return
 }
+
+func (repository *repository) Remove(ID uint64) (err error) {
+	if err = repository.collection.Find(db.Cond{"id": ID}).Delete(); err != nil {
+		return
+	}
+
+	return repository.collectionMeta.Find(db.Cond{"step_id": ID}).Delete()
+}
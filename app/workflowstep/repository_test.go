@@ -218,3 +218,31 @@ func TestRemoveByWorkflowID(t *testing.T) {
 		}
 	})
 }
+
+func TestRemove(t *testing.T) {
+	database.Test(func(session db.Session) {
+		steps := fixtures.WorkflowSteps(session)
+		repository := NewRepository(session)
+
+		if err := repository.Remove(steps["PostMessage1"].ID); err != nil {
+			t.Errorf("failed to remove workflow step: %s", err)
+		}
+
+		if _, err := repository.FindByID(steps["PostMessage1"].ID); err == nil {
+			t.Errorf("workflow step was not removed")
+		}
+
+		gotCount, err := session.Collection(workflowStepMetaTableName).Find(db.Cond{"step_id": steps["PostMessage1"].ID}).Count()
+		if err != nil {
+			t.Errorf("could not get data out of workflow step meta table")
+		}
+
+		if gotCount > 0 {
+			t.Errorf("workflow step meta was not deleted")
+		}
+
+		if _, err := repository.FindByID(steps["PostMessage3"].ID); err != nil {
+			t.Errorf("other workflow steps should not be removed, got: %s", err)
+		}
+	})
+}
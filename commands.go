@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	configuration "neurobot/app/config"
+	"neurobot/app/engine/steps"
+	"neurobot/app/trigger"
+	"neurobot/app/workflow"
+	"neurobot/app/workflowstep"
+	"neurobot/infrastructure/database"
+	"neurobot/infrastructure/toml"
+	"neurobot/resources/seeds"
+	"os"
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/upper/db/v4"
+)
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [-env .env] [command]\n\n", os.Args[0])
+	fmt.Fprintln(out, "Runs the workflows when no command is given. Commands:")
+	fmt.Fprintln(out, "  export [-o file]              writes the workflows of the database as a TOML file, to the standard output by default")
+	fmt.Fprintln(out, "  validate [-bots names] [file] checks a TOML file, the one of the .env file by default")
+	fmt.Fprintln(out, "  diff [-exit-code] [file]      shows what importing a TOML file would change, the one of the .env file by default")
+	fmt.Fprintln(out, "\nFlags:")
+	flag.PrintDefaults()
+}
+
+// export writes the workflows of the database as a TOML file, which can be imported back as it is. It doesn't connect
+// to Matrix.
+func export(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	output := flags.String("o", "", "file to write the workflows to, instead of the standard output")
+	flags.Parse(args)
+
+	config := configuration.LoadOfflineFromEnvFile(*envFile)
+	databaseSession := database.MakeDatabaseSession(config.DatabasePath)
+	defer databaseSession.Close()
+
+	var writer io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			log.WithError(err).Fatal("Failed to create export file")
+		}
+		defer file.Close()
+
+		writer = file
+	}
+
+	err := toml.Export(writer, workflow.NewRepository(databaseSession), workflowstep.NewRepository(databaseSession), trigger.NewRepository(databaseSession))
+	if err != nil {
+		log.WithError(err).Fatal("Failed to export workflows")
+	}
+}
+
+// validate checks a TOML file without the database nor Matrix, so that it can be checked before being deployed. Bots
+// known are the seeded ones, the primary one when the .env file exists, and the ones given. It exits with 1 when the
+// file has problems.
+func validate(args []string) {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	botNames := flags.String("bots", "", "comma separated usernames of other bots triggers and steps can act as, like the ones added through the admin API")
+	flags.Parse(args)
+
+	path := flags.Arg(0)
+	bots := seeds.BotUsernames()
+	for _, bot := range strings.Split(*botNames, ",") {
+		if bot = strings.TrimSpace(bot); bot != "" {
+			bots = append(bots, bot)
+		}
+	}
+	if _, err := os.Stat(*envFile); err == nil {
+		config := configuration.LoadOfflineFromEnvFile(*envFile)
+		bots = append(bots, config.PrimaryBotUsername)
+		if path == "" {
+			path = config.WorkflowsTOMLPath
+		}
+	}
+	if path == "" {
+		log.Fatal("No TOML file to validate, give its path or a .env file")
+	}
+
+	problems := toml.Validate(path, toml.Environment{
+		StepSchemas:   steps.Schemas(),
+		TriggerChecks: trigger.Checks(),
+		Bots:          bots,
+	})
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+
+	if len(problems) > 0 {
+		fmt.Printf("%s has %d problem(s)\n", path, len(problems))
+		os.Exit(1)
+	}
+	fmt.Printf("%s is valid\n", path)
+}
+
+// errRollback rolls back the transaction diff imports the TOML file within.
+var errRollback = errors.New("rollback")
+
+// diff shows what importing a TOML file would change in the database, without changing it nor connecting to Matrix.
+func diff(args []string) {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	exitCode := flags.Bool("exit-code", false, "exit with 1 when importing would change anything")
+	flags.Parse(args)
+
+	config := configuration.LoadOfflineFromEnvFile(*envFile)
+	path := flags.Arg(0)
+	if path == "" {
+		path = config.WorkflowsTOMLPath
+	}
+
+	// The database is only read, it must not even be migrated
+	databaseSession, err := database.OpenMigratedDatabaseSession(config.DatabasePath)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to open database")
+	}
+
+	var summary toml.Summary
+	err = databaseSession.Tx(func(tx db.Session) (err error) {
+		summary, err = toml.Diff(
+			os.Stdout,
+			path,
+			toml.Prune(config.Prune),
+			workflow.NewRepository(tx),
+			workflowstep.NewRepository(tx),
+			trigger.NewRepository(tx),
+			steps.Schemas(),
+			trigger.Checks(),
+		)
+		if err != nil {
+			return
+		}
+
+		return errRollback
+	})
+	if closeErr := databaseSession.Close(); closeErr != nil {
+		log.WithError(closeErr).Error("Failed to close database session")
+	}
+	if !errors.Is(err, errRollback) {
+		log.WithError(err).WithFields(log.Fields{"path": path}).Fatal("Failed to diff TOML workflows")
+	}
+
+	fmt.Println(summary)
+	if *exitCode && summary.Changed() {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,360 @@
+package condition
+
+import (
+	"fmt"
+	"neurobot/model/payload"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Condition is a boolean expression evaluated against a payload, like `payload.severity == "critical"`.
+//
+// Values are payload paths prefixed with `payload.` (missing ones are empty), double-quoted strings, numbers, `true`
+// and `false`. They can be compared with `==`, `!=`, `<`, `<=`, `>` and `>=`, numerically when both sides are numbers,
+// and combined with `&&`, `||`, `!` and parentheses. A value on its own is true unless it is empty, `false` or `0`.
+type Condition interface {
+	// Evaluate tells whether the payload matches the condition.
+	Evaluate(p payload.Payload) bool
+}
+
+// Parse parses a condition expression.
+func Parse(expression string) (Condition, error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.done() {
+		return nil, fmt.Errorf("unexpected %q in condition", p.peek().text)
+	}
+
+	return condition{root: n}, nil
+}
+
+type condition struct {
+	root node
+}
+
+func (c condition) Evaluate(p payload.Payload) bool {
+	return truthy(c.root.eval(p))
+}
+
+type tokenKind int
+
+const (
+	operatorToken tokenKind = iota
+	stringToken
+	numberToken
+	identifierToken
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+var operators = []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!", "(", ")"}
+
+func tokenize(expression string) (tokens []token, err error) {
+	runes := []rune(expression)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		rest := string(runes[i:])
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '"':
+			end := i + 1
+			for ; end < len(runes) && runes[end] != '"'; end++ {
+				if runes[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(runes) {
+				return nil, fmt.Errorf("unterminated string in condition")
+			}
+
+			value, err := strconv.Unquote(string(runes[i : end+1]))
+			if err != nil {
+				return nil, fmt.Errorf("invalid string %s in condition", string(runes[i:end+1]))
+			}
+			tokens = append(tokens, token{kind: stringToken, text: value})
+			i = end + 1
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			end := i + 1
+			for end < len(runes) && (unicode.IsDigit(runes[end]) || runes[end] == '.') {
+				end++
+			}
+
+			text := string(runes[i:end])
+			if _, err := strconv.ParseFloat(text, 64); err != nil {
+				return nil, fmt.Errorf("invalid number %s in condition", text)
+			}
+			tokens = append(tokens, token{kind: numberToken, text: text})
+			i = end
+		case unicode.IsLetter(r) || r == '_':
+			end := i + 1
+			for end < len(runes) && isIdentifierRune(runes[end]) {
+				end++
+			}
+			tokens = append(tokens, token{kind: identifierToken, text: string(runes[i:end])})
+			i = end
+		default:
+			matched := false
+			for _, operator := range operators {
+				if strings.HasPrefix(rest, operator) {
+					tokens = append(tokens, token{kind: operatorToken, text: operator})
+					i += len([]rune(operator))
+					matched = true
+					break
+				}
+			}
+
+			if !matched {
+				return nil, fmt.Errorf("unexpected %q in condition", string(r))
+			}
+		}
+	}
+
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("condition is empty")
+	}
+
+	return
+}
+
+func isIdentifierRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.' || r == '-'
+}
+
+type parser struct {
+	tokens   []token
+	position int
+}
+
+func (p *parser) done() bool {
+	return p.position >= len(p.tokens)
+}
+
+func (p *parser) peek() token {
+	if p.done() {
+		return token{}
+	}
+
+	return p.tokens[p.position]
+}
+
+func (p *parser) accept(operator string) bool {
+	if t := p.peek(); !p.done() && t.kind == operatorToken && t.text == operator {
+		p.position++
+		return true
+	}
+
+	return false
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.accept("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.accept("&&") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.accept("!") {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+
+		return notNode{operand: operand}, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, operator := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.accept(operator) {
+			right, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+
+			return comparisonNode{operator: operator, left: left, right: right}, nil
+		}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseOperand() (node, error) {
+	if p.done() {
+		return nil, fmt.Errorf("unexpected end of condition")
+	}
+
+	if p.accept("(") {
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if !p.accept(")") {
+			return nil, fmt.Errorf("missing ) in condition")
+		}
+
+		return inner, nil
+	}
+
+	t := p.peek()
+	p.position++
+
+	switch t.kind {
+	case stringToken, numberToken:
+		return literalNode{value: t.text}, nil
+	case identifierToken:
+		switch {
+		case t.text == "true" || t.text == "false":
+			return literalNode{value: t.text}, nil
+		case strings.HasPrefix(t.text, "payload.") && len(t.text) > len("payload."):
+			return payloadNode{key: strings.TrimPrefix(t.text, "payload.")}, nil
+		}
+
+		return nil, fmt.Errorf("unknown value %q in condition, payload keys must be prefixed with `payload.`", t.text)
+	}
+
+	return nil, fmt.Errorf("unexpected %q in condition", t.text)
+}
+
+// node is a part of a condition, it evaluates to a string.
+type node interface {
+	eval(p payload.Payload) string
+}
+
+type literalNode struct {
+	value string
+}
+
+func (n literalNode) eval(payload.Payload) string {
+	return n.value
+}
+
+type payloadNode struct {
+	key string
+}
+
+func (n payloadNode) eval(p payload.Payload) string {
+	return p.String(n.key)
+}
+
+type notNode struct {
+	operand node
+}
+
+func (n notNode) eval(p payload.Payload) string {
+	return strconv.FormatBool(!truthy(n.operand.eval(p)))
+}
+
+type andNode struct {
+	left  node
+	right node
+}
+
+func (n andNode) eval(p payload.Payload) string {
+	return strconv.FormatBool(truthy(n.left.eval(p)) && truthy(n.right.eval(p)))
+}
+
+type orNode struct {
+	left  node
+	right node
+}
+
+func (n orNode) eval(p payload.Payload) string {
+	return strconv.FormatBool(truthy(n.left.eval(p)) || truthy(n.right.eval(p)))
+}
+
+type comparisonNode struct {
+	operator string
+	left     node
+	right    node
+}
+
+func (n comparisonNode) eval(p payload.Payload) string {
+	left, right := n.left.eval(p), n.right.eval(p)
+
+	order := strings.Compare(left, right)
+	leftNumber, leftErr := strconv.ParseFloat(left, 64)
+	rightNumber, rightErr := strconv.ParseFloat(right, 64)
+	if leftErr == nil && rightErr == nil {
+		switch {
+		case leftNumber < rightNumber:
+			order = -1
+		case leftNumber > rightNumber:
+			order = 1
+		default:
+			order = 0
+		}
+	}
+
+	var result bool
+	switch n.operator {
+	case "==":
+		result = order == 0
+	case "!=":
+		result = order != 0
+	case "<":
+		result = order < 0
+	case "<=":
+		result = order <= 0
+	case ">":
+		result = order > 0
+	case ">=":
+		result = order >= 0
+	}
+
+	return strconv.FormatBool(result)
+}
+
+func truthy(value string) bool {
+	return value != "" && value != "false" && value != "0"
+}
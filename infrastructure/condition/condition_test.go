@@ -0,0 +1,74 @@
+package condition
+
+import (
+	"encoding/json"
+	"neurobot/model/payload"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	p := payload.Payload{
+		"severity": "critical",
+		"count":    json.Number("10"),
+		"user":     map[string]interface{}{"login": "alice"},
+		"ok":       true,
+		"empty":    "",
+	}
+
+	tables := []struct {
+		expression string
+		expected   bool
+	}{
+		{expression: `payload.severity == "critical"`, expected: true},
+		{expression: `payload.severity != "critical"`, expected: false},
+		{expression: `payload.user.login == "alice"`, expected: true},
+		{expression: `payload.missing == ""`, expected: true},
+		{expression: `payload.count > 9`, expected: true},
+		{expression: `payload.count > "9"`, expected: true}, // numeric, even quoted
+		{expression: `payload.count <= 9.5`, expected: false},
+		{expression: `payload.count == 10.0`, expected: true},
+		{expression: `payload.severity > "b"`, expected: true},
+		{expression: `payload.ok`, expected: true},
+		{expression: `payload.empty`, expected: false},
+		{expression: `!payload.missing`, expected: true},
+		{expression: `payload.ok == true`, expected: true},
+		{expression: `payload.severity == "warning" || payload.count >= 10`, expected: true},
+		{expression: `payload.severity == "critical" && payload.count < 10`, expected: false},
+		{expression: `!(payload.severity == "warning" || payload.empty) && payload.ok`, expected: true},
+		{expression: `payload.count == -1`, expected: false},
+		{expression: `"a \" b" == "a \" b"`, expected: true},
+	}
+
+	for _, table := range tables {
+		c, err := Parse(table.expression)
+		if err != nil {
+			t.Errorf("%s: failed to parse: %s", table.expression, err)
+			continue
+		}
+
+		if got := c.Evaluate(p); got != table.expected {
+			t.Errorf("%s: expected %t, got %t", table.expression, table.expected, got)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	invalid := []string{
+		``,
+		`severity == "critical"`,
+		`payload.`,
+		`payload.severity ==`,
+		`payload.severity = "critical"`,
+		`(payload.ok`,
+		`payload.ok)`,
+		`payload.ok payload.ok`,
+		`"unterminated`,
+		`1.2.3 == 1`,
+	}
+
+	for _, expression := range invalid {
+		if _, err := Parse(expression); err == nil {
+			t.Errorf("%s: parsing should fail", expression)
+		}
+	}
+}
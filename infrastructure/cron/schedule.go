@@ -0,0 +1,220 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule computes the activation times of a cron expression.
+type Schedule interface {
+	// Next returns the first activation time strictly after t, or the zero time if there is none within the next
+	// five years (e.g. 30th of February).
+	Next(t time.Time) time.Time
+}
+
+type bounds struct {
+	min   int
+	max   int
+	names map[string]int
+}
+
+var (
+	minuteBounds = bounds{min: 0, max: 59}
+	hourBounds   = bounds{min: 0, max: 23}
+	domBounds    = bounds{min: 1, max: 31}
+	monthBounds  = bounds{min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// 7 is accepted as an alias of 0 (Sunday).
+	dowBounds = bounds{min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// field is a bitset of the values that match a single field of the expression.
+type field uint64
+
+func (f field) has(value int) bool {
+	return f&(1<<uint(value)) > 0
+}
+
+type schedule struct {
+	minute   field
+	hour     field
+	dom      field
+	month    field
+	dow      field
+	domStar  bool
+	dowStar  bool
+	location *time.Location
+}
+
+// Parse parses a standard five field cron expression (minute, hour, day of month, month, day of week), or one of the
+// @yearly, @monthly, @weekly, @daily and @hourly descriptors. Times are evaluated in the given location.
+func Parse(expression string, location *time.Location) (Schedule, error) {
+	if location == nil {
+		location = time.UTC
+	}
+
+	expression = strings.TrimSpace(expression)
+	if expanded, ok := descriptors[strings.ToLower(expression)]; ok {
+		expression = expanded
+	}
+
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression must have 5 fields, got %d in %q", len(fields), expression)
+	}
+
+	s := &schedule{location: location}
+
+	var err error
+	if s.minute, err = parseField(fields[0], minuteBounds); err != nil {
+		return nil, fmt.Errorf("invalid minute field: %w", err)
+	}
+	if s.hour, err = parseField(fields[1], hourBounds); err != nil {
+		return nil, fmt.Errorf("invalid hour field: %w", err)
+	}
+	if s.dom, err = parseField(fields[2], domBounds); err != nil {
+		return nil, fmt.Errorf("invalid day of month field: %w", err)
+	}
+	if s.month, err = parseField(fields[3], monthBounds); err != nil {
+		return nil, fmt.Errorf("invalid month field: %w", err)
+	}
+	if s.dow, err = parseField(fields[4], dowBounds); err != nil {
+		return nil, fmt.Errorf("invalid day of week field: %w", err)
+	}
+
+	if s.dow.has(7) {
+		s.dow |= 1
+	}
+
+	s.domStar = strings.HasPrefix(fields[2], "*")
+	s.dowStar = strings.HasPrefix(fields[4], "*")
+
+	return s, nil
+}
+
+func parseField(value string, b bounds) (f field, err error) {
+	for _, part := range strings.Split(value, ",") {
+		var bits field
+		if bits, err = parseRange(part, b); err != nil {
+			return
+		}
+		f |= bits
+	}
+
+	return
+}
+
+func parseRange(value string, b bounds) (f field, err error) {
+	step := 1
+	if parts := strings.SplitN(value, "/", 2); len(parts) == 2 {
+		value = parts[0]
+		if step, err = strconv.Atoi(parts[1]); err != nil || step <= 0 {
+			return 0, fmt.Errorf("invalid step in %q", value+"/"+parts[1])
+		}
+	}
+
+	start, end := b.min, b.max
+	if value != "*" {
+		bounds := strings.SplitN(value, "-", 2)
+		if start, err = parseValue(bounds[0], b); err != nil {
+			return
+		}
+
+		end = start
+		if len(bounds) == 2 {
+			if end, err = parseValue(bounds[1], b); err != nil {
+				return
+			}
+		} else if step > 1 {
+			// "5/15" means every 15th value starting from 5.
+			end = b.max
+		}
+	}
+
+	if start > end {
+		return 0, fmt.Errorf("range start %d is beyond range end %d", start, end)
+	}
+
+	for i := start; i <= end; i += step {
+		f |= 1 << uint(i)
+	}
+
+	return
+}
+
+func parseValue(value string, b bounds) (int, error) {
+	if number, ok := b.names[strings.ToLower(value)]; ok {
+		return number, nil
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", value)
+	}
+
+	if number < b.min || number > b.max {
+		return 0, fmt.Errorf("value %d out of range [%d, %d]", number, b.min, b.max)
+	}
+
+	return number, nil
+}
+
+func (s *schedule) Next(t time.Time) time.Time {
+	t = t.In(s.location).Truncate(time.Minute).Add(time.Minute)
+	yearLimit := t.Year() + 5
+
+	for t.Year() <= yearLimit {
+		if !s.month.has(int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, s.location)
+			continue
+		}
+
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.location)
+			continue
+		}
+
+		if !s.hour.has(t.Hour()) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, s.location)
+			continue
+		}
+
+		if !s.minute.has(t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+// dayMatches follows the cron convention: when both day of month and day of week are restricted, a day matching
+// either of them is accepted.
+func (s *schedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom.has(t.Day())
+	dowMatch := s.dow.has(int(t.Weekday()))
+
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+
+	return domMatch || dowMatch
+}
@@ -0,0 +1,80 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseInvalid(t *testing.T) {
+	expressions := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"foo * * * *",
+		"@fortnightly",
+	}
+
+	for _, expression := range expressions {
+		if _, err := Parse(expression, time.UTC); err == nil {
+			t.Errorf("expression %q should not be valid", expression)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	from := time.Date(2022, time.May, 10, 10, 30, 15, 0, time.UTC) // Tuesday
+
+	tables := []struct {
+		expression string
+		expected   time.Time
+	}{
+		{"* * * * *", time.Date(2022, time.May, 10, 10, 31, 0, 0, time.UTC)},
+		{"30 10 * * *", time.Date(2022, time.May, 11, 10, 30, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2022, time.May, 10, 10, 45, 0, 0, time.UTC)},
+		{"0 9 * * mon-fri", time.Date(2022, time.May, 11, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * 0", time.Date(2022, time.May, 15, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * 7", time.Date(2022, time.May, 15, 9, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2022, time.June, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 feb *", time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 12 20 * fri", time.Date(2022, time.May, 13, 12, 0, 0, 0, time.UTC)},
+		{"0 0,12 * * *", time.Date(2022, time.May, 10, 12, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2022, time.May, 10, 11, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+
+	for _, table := range tables {
+		schedule, err := Parse(table.expression, time.UTC)
+		if err != nil {
+			t.Errorf("failed to parse %q: %s", table.expression, err)
+			continue
+		}
+
+		got := schedule.Next(from)
+		if !got.Equal(table.expected) {
+			t.Errorf("unexpected next time for %q, expected: %s got: %s", table.expression, table.expected, got)
+		}
+	}
+}
+
+func TestNextInLocation(t *testing.T) {
+	location := time.FixedZone("UTC+2", 2*60*60)
+	schedule, err := Parse("0 9 * * *", location)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := schedule.Next(time.Date(2022, time.May, 10, 6, 0, 0, 0, time.UTC))
+	expected := time.Date(2022, time.May, 10, 7, 0, 0, 0, time.UTC)
+
+	if !got.Equal(expected) {
+		t.Errorf("unexpected next time, expected: %s got: %s", expected, got)
+	}
+}
@@ -1,6 +1,9 @@
 package database
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/apex/log"
 	"github.com/upper/db/v4"
 	"github.com/upper/db/v4/adapter/sqlite"
@@ -26,3 +29,28 @@ func MakeDatabaseSession(databasePath string) db.Session {
 
 	return session
 }
+
+// OpenMigratedDatabaseSession opens an existing database without migrating it, so that it is left as it is. It fails
+// when the database doesn't exist, or has migrations to apply which the program applies when it starts.
+func OpenMigratedDatabaseSession(databasePath string) (db.Session, error) {
+	if _, err := os.Stat(databasePath); err != nil {
+		return nil, err
+	}
+
+	db.LC().SetLevel(db.LogLevelError)
+	session, err := sqlite.Open(sqlite.ConnectionURL{Database: databasePath})
+	if err != nil {
+		return nil, err
+	}
+
+	pending, err := Pending(session)
+	if err == nil && pending {
+		err = fmt.Errorf("database %s has migrations to apply, start neurobot once to apply them", databasePath)
+	}
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+
+	return session, nil
+}
@@ -3,12 +3,14 @@ package database
 import (
 	"database/sql"
 	"embed"
+	"errors"
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/sqlite3"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 	"github.com/golang-migrate/migrate/v4/source/httpfs"
 	"github.com/upper/db/v4"
 	"net/http"
+	"os"
 )
 
 // Embed migrations in the binary.
@@ -45,3 +47,44 @@ func Migrate(session db.Session) error {
 
 	return nil
 }
+
+// Pending tells whether the database has migrations to apply, without applying them nor changing the database at
+// all, which the migrate driver would do by adding its version table.
+func Pending(session db.Session) (bool, error) {
+	var version struct {
+		Version uint `db:"version"`
+		Dirty   bool `db:"dirty"`
+	}
+
+	// Databases which were never migrated don't have the version table
+	var tables []struct {
+		Name string `db:"name"`
+	}
+	err := session.SQL().Select("name").From("sqlite_master").Where("type = 'table' AND name = ?", sqlite3.DefaultMigrationsTable).All(&tables)
+	if err != nil || len(tables) == 0 {
+		return err == nil, err
+	}
+
+	err = session.SQL().Select("version", "dirty").From(sqlite3.DefaultMigrationsTable).One(&version)
+	if errors.Is(err, db.ErrNoMoreRows) {
+		return true, nil
+	}
+	if err != nil || version.Dirty {
+		return version.Dirty, err
+	}
+
+	migrations, err := httpfs.New(http.FS(migrationsFilesystem), "migrations")
+	if err != nil {
+		return false, err
+	}
+	defer migrations.Close()
+
+	// The source tells there is no next migration with os.ErrNotExist
+	_, err = migrations.Next(version.Version)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
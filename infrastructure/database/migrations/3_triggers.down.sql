@@ -0,0 +1,3 @@
+DROP TABLE "workflow_trigger_state";
+DROP TABLE "workflow_trigger_meta";
+DROP TABLE "workflow_triggers";
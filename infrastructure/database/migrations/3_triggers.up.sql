@@ -0,0 +1,18 @@
+CREATE TABLE "workflow_triggers" (
+"id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+"workflow_id" INTEGER,
+"variety" TEXT
+);
+
+CREATE TABLE "workflow_trigger_meta" (
+"id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+"trigger_id" INTEGER,
+"key" TEXT,
+"value" TEXT
+);
+
+CREATE TABLE "workflow_trigger_state" (
+"workflow_id" INTEGER NOT NULL,
+"key"         TEXT NOT NULL CHECK(key <> ''),
+"value"       TEXT NOT NULL
+);
@@ -0,0 +1 @@
+ALTER TABLE "workflow_steps" DROP COLUMN "condition";
@@ -0,0 +1 @@
+ALTER TABLE "workflow_steps" ADD COLUMN "condition" TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,7 @@
+ALTER TABLE "workflow_steps" DROP COLUMN "retry_backoff";
+ALTER TABLE "workflow_steps" DROP COLUMN "max_attempts";
+ALTER TABLE "workflow_steps" DROP COLUMN "on_error";
+
+ALTER TABLE "workflows" DROP COLUMN "retry_backoff";
+ALTER TABLE "workflows" DROP COLUMN "max_attempts";
+ALTER TABLE "workflows" DROP COLUMN "on_error";
@@ -0,0 +1,7 @@
+ALTER TABLE "workflows" ADD COLUMN "on_error" TEXT NOT NULL DEFAULT '';
+ALTER TABLE "workflows" ADD COLUMN "max_attempts" INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE "workflows" ADD COLUMN "retry_backoff" TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE "workflow_steps" ADD COLUMN "on_error" TEXT NOT NULL DEFAULT '';
+ALTER TABLE "workflow_steps" ADD COLUMN "max_attempts" INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE "workflow_steps" ADD COLUMN "retry_backoff" TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+DROP TABLE "workflow_step_runs";
+DROP TABLE "workflow_runs";
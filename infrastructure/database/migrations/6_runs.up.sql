@@ -0,0 +1,29 @@
+CREATE TABLE "workflow_runs" (
+"id"          INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+"workflow_id" INTEGER NOT NULL,
+"trigger"     TEXT NOT NULL,
+"payload"     TEXT NOT NULL,
+"status"      TEXT NOT NULL,
+"error"       TEXT NOT NULL DEFAULT '',
+"started_at"  DATETIME NOT NULL,
+"finished_at" DATETIME
+);
+
+CREATE INDEX "workflow_runs_workflow_id" ON "workflow_runs" ("workflow_id", "started_at");
+
+CREATE TABLE "workflow_step_runs" (
+"id"          INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+"run_id"      INTEGER NOT NULL,
+"step_id"     INTEGER NOT NULL,
+"position"    INTEGER NOT NULL,
+"name"        TEXT NOT NULL,
+"variety"     TEXT NOT NULL,
+"status"      TEXT NOT NULL,
+"attempts"    INTEGER NOT NULL,
+"output"      TEXT NOT NULL,
+"error"       TEXT NOT NULL DEFAULT '',
+"started_at"  DATETIME NOT NULL,
+"duration_ms" INTEGER NOT NULL
+);
+
+CREATE INDEX "workflow_step_runs_run_id" ON "workflow_step_runs" ("run_id");
@@ -0,0 +1,3 @@
+DROP INDEX "workflow_runs_status";
+
+ALTER TABLE "workflow_runs" DROP COLUMN "attempts";
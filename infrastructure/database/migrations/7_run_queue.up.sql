@@ -0,0 +1,3 @@
+ALTER TABLE "workflow_runs" ADD COLUMN "attempts" INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX "workflow_runs_status" ON "workflow_runs" ("status");
@@ -0,0 +1,3 @@
+ALTER TABLE "workflow_steps" DROP COLUMN "timeout";
+
+ALTER TABLE "workflows" DROP COLUMN "timeout";
@@ -0,0 +1,3 @@
+ALTER TABLE "workflows" ADD COLUMN "timeout" TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE "workflow_steps" ADD COLUMN "timeout" TEXT NOT NULL DEFAULT '';
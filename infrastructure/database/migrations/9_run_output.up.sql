@@ -0,0 +1 @@
+ALTER TABLE "workflow_runs" ADD COLUMN "output" TEXT NOT NULL DEFAULT '';
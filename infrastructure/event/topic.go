@@ -4,6 +4,7 @@ type Topic struct {
 	id string
 }
 
+// TriggerTopic is the topic on which Trigger events are published.
 func TriggerTopic() Topic {
 	return Topic{id: "trigger"}
 }
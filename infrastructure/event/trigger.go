@@ -0,0 +1,34 @@
+package event
+
+import (
+	"neurobot/model/payload"
+	"neurobot/model/run"
+	"time"
+)
+
+// Trigger is published on the TriggerTopic whenever a source (webhook, schedule, Matrix, etc.) wants a workflow to start.
+type Trigger struct {
+	WorkflowIdentifier string
+	Variety            string // variety of the trigger which started the workflow, e.g. webhook
+	Payload            payload.Payload
+	ReceivedAt         time.Time // when the trigger fired, the run being queued as of then
+
+	// Queued, when set, is sent the ID of the run once the workflow is queued, or 0 when it couldn't be.
+	// It must be buffered, as it is sent to whether someone is still waiting or not.
+	Queued chan<- uint64
+
+	// Finished, when set, is sent the run once the workflow ran, whether it succeeded or failed, or once it was
+	// interrupted, its status then being queued as it runs again when the program starts again.
+	// It must be buffered, as it is sent to whether someone is still waiting or not.
+	Finished chan<- run.Run
+}
+
+// NewTrigger makes a Trigger event which was received now.
+func NewTrigger(workflowIdentifier string, variety string, p payload.Payload) Trigger {
+	return Trigger{
+		WorkflowIdentifier: workflowIdentifier,
+		Variety:            variety,
+		Payload:            p,
+		ReceivedAt:         time.Now(),
+	}
+}
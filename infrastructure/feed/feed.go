@@ -0,0 +1,172 @@
+package feed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Item is an entry of an RSS or Atom feed.
+type Item struct {
+	GUID      string
+	Title     string
+	Link      string
+	Author    string
+	Summary   string
+	Published time.Time // zero when the feed doesn't tell
+}
+
+type rss struct {
+	Channel struct {
+		Items []struct {
+			GUID        string `xml:"guid"`
+			Title       string `xml:"title"`
+			Link        string `xml:"link"`
+			Author      string `xml:"author"`
+			Creator     string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+			Description string `xml:"description"`
+			PubDate     string `xml:"pubDate"`
+		} `xml:"item"`
+	} `xml:"channel"`
+}
+
+type atom struct {
+	Entries []struct {
+		ID    string `xml:"id"`
+		Title string `xml:"title"`
+		Links []struct {
+			Href string `xml:"href,attr"`
+			Rel  string `xml:"rel,attr"`
+		} `xml:"link"`
+		Authors []struct {
+			Name string `xml:"name"`
+		} `xml:"author"`
+		Summary   string `xml:"summary"`
+		Content   string `xml:"content"`
+		Published string `xml:"published"`
+		Updated   string `xml:"updated"`
+	} `xml:"entry"`
+}
+
+var dateLayouts = []string{
+	time.RFC3339,
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	time.RFC822Z,
+	time.RFC822,
+}
+
+// Parse parses an RSS 2.0 or an Atom document, and returns its items in the order of the document.
+func Parse(document []byte) ([]Item, error) {
+	root, err := rootElement(document)
+	if err != nil {
+		return nil, err
+	}
+
+	switch root {
+	case "rss":
+		return parseRSS(document)
+	case "feed":
+		return parseAtom(document)
+	}
+
+	return nil, errors.New("document is neither an RSS nor an Atom feed, root element is " + root)
+}
+
+func rootElement(document []byte) (string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(document))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", err
+		}
+
+		if element, ok := token.(xml.StartElement); ok {
+			return element.Name.Local, nil
+		}
+	}
+}
+
+func parseRSS(document []byte) (items []Item, err error) {
+	var feed rss
+	if err = xml.Unmarshal(document, &feed); err != nil {
+		return
+	}
+
+	for _, i := range feed.Channel.Items {
+		author := i.Author
+		if author == "" {
+			author = i.Creator
+		}
+
+		items = append(items, Item{
+			GUID:      firstNonEmpty(i.GUID, i.Link, i.Title),
+			Title:     strings.TrimSpace(i.Title),
+			Link:      strings.TrimSpace(i.Link),
+			Author:    strings.TrimSpace(author),
+			Summary:   strings.TrimSpace(i.Description),
+			Published: parseDate(i.PubDate),
+		})
+	}
+
+	return
+}
+
+func parseAtom(document []byte) (items []Item, err error) {
+	var feed atom
+	if err = xml.Unmarshal(document, &feed); err != nil {
+		return
+	}
+
+	for _, e := range feed.Entries {
+		var link string
+		for _, l := range e.Links {
+			if l.Rel == "" || l.Rel == "alternate" {
+				link = l.Href
+				break
+			}
+		}
+
+		var authors []string
+		for _, a := range e.Authors {
+			authors = append(authors, strings.TrimSpace(a.Name))
+		}
+
+		items = append(items, Item{
+			GUID:      firstNonEmpty(e.ID, link, e.Title),
+			Title:     strings.TrimSpace(e.Title),
+			Link:      strings.TrimSpace(link),
+			Author:    strings.Join(authors, ", "),
+			Summary:   strings.TrimSpace(firstNonEmpty(e.Summary, e.Content)),
+			Published: parseDate(firstNonEmpty(e.Published, e.Updated)),
+		})
+	}
+
+	return
+}
+
+func parseDate(value string) time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
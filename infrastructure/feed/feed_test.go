@@ -0,0 +1,115 @@
+package feed
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseRSS(t *testing.T) {
+	document := `<?xml version="1.0" encoding="UTF-8"?>
+	<rss version="2.0" xmlns:dc="http://purl.org/dc/elements/1.1/">
+		<channel>
+			<title>Blog</title>
+			<item>
+				<title>Second post</title>
+				<link>https://example.com/2</link>
+				<guid isPermaLink="false">post-2</guid>
+				<dc:creator>Alice</dc:creator>
+				<description>Second summary</description>
+				<pubDate>Tue, 10 May 2022 10:00:00 +0000</pubDate>
+			</item>
+			<item>
+				<title>First post</title>
+				<link>https://example.com/1</link>
+				<author>bob@example.com (Bob)</author>
+				<pubDate>Mon, 9 May 2022 10:00:00 GMT</pubDate>
+			</item>
+		</channel>
+	</rss>`
+
+	got, err := Parse([]byte(document))
+	if err != nil {
+		t.Fatalf("failed to parse RSS: %s", err)
+	}
+
+	expected := []Item{
+		{
+			GUID:      "post-2",
+			Title:     "Second post",
+			Link:      "https://example.com/2",
+			Author:    "Alice",
+			Summary:   "Second summary",
+			Published: time.Date(2022, time.May, 10, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			GUID:      "https://example.com/1",
+			Title:     "First post",
+			Link:      "https://example.com/1",
+			Author:    "bob@example.com (Bob)",
+			Published: time.Date(2022, time.May, 9, 10, 0, 0, 0, time.UTC),
+		},
+	}
+
+	assertItems(t, got, expected)
+}
+
+func TestParseAtom(t *testing.T) {
+	document := `<?xml version="1.0" encoding="utf-8"?>
+	<feed xmlns="http://www.w3.org/2005/Atom">
+		<title>Blog</title>
+		<entry>
+			<id>urn:uuid:1225c695</id>
+			<title>Atom post</title>
+			<link rel="self" href="https://example.com/self"/>
+			<link href="https://example.com/atom"/>
+			<author><name>Alice</name></author>
+			<author><name>Bob</name></author>
+			<summary>Atom summary</summary>
+			<updated>2022-05-10T12:00:00+02:00</updated>
+		</entry>
+	</feed>`
+
+	got, err := Parse([]byte(document))
+	if err != nil {
+		t.Fatalf("failed to parse Atom: %s", err)
+	}
+
+	expected := []Item{
+		{
+			GUID:      "urn:uuid:1225c695",
+			Title:     "Atom post",
+			Link:      "https://example.com/atom",
+			Author:    "Alice, Bob",
+			Summary:   "Atom summary",
+			Published: time.Date(2022, time.May, 10, 10, 0, 0, 0, time.UTC),
+		},
+	}
+
+	assertItems(t, got, expected)
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, document := range []string{"", "{}", "<html><body></body></html>", "<rss><channel><item>"} {
+		if _, err := Parse([]byte(document)); err == nil {
+			t.Errorf("document %q should not be parsed", document)
+		}
+	}
+}
+
+func assertItems(t *testing.T, got []Item, expected []Item) {
+	if len(got) != len(expected) {
+		t.Fatalf("expected %d items, got %d", len(expected), len(got))
+	}
+
+	for i := range got {
+		if !got[i].Published.Equal(expected[i].Published) {
+			t.Errorf("unexpected published date, expected: %s got: %s", expected[i].Published, got[i].Published)
+		}
+
+		got[i].Published, expected[i].Published = time.Time{}, time.Time{}
+		if !reflect.DeepEqual(got[i], expected[i]) {
+			t.Errorf("unexpected item\n%+v\n%+v", got[i], expected[i])
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package http
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Schemes of webhook authentication, what the `auth` meta field of a webhook trigger refers to.
+const (
+	BearerAuth = "bearer" // `Authorization: Bearer <secret>` header
+	QueryAuth  = "query"  // `?token=<secret>` query parameter
+	GitHubAuth = "github" // GitHub `X-Hub-Signature-256` signature of the body
+	SlackAuth  = "slack"  // Slack `X-Slack-Signature` signature of the timestamp and body
+	HMACAuth   = "hmac"   // SHA-256 signature of the body in a header, optionally with a timestamp
+)
+
+const defaultQueryTokenParameter = "token"
+const defaultSignatureHeader = "X-Signature-256"
+const defaultAuthTolerance = 5 * time.Minute
+
+// bodySignatureTTL is how long requests whose signature only covers their body, like GitHub deliveries, are
+// remembered to refuse them when replayed, as no signed timestamp makes older requests expire.
+const bodySignatureTTL = 24 * time.Hour
+
+// replaySweepSize is how many requests a replay cache remembers before forgetting the expired ones, it then grows
+// to twice what it still remembers before doing so again.
+const replaySweepSize = 1024
+
+// ErrUnauthorized is returned when a request doesn't prove it is allowed to start a workflow.
+var ErrUnauthorized = errors.New("unauthorized")
+
+// Authenticator checks that a request comes from who is allowed to make it, given the request and its raw body.
+type Authenticator interface {
+	Authenticate(r *http.Request, body []byte) error
+}
+
+// ParseAuthenticator makes the authenticator defined by the meta of a webhook trigger: the scheme in `auth`, and the
+// secret in `secret` or in the environment variable named by `secretEnv`. No authenticator is returned when `auth`
+// is empty. Replays of signed requests are refused thanks to replays, which is to be shared by all authenticators.
+func ParseAuthenticator(meta map[string]string, replays *ReplayCache) (Authenticator, error) {
+	scheme := meta["auth"]
+	if scheme == "" {
+		return nil, nil
+	}
+
+	secret := meta["secret"]
+	if meta["secretEnv"] != "" {
+		secret = os.Getenv(meta["secretEnv"])
+	}
+	if secret == "" {
+		return nil, fmt.Errorf("%s auth requires a secret, in secret or secretEnv", scheme)
+	}
+
+	tolerance := defaultAuthTolerance
+	if meta["tolerance"] != "" {
+		// Only timestamped requests have an age to tolerate
+		if scheme != SlackAuth && (scheme != HMACAuth || meta["timestampHeader"] == "") {
+			return nil, fmt.Errorf("tolerance only applies to %s auth, and to %s auth with a timestampHeader", SlackAuth, HMACAuth)
+		}
+
+		var err error
+		if tolerance, err = time.ParseDuration(meta["tolerance"]); err != nil {
+			return nil, err
+		}
+	}
+
+	switch scheme {
+	case BearerAuth:
+		return BearerToken{Token: secret}, nil
+	case QueryAuth:
+		parameter := meta["tokenParameter"]
+		if parameter == "" {
+			parameter = defaultQueryTokenParameter
+		}
+		return QueryToken{Parameter: parameter, Token: secret}, nil
+	case GitHubAuth:
+		return gitHubSignature{secret: []byte(secret), replays: replays}, nil
+	case SlackAuth:
+		return slackSignature{secret: []byte(secret), tolerance: tolerance, replays: replays}, nil
+	case HMACAuth:
+		header := meta["signatureHeader"]
+		if header == "" {
+			header = defaultSignatureHeader
+		}
+		return hmacSignature{
+			secret:          []byte(secret),
+			header:          header,
+			timestampHeader: meta["timestampHeader"],
+			tolerance:       tolerance,
+			replays:         replays,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("auth must be %s, %s, %s, %s or %s, got %q", BearerAuth, QueryAuth, GitHubAuth, SlackAuth, HMACAuth, scheme)
+}
+
+// BearerToken accepts requests with an `Authorization: Bearer <Token>` header.
+type BearerToken struct {
+	Token string
+}
+
+func (a BearerToken) Authenticate(r *http.Request, body []byte) error {
+	const prefix = "Bearer "
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, prefix) || !equal(strings.TrimPrefix(header, prefix), a.Token) {
+		return ErrUnauthorized
+	}
+
+	return nil
+}
+
+// QueryToken accepts requests with the token as a query parameter, which isn't to be kept in the payload.
+type QueryToken struct {
+	Parameter string
+	Token     string
+}
+
+func (a QueryToken) Authenticate(r *http.Request, body []byte) error {
+	if !equal(r.URL.Query().Get(a.Parameter), a.Token) {
+		return ErrUnauthorized
+	}
+
+	return nil
+}
+
+// gitHubSignature accepts requests signed by GitHub, deliveries are accepted once. As only the body is signed, a
+// delivery is told by its signature: X-GitHub-Delivery could be changed by whoever replays it.
+type gitHubSignature struct {
+	secret  []byte
+	replays *ReplayCache
+}
+
+func (a gitHubSignature) Authenticate(r *http.Request, body []byte) error {
+	if !validSignature(a.secret, body, strings.TrimPrefix(r.Header.Get("X-Hub-Signature-256"), "sha256=")) {
+		return ErrUnauthorized
+	}
+
+	return a.replays.check("github:"+Sign(a.secret, body), bodySignatureTTL)
+}
+
+// slackSignature accepts requests signed the way Slack does, with a timestamp which must be recent.
+type slackSignature struct {
+	secret    []byte
+	tolerance time.Duration
+	replays   *ReplayCache
+}
+
+func (a slackSignature) Authenticate(r *http.Request, body []byte) error {
+	timestamp := r.Header.Get("X-Slack-Request-Timestamp")
+	if err := checkTimestamp(timestamp, a.tolerance); err != nil {
+		return err
+	}
+
+	signed := append([]byte("v0:"+timestamp+":"), body...)
+	if !validSignature(a.secret, signed, strings.TrimPrefix(r.Header.Get("X-Slack-Signature"), "v0=")) {
+		return ErrUnauthorized
+	}
+
+	// The signature is computed again, as the one received could be written differently while being as valid
+	return a.replays.check("slack:"+Sign(a.secret, signed), a.tolerance)
+}
+
+// hmacSignature accepts requests with the hex encoded SHA-256 HMAC of their body in a header, optionally prefixed by
+// `sha256=`, each request being accepted once. When there is a timestamp header, the timestamp, a `.` and the body
+// are signed, and the timestamp must be recent. Otherwise, as for GitHub, the same signed body is refused for a day.
+type hmacSignature struct {
+	secret          []byte
+	header          string
+	timestampHeader string
+	tolerance       time.Duration
+	replays         *ReplayCache
+}
+
+func (a hmacSignature) Authenticate(r *http.Request, body []byte) error {
+	signed := body
+	if a.timestampHeader != "" {
+		timestamp := r.Header.Get(a.timestampHeader)
+		if err := checkTimestamp(timestamp, a.tolerance); err != nil {
+			return err
+		}
+		signed = append([]byte(timestamp+"."), body...)
+	}
+
+	if !validSignature(a.secret, signed, strings.TrimPrefix(r.Header.Get(a.header), "sha256=")) {
+		return ErrUnauthorized
+	}
+
+	// Without a timestamp, the same body sent twice is signed the same, which can't be told apart from a replay
+	ttl := a.tolerance
+	if a.timestampHeader == "" {
+		ttl = bodySignatureTTL
+	}
+
+	return a.replays.check("hmac:"+Sign(a.secret, signed), ttl)
+}
+
+// Sign returns the hex encoded SHA-256 HMAC of a message.
+func Sign(secret []byte, message []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(message)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func validSignature(secret []byte, message []byte, signature string) bool {
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(message)
+
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// checkTimestamp makes sure a timestamp, in Unix seconds, is within tolerance of now.
+func checkTimestamp(timestamp string, tolerance time.Duration) error {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: invalid timestamp %q", ErrUnauthorized, timestamp)
+	}
+
+	age := time.Since(time.Unix(seconds, 0))
+	if age > tolerance || age < -tolerance {
+		return fmt.Errorf("%w: timestamp %s is too old", ErrUnauthorized, timestamp)
+	}
+
+	return nil
+}
+
+func equal(got string, expected string) bool {
+	return subtle.ConstantTimeCompare([]byte(got), []byte(expected)) == 1
+}
+
+// ReplayCache remembers the signed requests received recently, so that they are refused when received again.
+type ReplayCache struct {
+	mutex   sync.Mutex
+	seen    map[string]time.Time // when each request is forgotten
+	sweepAt int                  // how many requests are remembered when the expired ones are next forgotten
+}
+
+// NewReplayCache returns an empty replay cache.
+func NewReplayCache() *ReplayCache {
+	return &ReplayCache{seen: make(map[string]time.Time), sweepAt: replaySweepSize}
+}
+
+// Seen tells whether a request was already received in the last ttl, and remembers it for ttl otherwise.
+func (c *ReplayCache) Seen(key string, ttl time.Duration) bool {
+	if c == nil {
+		return false
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	if expiresAt, ok := c.seen[key]; ok && !now.After(expiresAt) {
+		return true
+	}
+
+	c.seen[key] = now.Add(ttl)
+
+	if len(c.seen) >= c.sweepAt {
+		c.sweep(now)
+	}
+
+	return false
+}
+
+// sweep forgets the expired requests.
+func (c *ReplayCache) sweep(now time.Time) {
+	for key, expiresAt := range c.seen {
+		if now.After(expiresAt) {
+			delete(c.seen, key)
+		}
+	}
+
+	c.sweepAt = 2 * len(c.seen)
+	if c.sweepAt < replaySweepSize {
+		c.sweepAt = replaySweepSize
+	}
+}
+
+func (c *ReplayCache) check(key string, ttl time.Duration) error {
+	if c.Seen(key, ttl) {
+		return fmt.Errorf("%w: request was already received", ErrUnauthorized)
+	}
+
+	return nil
+}
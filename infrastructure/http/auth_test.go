@@ -0,0 +1,238 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseAuthenticator(t *testing.T) {
+	t.Setenv("WEBHOOK_SECRET", "from env")
+
+	tables := []struct {
+		meta     map[string]string
+		expected Authenticator
+		valid    bool
+	}{
+		{meta: map[string]string{}, valid: true},
+		{meta: map[string]string{"auth": "bearer", "secret": "s3cret"}, expected: BearerToken{Token: "s3cret"}, valid: true},
+		{meta: map[string]string{"auth": "bearer", "secretEnv": "WEBHOOK_SECRET"}, expected: BearerToken{Token: "from env"}, valid: true},
+		{meta: map[string]string{"auth": "query", "secret": "s3cret"}, expected: QueryToken{Parameter: "token", Token: "s3cret"}, valid: true},
+		{meta: map[string]string{"auth": "query", "secret": "s3cret", "tokenParameter": "key"}, expected: QueryToken{Parameter: "key", Token: "s3cret"}, valid: true},
+		{meta: map[string]string{"auth": "github", "secret": "s3cret"}, valid: true},
+		{meta: map[string]string{"auth": "bearer"}},
+		{meta: map[string]string{"auth": "bearer", "secretEnv": "MISSING_WEBHOOK_SECRET"}},
+		{meta: map[string]string{"auth": "slack", "secret": "s3cret", "tolerance": "soon"}},
+		{meta: map[string]string{"auth": "slack", "secret": "s3cret", "tolerance": "1m"}, valid: true},
+		{meta: map[string]string{"auth": "hmac", "secret": "s3cret", "timestampHeader": "X-Timestamp", "tolerance": "1m"}, valid: true},
+		{meta: map[string]string{"auth": "hmac", "secret": "s3cret", "tolerance": "1m"}},
+		{meta: map[string]string{"auth": "github", "secret": "s3cret", "tolerance": "1m"}},
+		{meta: map[string]string{"auth": "bearer", "secret": "s3cret", "tolerance": "1m"}},
+		{meta: map[string]string{"auth": "basic", "secret": "s3cret"}},
+	}
+
+	for _, table := range tables {
+		got, err := ParseAuthenticator(table.meta, nil)
+		if table.valid != (err == nil) {
+			t.Errorf("%v: expected valid to be %t, got error: %v", table.meta, table.valid, err)
+			continue
+		}
+		if table.expected != nil && got != table.expected {
+			t.Errorf("%v: expected %+v, got %+v", table.meta, table.expected, got)
+		}
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	secret := []byte("s3cret")
+	body := []byte(`{"action": "opened"}`)
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	old := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+
+	request := func(target string, headers map[string]string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, target, nil)
+		for name, value := range headers {
+			r.Header.Set(name, value)
+		}
+		return r
+	}
+
+	tables := []struct {
+		name          string
+		meta          map[string]string
+		request       *http.Request
+		authenticated bool
+	}{
+		{
+			name:          "bearer",
+			meta:          map[string]string{"auth": "bearer", "secret": "s3cret"},
+			request:       request("/", map[string]string{"Authorization": "Bearer s3cret"}),
+			authenticated: true,
+		},
+		{
+			name:    "bearer with wrong token",
+			meta:    map[string]string{"auth": "bearer", "secret": "s3cret"},
+			request: request("/", map[string]string{"Authorization": "Bearer guess"}),
+		},
+		{
+			name:    "bearer without token",
+			meta:    map[string]string{"auth": "bearer", "secret": "s3cret"},
+			request: request("/", nil),
+		},
+		{
+			name:          "query",
+			meta:          map[string]string{"auth": "query", "secret": "s3cret"},
+			request:       request("/?token=s3cret", nil),
+			authenticated: true,
+		},
+		{
+			name:    "query with wrong token",
+			meta:    map[string]string{"auth": "query", "secret": "s3cret"},
+			request: request("/?token=guess", nil),
+		},
+		{
+			name: "github",
+			meta: map[string]string{"auth": "github", "secret": "s3cret"},
+			request: request("/", map[string]string{
+				"X-Hub-Signature-256": "sha256=" + Sign(secret, body),
+				"X-GitHub-Delivery":   "72d3162e",
+			}),
+			authenticated: true,
+		},
+		{
+			name: "github replayed",
+			meta: map[string]string{"auth": "github", "secret": "s3cret"},
+			request: request("/", map[string]string{
+				"X-Hub-Signature-256": "sha256=" + Sign(secret, body),
+				"X-GitHub-Delivery":   "72d3162e",
+			}),
+		},
+		{
+			name: "github replayed as another delivery",
+			meta: map[string]string{"auth": "github", "secret": "s3cret"},
+			request: request("/", map[string]string{
+				"X-Hub-Signature-256": "sha256=" + Sign(secret, body),
+				"X-GitHub-Delivery":   "72d3162f",
+			}),
+		},
+		{
+			name:    "github replayed without delivery",
+			meta:    map[string]string{"auth": "github", "secret": "s3cret"},
+			request: request("/", map[string]string{"X-Hub-Signature-256": "sha256=" + Sign(secret, body)}),
+		},
+		{
+			name:    "github with wrong signature",
+			meta:    map[string]string{"auth": "github", "secret": "s3cret"},
+			request: request("/", map[string]string{"X-Hub-Signature-256": "sha256=" + Sign([]byte("guess"), body)}),
+		},
+		{
+			name: "slack",
+			meta: map[string]string{"auth": "slack", "secret": "s3cret"},
+			request: request("/", map[string]string{
+				"X-Slack-Request-Timestamp": now,
+				"X-Slack-Signature":         "v0=" + Sign(secret, []byte("v0:"+now+":"+string(body))),
+			}),
+			authenticated: true,
+		},
+		{
+			name: "slack replayed",
+			meta: map[string]string{"auth": "slack", "secret": "s3cret"},
+			request: request("/", map[string]string{
+				"X-Slack-Request-Timestamp": now,
+				"X-Slack-Signature":         "v0=" + Sign(secret, []byte("v0:"+now+":"+string(body))),
+			}),
+		},
+		{
+			name: "slack replayed with the signature written differently",
+			meta: map[string]string{"auth": "slack", "secret": "s3cret"},
+			request: request("/", map[string]string{
+				"X-Slack-Request-Timestamp": now,
+				"X-Slack-Signature":         strings.ToUpper(Sign(secret, []byte("v0:"+now+":"+string(body)))),
+			}),
+		},
+		{
+			name: "slack with old timestamp",
+			meta: map[string]string{"auth": "slack", "secret": "s3cret"},
+			request: request("/", map[string]string{
+				"X-Slack-Request-Timestamp": old,
+				"X-Slack-Signature":         "v0=" + Sign(secret, []byte("v0:"+old+":"+string(body))),
+			}),
+		},
+		{
+			name:          "hmac",
+			meta:          map[string]string{"auth": "hmac", "secret": "s3cret"},
+			request:       request("/", map[string]string{"X-Signature-256": Sign(secret, body)}),
+			authenticated: true,
+		},
+		{
+			name:    "hmac replayed without timestamp",
+			meta:    map[string]string{"auth": "hmac", "secret": "s3cret"},
+			request: request("/", map[string]string{"X-Signature-256": "sha256=" + Sign(secret, body)}),
+		},
+		{
+			name: "hmac with timestamp",
+			meta: map[string]string{"auth": "hmac", "secret": "s3cret", "signatureHeader": "X-Sentry-Signature", "timestampHeader": "X-Timestamp"},
+			request: request("/", map[string]string{
+				"X-Timestamp":        now,
+				"X-Sentry-Signature": "sha256=" + Sign(secret, []byte(now+"."+string(body))),
+			}),
+			authenticated: true,
+		},
+		{
+			name: "hmac with timestamp replayed",
+			meta: map[string]string{"auth": "hmac", "secret": "s3cret", "signatureHeader": "X-Sentry-Signature", "timestampHeader": "X-Timestamp"},
+			request: request("/", map[string]string{
+				"X-Timestamp":        now,
+				"X-Sentry-Signature": "sha256=" + Sign(secret, []byte(now+"."+string(body))),
+			}),
+		},
+		{
+			name:    "hmac with malformed signature",
+			meta:    map[string]string{"auth": "hmac", "secret": "s3cret"},
+			request: request("/", map[string]string{"X-Signature-256": "not hex"}),
+		},
+	}
+
+	replays := NewReplayCache()
+	for _, table := range tables {
+		authenticator, err := ParseAuthenticator(table.meta, replays)
+		if err != nil {
+			t.Fatalf("%s: failed to parse authenticator: %s", table.name, err)
+		}
+
+		err = authenticator.Authenticate(table.request, body)
+		if table.authenticated && err != nil {
+			t.Errorf("%s: expected to be authenticated, got: %s", table.name, err)
+		}
+		if !table.authenticated && !errors.Is(err, ErrUnauthorized) {
+			t.Errorf("%s: expected to be unauthorized, got: %v", table.name, err)
+		}
+	}
+}
+
+func TestReplayCache(t *testing.T) {
+	c := NewReplayCache()
+	if c.Seen("first", time.Hour) || !c.Seen("first", time.Hour) {
+		t.Errorf("request should be seen once remembered")
+	}
+
+	if c.Seen("expired", -time.Second) || c.Seen("expired", time.Hour) {
+		t.Errorf("expired request should not be seen")
+	}
+
+	// Expired requests are only forgotten once the cache is large enough
+	for i := 2; i < replaySweepSize; i++ {
+		c.Seen(fmt.Sprintf("expiring %d", i), -time.Second)
+	}
+	if len(c.seen) != 2 || c.sweepAt != replaySweepSize {
+		t.Errorf("expected expired requests to be forgotten, %d remembered, next sweep at %d", len(c.seen), c.sweepAt)
+	}
+	if !c.Seen("first", time.Hour) || !c.Seen("expired", time.Hour) {
+		t.Errorf("requests which didn't expire should still be seen")
+	}
+}
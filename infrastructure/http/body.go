@@ -0,0 +1,22 @@
+package http
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// ReadBody reads a response body, failing when it is larger than limit bytes
+// so that a misbehaving server can't exhaust memory.
+func ReadBody(body io.Reader, limit int64) ([]byte, error) {
+	content, err := ioutil.ReadAll(io.LimitReader(body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if int64(len(content)) > limit {
+		return nil, fmt.Errorf("response is larger than %d bytes", limit)
+	}
+
+	return content, nil
+}
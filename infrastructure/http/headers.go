@@ -0,0 +1,26 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ParseHeaders parses headers defined one per line, as `Name: value`.
+func ParseHeaders(value string) (http.Header, error) {
+	headers := make(http.Header)
+	for _, line := range strings.Split(value, "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("header must have format `Name: value`, got %q", line)
+		}
+
+		headers.Add(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+	}
+
+	return headers, nil
+}
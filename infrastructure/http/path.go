@@ -0,0 +1,95 @@
+package http
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PathPattern is a URL path in which segments can be parameters, like `/deploy/{env}`.
+type PathPattern struct {
+	pattern  string
+	segments []string
+	params   int // how many segments are parameters
+}
+
+// ParsePathPattern parses a path pattern, which must start with `/`. A segment which is a parameter is a name between
+// braces, and must be the whole segment.
+func ParsePathPattern(pattern string) (p PathPattern, err error) {
+	if !strings.HasPrefix(pattern, "/") {
+		return p, fmt.Errorf("path must start with /, got %q", pattern)
+	}
+
+	p.pattern = pattern
+	p.segments = splitPath(pattern)
+
+	names := make(map[string]bool)
+	for _, segment := range p.segments {
+		if !strings.ContainsAny(segment, "{}") {
+			continue
+		}
+
+		name := strings.TrimSuffix(strings.TrimPrefix(segment, "{"), "}")
+		if len(name) != len(segment)-2 || name == "" || strings.ContainsAny(name, "{}") {
+			return p, fmt.Errorf("path parameter must be a whole segment like {name}, got %q", segment)
+		}
+		if names[name] {
+			return p, fmt.Errorf("path parameter %s is used more than once", name)
+		}
+		names[name] = true
+		p.params++
+	}
+
+	return p, nil
+}
+
+// Match tells whether a path matches the pattern, and returns the value of each parameter by name.
+// A trailing `/` is ignored.
+func (p PathPattern) Match(path string) (params map[string]string, ok bool) {
+	segments := splitPath(path)
+	if len(segments) != len(p.segments) {
+		return nil, false
+	}
+
+	params = make(map[string]string)
+	for i, segment := range p.segments {
+		if name, isParam := paramName(segment); isParam {
+			if segments[i] == "" {
+				return nil, false
+			}
+			params[name] = segments[i]
+			continue
+		}
+
+		if segment != segments[i] {
+			return nil, false
+		}
+	}
+
+	return params, true
+}
+
+// Params returns how many segments of the pattern are parameters, a pattern with fewer parameters is more specific.
+func (p PathPattern) Params() int {
+	return p.params
+}
+
+func (p PathPattern) String() string {
+	return p.pattern
+}
+
+func paramName(segment string) (string, bool) {
+	if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+		return segment[1 : len(segment)-1], true
+	}
+
+	return "", false
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+
+	return strings.Split(path, "/")
+}
@@ -0,0 +1,48 @@
+package http
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPathPattern(t *testing.T) {
+	tables := []struct {
+		pattern  string
+		path     string
+		params   map[string]string
+		matching bool
+	}{
+		{pattern: "/", path: "/", params: map[string]string{}, matching: true},
+		{pattern: "/deploy", path: "/deploy", params: map[string]string{}, matching: true},
+		{pattern: "/deploy", path: "/deploy/", params: map[string]string{}, matching: true},
+		{pattern: "/deploy", path: "/deploy/production"},
+		{pattern: "/deploy/{env}", path: "/deploy/production", params: map[string]string{"env": "production"}, matching: true},
+		{pattern: "/deploy/{env}", path: "/deploy"},
+		{pattern: "/deploy/{env}", path: "/deploy//"},
+		{pattern: "/{app}/deploy/{env}", path: "/neurobot/deploy/staging", params: map[string]string{"app": "neurobot", "env": "staging"}, matching: true},
+		{pattern: "/{app}/deploy/{env}", path: "/neurobot/release/staging"},
+	}
+
+	for _, table := range tables {
+		p, err := ParsePathPattern(table.pattern)
+		if err != nil {
+			t.Errorf("%s: failed to parse: %s", table.pattern, err)
+			continue
+		}
+
+		params, ok := p.Match(table.path)
+		if ok != table.matching {
+			t.Errorf("%s: expected matching %s to be %t", table.pattern, table.path, table.matching)
+			continue
+		}
+		if ok && !reflect.DeepEqual(params, table.params) {
+			t.Errorf("%s: unexpected params of %s %v", table.pattern, table.path, params)
+		}
+	}
+
+	for _, pattern := range []string{"", "deploy", "/deploy/{env", "/deploy/{}", "/deploy/env-{env}", "/{env}/{env}"} {
+		if _, err := ParsePathPattern(pattern); err == nil {
+			t.Errorf("%q: expected to be invalid", pattern)
+		}
+	}
+}
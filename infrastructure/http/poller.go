@@ -1,55 +1,118 @@
 package http
 
 import (
-	"io/ioutil"
-	"log"
+	"context"
+	"fmt"
 	"net/http"
 	"net/url"
-	"neurobot/infrastructure/event"
+	"sync"
 	"time"
+
+	"github.com/apex/log"
 )
 
+// maxPollResponseSize limits how much of a polled response is read, so that a misbehaving server can't exhaust memory.
+const maxPollResponseSize = 10 << 20
+
+// Poller requests a URL at a regular interval and hands the response body over to a handler.
+type Poller interface {
+	// Run polls until Stop is called, it is blocking.
+	Run()
+
+	// Stop stops polling, cancelling the request in progress if any: the handler isn't called once stopped. It can be
+	// called more than once.
+	Stop()
+
+	// Poll requests the URL once, and returns the response body. It fails once the poller is stopped.
+	Poll() ([]byte, error)
+}
+
 type poller struct {
 	interval time.Duration
 	url      *url.URL
-	eventBus event.Bus
+	headers  http.Header
+	client   *http.Client
+	handler  func(body []byte)
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stop     sync.Once
 }
 
-func NewHttpPoller(duration string, url *url.URL, eventBus event.Bus) *poller {
-	interval, err := time.ParseDuration(duration)
-	if err != nil {
-		log.Printf("Failed to parse duration %s, defaulting to 1 minute", duration)
-		interval, _ = time.ParseDuration("1m")
+func NewHttpPoller(interval time.Duration, url *url.URL, headers http.Header, handler func(body []byte)) Poller {
+	if interval <= 0 {
+		log.Warnf("Invalid poll interval %s, defaulting to 1 minute", interval)
+		interval = time.Minute
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+
 	return &poller{
 		interval: interval,
 		url:      url,
-		eventBus: eventBus,
+		headers:  headers,
+		client:   &http.Client{Timeout: 30 * time.Second},
+		handler:  handler,
+		ctx:      ctx,
+		cancel:   cancel,
 	}
 }
 
 func (poller *poller) Run() {
+	ticker := time.NewTicker(poller.interval)
+	defer ticker.Stop()
+
 	for {
-		time.Sleep(poller.interval)
 		poller.poll()
+
+		select {
+		case <-poller.ctx.Done():
+			return
+		case <-ticker.C:
+		}
 	}
 }
 
+func (poller *poller) Stop() {
+	poller.stop.Do(poller.cancel)
+}
+
 func (poller *poller) poll() {
-	response, err := http.Get(poller.url.String())
+	body, err := poller.Poll()
+
+	// Stopping cancels the request, and what it may have received is no longer wanted
+	if poller.ctx.Err() != nil {
+		return
+	}
+
 	if err != nil {
-		log.Printf("Failed to poll %s: %s", poller.url.String(), err)
+		log.WithError(err).WithFields(log.Fields{"url": poller.url.String()}).Error("Failed to poll")
+		return
 	}
 
-	body, err := ioutil.ReadAll(response.Body)
+	poller.handler(body)
+}
+
+func (poller *poller) Poll() ([]byte, error) {
+	request, err := http.NewRequestWithContext(poller.ctx, http.MethodGet, poller.url.String(), nil)
 	if err != nil {
-		log.Printf("Failed to parse response from url %s: %s", poller.url.String(), err)
+		return nil, err
 	}
 
-	// TODO: Remove this once the body variable is being used
-	log.Printf(string(body))
+	for name, values := range poller.headers {
+		for _, value := range values {
+			request.Header.Add(name, value)
+		}
+	}
+
+	response, err := poller.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return nil, fmt.Errorf("unexpected response status %s", response.Status)
+	}
 
-	// TODO: create a trigger, then publish it
-	// http.eventBus.Publish(event.TriggerTopic(), trigger)
+	return ReadBody(response.Body, maxPollResponseSize)
 }
@@ -0,0 +1,112 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestPoll(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.Write([]byte("foo"))
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+
+	p := NewHttpPoller(time.Minute, serverURL, http.Header{"Authorization": {"Bearer secret"}}, nil)
+	body, err := p.Poll()
+	if err != nil {
+		t.Errorf("failed to poll: %s", err)
+	}
+	if string(body) != "foo" {
+		t.Errorf("unexpected body, got: %s", body)
+	}
+
+	p = NewHttpPoller(time.Minute, serverURL, nil, nil)
+	if _, err = p.Poll(); err == nil {
+		t.Errorf("non 2xx response should be an error")
+	}
+
+	server.Close()
+	if _, err = p.Poll(); err == nil {
+		t.Errorf("unreachable server should be an error")
+	}
+}
+
+func TestRun(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("foo"))
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+
+	bodies := make(chan string, 10)
+	p := NewHttpPoller(10*time.Millisecond, serverURL, nil, func(body []byte) {
+		bodies <- string(body)
+	})
+
+	go p.Run()
+	defer p.Stop()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case body := <-bodies:
+			if body != "foo" {
+				t.Errorf("unexpected body, got: %s", body)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("poller did not poll")
+		}
+	}
+}
+
+func TestStop(t *testing.T) {
+	requested := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested <- struct{}{}
+		<-r.Context().Done() // answers only once the request is cancelled
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+
+	handled := make(chan struct{}, 1)
+	p := NewHttpPoller(time.Minute, serverURL, nil, func(body []byte) {
+		handled <- struct{}{}
+	})
+
+	stopped := make(chan struct{})
+	go func() {
+		p.Run()
+		close(stopped)
+	}()
+
+	<-requested
+	p.Stop()
+	p.Stop() // stopping again is harmless
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("stopping should cancel the request in progress")
+	}
+
+	select {
+	case <-handled:
+		t.Error("handler should not be called once stopped")
+	default:
+	}
+
+	if _, err := p.Poll(); err == nil {
+		t.Error("polling once stopped should fail")
+	}
+}
@@ -1,14 +1,23 @@
 package http
 
 import (
-	"encoding/json"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"mime"
 	"net/http"
+	"net/url"
+	"neurobot/model/payload"
+	"strings"
 )
 
-type requestHandler func(w http.ResponseWriter, r *http.Request, val map[string]string)
+// maxRequestBodySize is the size of the largest request body accepted, larger requests are refused.
+const maxRequestBodySize = 10 << 20
+
+type requestHandler func(w http.ResponseWriter, r *http.Request, p payload.Payload)
 
 type httpError struct {
 	StatusCode int
@@ -20,21 +29,34 @@ type httpError struct {
 type Server struct {
 	port   int
 	routes map[string]requestHandler
+	mux    *http.ServeMux
+	server *http.Server
 }
 
 // NewServer returns a new instance of http server
 func NewServer(port int) *Server {
+	mux := http.NewServeMux()
+
 	return &Server{
 		port:   port,
 		routes: make(map[string]requestHandler),
+		mux:    mux,
+		server: &http.Server{Addr: fmt.Sprintf(":%d", port), Handler: mux},
 	}
 }
 
-// Run method starts the http server
-func (s *Server) Run() {
-	if err := http.ListenAndServe(fmt.Sprintf(":%d", s.port), nil); err != nil {
-		log.Fatal(err)
+// Run method starts the http server, it blocks until the server fails or is shut down
+func (s *Server) Run() error {
+	if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
 	}
+
+	return nil
+}
+
+// Shutdown stops accepting requests and waits for the ones in progress to be handled, until ctx is done
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
 }
 
 // RegisterRoute saves the callback func for a particular route
@@ -46,7 +68,8 @@ func (s *Server) RegisterRoute(route string, fn requestHandler) error {
 	s.routes[route] = fn
 
 	// handle the actual request
-	http.HandleFunc(fmt.Sprintf("%s", route), func(w http.ResponseWriter, r *http.Request) {
+	s.mux.HandleFunc(fmt.Sprintf("%s", route), func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
 		requestParameters, err := s.parseRequest(r)
 		if err != nil {
 			log.Printf("Failed to parse request: %s\n", err.Error)
@@ -60,47 +83,97 @@ func (s *Server) RegisterRoute(route string, fn requestHandler) error {
 	return nil
 }
 
-func (s Server) parseRequest(r *http.Request) (values map[string]string, err *httpError) {
-	values = make(map[string]string)
-	switch r.Method {
-	case "GET":
-		q := r.URL.Query()
-		for i, v := range q {
-			values[i] = v[0]
+// RegisterHandler saves a handler which parses requests itself for a particular route, like a REST API
+func (s *Server) RegisterHandler(route string, handler http.Handler) error {
+	if _, ok := s.routes[route]; ok {
+		return fmt.Errorf("route %s already registered", route)
+	}
+
+	s.routes[route] = func(w http.ResponseWriter, r *http.Request, p payload.Payload) {
+		handler.ServeHTTP(w, r)
+	}
+
+	s.mux.Handle(route, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+		handler.ServeHTTP(w, r)
+	}))
+
+	return nil
+}
+
+// parseRequest makes the payload of a request: its query parameters, and the values of its body when it has one,
+// which can be JSON (a JSON object) or a form. Values of the body take precedence over query parameters.
+func (s Server) parseRequest(r *http.Request) (values payload.Payload, err *httpError) {
+	values = make(payload.Payload)
+	for key, v := range r.URL.Query() {
+		values[key] = v[0]
+	}
+
+	body, readErr := ioutil.ReadAll(r.Body)
+	if readErr != nil {
+		return nil, &httpError{
+			StatusCode: http.StatusRequestEntityTooLarge,
+			Message:    fmt.Sprintf("Request body is larger than %d bytes", maxRequestBodySize),
+			Error:      readErr,
 		}
-	case "POST":
-		switch r.Header.Values("Content-Type")[0] {
-		case "application/json":
-			var jsonResult map[string]interface{}
-			body, err := ioutil.ReadAll(r.Body)
-			if err != nil {
-				return nil, &httpError{
-					StatusCode: http.StatusBadRequest,
-					Message:    "Failed to parse JSON request body",
-					Error:      err,
-				}
-			}
-			if err := json.Unmarshal(body, &jsonResult); err != nil {
-				return nil, &httpError{
-					StatusCode: http.StatusInternalServerError,
-					Message:    "Failed to parse JSON request body",
-					Error:      err,
-				}
-			}
-		case "application/x-www-form-urlencoded":
-			err := r.ParseForm()
-			if err != nil {
-				return nil, &httpError{
-					StatusCode: http.StatusBadRequest,
-					Message:    "Failed to parse request body",
-					Error:      err,
-				}
+	}
+
+	// The handler can read the body again, to check its signature
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		return
+	}
+
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		return nil, &httpError{
+			StatusCode: http.StatusUnsupportedMediaType,
+			Message:    "Missing Content-Type of request body",
+			Error:      errors.New("missing Content-Type header"),
+		}
+	}
+
+	mediaType, _, parseErr := mime.ParseMediaType(contentType)
+	if parseErr != nil {
+		return nil, &httpError{
+			StatusCode: http.StatusBadRequest,
+			Message:    "Invalid Content-Type of request body",
+			Error:      parseErr,
+		}
+	}
+
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		decoded, decodeErr := payload.Decode(body)
+		if decodeErr != nil {
+			return nil, &httpError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Request body must be a JSON object",
+				Error:      decodeErr,
 			}
-			q := r.Form
-			for i, v := range q {
-				values[i] = v[0]
+		}
+		for key, value := range decoded {
+			values[key] = value
+		}
+	case mediaType == "application/x-www-form-urlencoded":
+		form, parseErr := url.ParseQuery(string(body))
+		if parseErr != nil {
+			return nil, &httpError{
+				StatusCode: http.StatusBadRequest,
+				Message:    "Failed to parse request body",
+				Error:      parseErr,
 			}
 		}
+		for key, v := range form {
+			values[key] = v[0]
+		}
+	default:
+		return nil, &httpError{
+			StatusCode: http.StatusUnsupportedMediaType,
+			Message:    fmt.Sprintf("Unsupported Content-Type %s, use application/json or application/x-www-form-urlencoded", mediaType),
+			Error:      fmt.Errorf("unsupported Content-Type %s", mediaType),
+		}
 	}
 
 	return
@@ -0,0 +1,148 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"neurobot/model/payload"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRequest(t *testing.T) {
+	tables := []struct {
+		name        string
+		method      string
+		target      string
+		contentType string
+		body        string
+		status      int
+		expected    payload.Payload
+	}{
+		{
+			name:     "query",
+			method:   http.MethodGet,
+			target:   "/?message=hello",
+			status:   http.StatusOK,
+			expected: payload.Payload{"message": "hello"},
+		},
+		{
+			name:        "JSON",
+			method:      http.MethodPost,
+			target:      "/",
+			contentType: "application/json",
+			body:        `{"action": "opened", "number": 42, "pull_request": {"user": {"login": "alice"}}}`,
+			status:      http.StatusOK,
+			expected: payload.Payload{
+				"action":       "opened",
+				"number":       json.Number("42"),
+				"pull_request": map[string]interface{}{"user": map[string]interface{}{"login": "alice"}},
+			},
+		},
+		{
+			name:        "JSON with charset and query",
+			method:      http.MethodPost,
+			target:      "/?source=ci&status=queued",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"status": "done"}`,
+			status:      http.StatusOK,
+			expected:    payload.Payload{"source": "ci", "status": "done"},
+		},
+		{
+			name:        "vendor JSON",
+			method:      http.MethodPost,
+			target:      "/",
+			contentType: "application/vnd.github+json",
+			body:        `{"zen": "Keep it simple"}`,
+			status:      http.StatusOK,
+			expected:    payload.Payload{"zen": "Keep it simple"},
+		},
+		{
+			name:        "form",
+			method:      http.MethodPost,
+			target:      "/",
+			contentType: "application/x-www-form-urlencoded; charset=utf-8",
+			body:        "message=hello&room=%21abc",
+			status:      http.StatusOK,
+			expected:    payload.Payload{"message": "hello", "room": "!abc"},
+		},
+		{
+			name:     "empty body without Content-Type",
+			method:   http.MethodPost,
+			target:   "/?message=hello",
+			status:   http.StatusOK,
+			expected: payload.Payload{"message": "hello"},
+		},
+		{
+			name:   "body without Content-Type",
+			method: http.MethodPost,
+			target: "/",
+			body:   `{"message": "hello"}`,
+			status: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:        "malformed JSON",
+			method:      http.MethodPost,
+			target:      "/",
+			contentType: "application/json",
+			body:        `{"message": `,
+			status:      http.StatusBadRequest,
+		},
+		{
+			name:        "JSON array",
+			method:      http.MethodPost,
+			target:      "/",
+			contentType: "application/json",
+			body:        `["hello"]`,
+			status:      http.StatusBadRequest,
+		},
+		{
+			name:        "invalid Content-Type",
+			method:      http.MethodPost,
+			target:      "/",
+			contentType: "application/json; charset",
+			body:        `{}`,
+			status:      http.StatusBadRequest,
+		},
+		{
+			name:        "unsupported Content-Type",
+			method:      http.MethodPost,
+			target:      "/",
+			contentType: "text/plain",
+			body:        "hello",
+			status:      http.StatusUnsupportedMediaType,
+		},
+		{
+			name:        "too large",
+			method:      http.MethodPost,
+			target:      "/",
+			contentType: "application/json",
+			body:        `{"message": "` + strings.Repeat("a", maxRequestBodySize) + `"}`,
+			status:      http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, table := range tables {
+		var got payload.Payload
+		s := NewServer(0)
+		s.RegisterRoute("/", func(w http.ResponseWriter, r *http.Request, p payload.Payload) {
+			got = p
+		})
+
+		request := httptest.NewRequest(table.method, table.target, strings.NewReader(table.body))
+		if table.contentType != "" {
+			request.Header.Set("Content-Type", table.contentType)
+		}
+		recorder := httptest.NewRecorder()
+		s.mux.ServeHTTP(recorder, request)
+
+		if recorder.Code != table.status {
+			t.Errorf("%s: expected status %d, got %d: %s", table.name, table.status, recorder.Code, recorder.Body)
+			continue
+		}
+		if !reflect.DeepEqual(got, table.expected) {
+			t.Errorf("%s: unexpected payload\n%+v\n%+v", table.name, got, table.expected)
+		}
+	}
+}
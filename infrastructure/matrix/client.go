@@ -1,19 +1,29 @@
 package matrix
 
 import (
+	"context"
 	"neurobot/model/message"
 	"neurobot/model/room"
 )
 
+// Client talks to a Matrix homeserver, the context of a call cancels the requests it makes to the homeserver.
 type Client interface {
-	Login(username string, password string) error
-	JoinRoom(id room.ID) error
-	SendMessage(roomID room.ID, message message.Message) error
+	Login(ctx context.Context, username string, password string) error
+	JoinRoom(ctx context.Context, id room.ID) error
+	SendMessage(ctx context.Context, roomID room.ID, message message.Message) error
+
+	// Stop stops syncing with the homeserver, waiting for the events being handled and for the sync state to be stored.
+	Stop()
 
 	// OnRoomInvite registers a handler that will be called whenever the currently authenticated user is invited to a room.
 	OnRoomInvite(handler func(roomID room.ID)) error
 
 	// OnMessage registers a handler that will be called whenever a message is sent to a room
-	// the currently authenticated user is a member of.
-	OnMessage(handler func(roomID room.ID, message message.Message)) error
+	// the currently authenticated user is a member of. Messages sent by the currently authenticated user,
+	// or sent before it logged in, are ignored.
+	OnMessage(handler func(roomID room.ID, sender string, message message.Message)) error
+
+	// OnReaction registers a handler that will be called whenever someone reacts to an event (e.g. with an emoji)
+	// in a room the currently authenticated user is a member of. The same rules as OnMessage apply.
+	OnReaction(handler func(roomID room.ID, sender string, eventID string, key string)) error
 }
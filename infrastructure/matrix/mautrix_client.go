@@ -20,11 +20,10 @@ import (
 )
 
 type mautrixClient interface {
-	Login(*mautrix.ReqLogin) (*mautrix.RespLogin, error)
-	JoinRoom(roomIDorAlias, serverName string, content interface{}) (resp *mautrix.RespJoinRoom, err error)
-	SendText(roomID mautrixId.RoomID, text string) (*mautrix.RespSendEvent, error)
-	SendMessageEvent(roomID mautrixId.RoomID, eventType mautrixEvent.Type, contentJSON interface{}, extra ...mautrix.ReqSendEvent) (resp *mautrix.RespSendEvent, err error)
-	ResolveAlias(alias mautrixId.RoomAlias) (resp *mautrix.RespAliasResolve, err error)
+	LoginContext(ctx context.Context, req *mautrix.ReqLogin) (*mautrix.RespLogin, error)
+	JoinRoomContext(ctx context.Context, roomIDorAlias string) (*mautrix.RespJoinRoom, error)
+	SendMessageEventContext(ctx context.Context, roomID mautrixId.RoomID, eventType mautrixEvent.Type, contentJSON interface{}) (*mautrix.RespSendEvent, error)
+	ResolveAliasContext(ctx context.Context, alias mautrixId.RoomAlias) (*mautrix.RespAliasResolve, error)
 	SyncWithContext(ctx context.Context) error
 }
 
@@ -38,6 +37,10 @@ type client struct {
 	mautrix          mautrixClient
 	syncer           mautrixSyncer
 	listenersEnabled bool
+	userID           mautrixId.UserID
+	loggedInAt       time.Time
+	stopSync         context.CancelFunc // nil when not syncing
+	syncDone         chan struct{}      // closed when syncing returned
 }
 
 func DiscoverServerURL(homeserverName string) (homeserverURL *url.URL, err error) {
@@ -107,7 +110,7 @@ func NewMautrixClient(homeserverURL *url.URL, stateStore mautrix.Storer, enableL
 
 	client := &client{
 		homeserverURL:    homeserverURL.String(),
-		mautrix:          mautrixClient,
+		mautrix:          contextClient{mautrixClient},
 		syncer:           syncer,
 		listenersEnabled: enableListeners,
 	}
@@ -115,8 +118,8 @@ func NewMautrixClient(homeserverURL *url.URL, stateStore mautrix.Storer, enableL
 	return client, nil
 }
 
-func (client *client) Login(username string, password string) error {
-	_, err := client.mautrix.Login(&mautrix.ReqLogin{
+func (client *client) Login(ctx context.Context, username string, password string) error {
+	response, err := client.mautrix.LoginContext(ctx, &mautrix.ReqLogin{
 		Type:             "m.login.password",
 		Identifier:       mautrix.UserIdentifier{Type: mautrix.IdentifierTypeUser, User: username},
 		Password:         password,
@@ -128,9 +131,18 @@ func (client *client) Login(username string, password string) error {
 		return err
 	}
 
+	client.userID = response.UserID
+	client.loggedInAt = time.Now()
+
 	if client.listenersEnabled {
+		syncCtx, stopSync := context.WithCancel(context.Background())
+		client.stopSync = stopSync
+		client.syncDone = make(chan struct{})
+
 		go func() {
-			if err := client.mautrix.SyncWithContext(context.Background()); err != nil {
+			defer close(client.syncDone)
+
+			if err := client.mautrix.SyncWithContext(syncCtx); err != nil && !errors.Is(err, context.Canceled) {
 				fmt.Printf("Error during sync: %s", err)
 			}
 		}()
@@ -139,6 +151,16 @@ func (client *client) Login(username string, password string) error {
 	return nil
 }
 
+func (client *client) Stop() {
+	if client.stopSync == nil {
+		return
+	}
+
+	client.stopSync()
+	<-client.syncDone
+	client.stopSync = nil
+}
+
 func (client *client) OnRoomInvite(handler func(roomID room.ID)) error {
 	if err := client.assertListenersEnabled(); err != nil {
 		return err
@@ -165,12 +187,16 @@ func (client *client) OnRoomInvite(handler func(roomID room.ID)) error {
 	return nil
 }
 
-func (client *client) OnMessage(handler func(roomID room.ID, message message.Message)) error {
+func (client *client) OnMessage(handler func(roomID room.ID, sender string, message message.Message)) error {
 	if err := client.assertListenersEnabled(); err != nil {
 		return err
 	}
 
 	client.syncer.OnEventType(mautrixEvent.EventMessage, func(source mautrix.EventSource, event *mautrixEvent.Event) {
+		if !client.isIncoming(event) {
+			return
+		}
+
 		var message string
 		switch event.Content.AsMessage().MsgType {
 		case mautrixEvent.MsgText:
@@ -183,20 +209,59 @@ func (client *client) OnMessage(handler func(roomID room.ID, message message.Mes
 			return
 		}
 
-		handler(roomID, msg.NewPlainTextMessage(message))
+		handler(roomID, event.Sender.String(), msg.NewPlainTextMessage(message))
 	})
 
 	return nil
 }
 
-func (client *client) JoinRoom(id room.ID) (err error) {
-	_, err = client.mautrix.JoinRoom(id.ID(), "", "")
+func (client *client) OnReaction(handler func(roomID room.ID, sender string, eventID string, key string)) error {
+	if err := client.assertListenersEnabled(); err != nil {
+		return err
+	}
+
+	client.syncer.OnEventType(mautrixEvent.EventReaction, func(source mautrix.EventSource, event *mautrixEvent.Event) {
+		if !client.isIncoming(event) {
+			return
+		}
+
+		relation := event.Content.AsReaction().RelatesTo
+		if relation.Type != mautrixEvent.RelAnnotation {
+			return
+		}
+
+		roomID, err := room.NewID(event.RoomID.String())
+		if err != nil {
+			fmt.Printf("Invalid roomID: %s", err)
+			return
+		}
+
+		handler(roomID, event.Sender.String(), relation.EventID.String(), relation.Key)
+	})
+
+	return nil
+}
+
+// isIncoming tells whether an event was sent by someone else after we logged in,
+// so that we don't react to our own events, or to old events replayed by the initial sync.
+func (client *client) isIncoming(event *mautrixEvent.Event) bool {
+	if event.Sender == client.userID {
+		return false
+	}
+
+	sentAt := time.Unix(0, event.Timestamp*int64(time.Millisecond))
+
+	return !sentAt.Before(client.loggedInAt)
+}
+
+func (client *client) JoinRoom(ctx context.Context, id room.ID) (err error) {
+	_, err = client.mautrix.JoinRoomContext(ctx, id.ID())
 
 	return
 }
 
-func (client *client) SendMessage(roomID room.ID, message msg.Message) error {
-	resolvedRoomID, err := client.resolveRoomAlias(roomID)
+func (client *client) SendMessage(ctx context.Context, roomID room.ID, message msg.Message) error {
+	resolvedRoomID, err := client.resolveRoomAlias(ctx, roomID)
 	if err != nil {
 		return err
 	}
@@ -204,21 +269,24 @@ func (client *client) SendMessage(roomID room.ID, message msg.Message) error {
 	switch message.ContentType() {
 	case msg.Markdown:
 		rendered := format.RenderMarkdown(message.String(), true, false)
-		_, err = client.mautrix.SendMessageEvent(resolvedRoomID, mautrixEvent.EventMessage, rendered)
+		_, err = client.mautrix.SendMessageEventContext(ctx, resolvedRoomID, mautrixEvent.EventMessage, rendered)
 
 	case msg.PlainText:
-		_, err = client.mautrix.SendText(resolvedRoomID, message.String())
+		_, err = client.mautrix.SendMessageEventContext(ctx, resolvedRoomID, mautrixEvent.EventMessage, mautrixEvent.MessageEventContent{
+			MsgType: mautrixEvent.MsgText,
+			Body:    message.String(),
+		})
 	}
 
 	return err
 }
 
-func (client *client) resolveRoomAlias(roomID room.ID) (mautrixId.RoomID, error) {
+func (client *client) resolveRoomAlias(ctx context.Context, roomID room.ID) (mautrixId.RoomID, error) {
 	if !roomID.IsAlias() {
 		return mautrixId.RoomID(roomID.ID()), nil
 	}
 
-	response, err := client.mautrix.ResolveAlias(mautrixId.RoomAlias(roomID.ID()))
+	response, err := client.mautrix.ResolveAliasContext(ctx, mautrixId.RoomAlias(roomID.ID()))
 	if err != nil {
 		return "", err
 	}
@@ -1,10 +1,15 @@
 package matrix
 
 import (
+	"context"
+	"errors"
 	msg "neurobot/model/message"
 	"neurobot/model/room"
 	"neurobot/resources/tests/mocks"
 	"testing"
+	"time"
+
+	mautrixEvent "maunium.net/go/mautrix/event"
 )
 
 func makeClient() (*client, mocks.MautrixClientMock, mocks.MautrixSyncerMock) {
@@ -26,7 +31,7 @@ func TestSendPlainTextMessage(t *testing.T) {
 	roomID, _ := room.NewID("!foo:matrix.test")
 	message := msg.NewPlainTextMessage("foo")
 
-	err := client.SendMessage(roomID, message)
+	err := client.SendMessage(context.Background(), roomID, message)
 	if err != nil {
 		t.Error(err)
 	}
@@ -41,7 +46,7 @@ func TestSendMarkdownMessage(t *testing.T) {
 	roomID, _ := room.NewID("!foo:matrix.test")
 	message := msg.NewMarkdownMessage("foo")
 
-	err := client.SendMessage(roomID, message)
+	err := client.SendMessage(context.Background(), roomID, message)
 	if err != nil {
 		t.Error(err)
 	}
@@ -51,11 +56,28 @@ func TestSendMarkdownMessage(t *testing.T) {
 	}
 }
 
+func TestSendMessageCancelled(t *testing.T) {
+	client, mautrixMock, _ := makeClient()
+	roomID, _ := room.NewID("#foo:matrix.test")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := client.SendMessage(ctx, roomID, msg.NewPlainTextMessage("foo"))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the message to be cancelled, got: %v", err)
+	}
+
+	if mautrixMock.WasMessageSent("foo") {
+		t.Error("message: foo shouldn't have been sent")
+	}
+}
+
 func TestJoinRoom(t *testing.T) {
 	client, mautrixMock, _ := makeClient()
 	roomID, _ := room.NewID("!foo:matrix.test")
 
-	err := client.JoinRoom(roomID)
+	err := client.JoinRoom(context.Background(), roomID)
 	if err != nil {
 		t.Error(err)
 	}
@@ -64,3 +86,56 @@ func TestJoinRoom(t *testing.T) {
 		t.Errorf("room wasn't joined")
 	}
 }
+
+func TestLogin(t *testing.T) {
+	client, _, _ := makeClient()
+
+	if err := client.Login(context.Background(), "bot", "password"); err != nil {
+		t.Error(err)
+	}
+
+	if client.userID != "1" {
+		t.Errorf("user ID wasn't stored, got: %s", client.userID)
+	}
+}
+
+func TestStop(t *testing.T) {
+	client, mautrixMock, _ := makeClient()
+	client.listenersEnabled = true
+
+	if err := client.Login(context.Background(), "bot", "password"); err != nil {
+		t.Fatal(err)
+	}
+
+	client.Stop() // returns once syncing returned
+
+	if !mautrixMock.SyncWithContextWasCalled() {
+		t.Error("expected client to have been syncing")
+	}
+
+	client.Stop() // stopping again is harmless
+}
+
+func TestIsIncoming(t *testing.T) {
+	client, _, _ := makeClient()
+	client.userID = "@bot:matrix.test"
+	client.loggedInAt = time.Now()
+
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	before := client.loggedInAt.Add(-time.Minute).UnixNano() / int64(time.Millisecond)
+
+	tables := []struct {
+		event    mautrixEvent.Event
+		expected bool
+	}{
+		{event: mautrixEvent.Event{Sender: "@alice:matrix.test", Timestamp: now + 1000}, expected: true},
+		{event: mautrixEvent.Event{Sender: "@bot:matrix.test", Timestamp: now + 1000}, expected: false},
+		{event: mautrixEvent.Event{Sender: "@alice:matrix.test", Timestamp: before}, expected: false},
+	}
+
+	for _, table := range tables {
+		if got := client.isIncoming(&table.event); got != table.expected {
+			t.Errorf("event from %s at %d: expected incoming to be %t", table.event.Sender, table.event.Timestamp, table.expected)
+		}
+	}
+}
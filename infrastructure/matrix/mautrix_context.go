@@ -0,0 +1,69 @@
+package matrix
+
+import (
+	"context"
+	"net/http"
+
+	"maunium.net/go/mautrix"
+	mautrixEvent "maunium.net/go/mautrix/event"
+	mautrixId "maunium.net/go/mautrix/id"
+)
+
+// contextClient makes the requests of a mautrix client with a context, so that they can be cancelled or time out,
+// the methods of the mautrix version we use don't take any.
+type contextClient struct {
+	*mautrix.Client
+}
+
+func (c contextClient) LoginContext(ctx context.Context, req *mautrix.ReqLogin) (resp *mautrix.RespLogin, err error) {
+	_, err = c.MakeFullRequest(mautrix.FullRequest{
+		Method:           http.MethodPost,
+		URL:              c.BuildURL("login"),
+		RequestJSON:      req,
+		ResponseJSON:     &resp,
+		Context:          ctx,
+		SensitiveContent: len(req.Password) > 0 || len(req.Token) > 0,
+	})
+	if req.StoreCredentials && err == nil {
+		c.DeviceID = resp.DeviceID
+		c.AccessToken = resp.AccessToken
+		c.UserID = resp.UserID
+	}
+
+	return
+}
+
+func (c contextClient) JoinRoomContext(ctx context.Context, roomIDorAlias string) (resp *mautrix.RespJoinRoom, err error) {
+	_, err = c.MakeFullRequest(mautrix.FullRequest{
+		Method:       http.MethodPost,
+		URL:          c.BuildURL("join", roomIDorAlias),
+		RequestJSON:  struct{}{},
+		ResponseJSON: &resp,
+		Context:      ctx,
+	})
+
+	return
+}
+
+func (c contextClient) SendMessageEventContext(ctx context.Context, roomID mautrixId.RoomID, eventType mautrixEvent.Type, contentJSON interface{}) (resp *mautrix.RespSendEvent, err error) {
+	_, err = c.MakeFullRequest(mautrix.FullRequest{
+		Method:       http.MethodPut,
+		URL:          c.BuildURL("rooms", roomID, "send", eventType.String(), c.TxnID()),
+		RequestJSON:  contentJSON,
+		ResponseJSON: &resp,
+		Context:      ctx,
+	})
+
+	return
+}
+
+func (c contextClient) ResolveAliasContext(ctx context.Context, alias mautrixId.RoomAlias) (resp *mautrix.RespAliasResolve, err error) {
+	_, err = c.MakeFullRequest(mautrix.FullRequest{
+		Method:       http.MethodGet,
+		URL:          c.BuildURL("directory", "room", alias),
+		ResponseJSON: &resp,
+		Context:      ctx,
+	})
+
+	return
+}
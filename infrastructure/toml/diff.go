@@ -0,0 +1,118 @@
+package toml
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"neurobot/model/trigger"
+	"neurobot/model/workflow"
+	"neurobot/model/workflowstep"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Diff imports the TOML file, and writes what it changed: the definition of every workflow it created, updated,
+// deactivated or deleted, line by line as Export writes it, lines removed being prefixed with - and lines added with +.
+// The repositories are meant to be bound to a database transaction which is rolled back afterwards, so that the
+// database is left as it was.
+func Diff(writer io.Writer, tomlFilePath string, prune Prune, wfRepo workflow.Repository, wfsRepo workflowstep.Repository, trRepo trigger.Repository, stepSchemas map[string]workflowstep.Schema, triggerChecks map[string]trigger.Check) (summary Summary, err error) {
+	before, err := snapshot(wfRepo, wfsRepo, trRepo)
+	if err != nil {
+		return
+	}
+
+	summary, err = Import(tomlFilePath, prune, wfRepo, wfsRepo, trRepo, stepSchemas, triggerChecks)
+	if err != nil {
+		return
+	}
+
+	after, err := snapshot(wfRepo, wfsRepo, trRepo)
+	if err != nil {
+		return
+	}
+
+	for _, change := range []struct {
+		name        string
+		identifiers []string
+	}{
+		{"created", summary.Created},
+		{"updated", summary.Updated},
+		{"deactivated", summary.Deactivated},
+		{"deleted", summary.Deleted},
+	} {
+		for _, identifier := range change.identifiers {
+			fmt.Fprintf(writer, "# %s %s\n", identifier, change.name)
+			for _, line := range diffLines(before[identifier], after[identifier]) {
+				fmt.Fprintln(writer, line)
+			}
+			fmt.Fprintln(writer)
+		}
+	}
+
+	return
+}
+
+// snapshot exports every workflow of the database on its own, by identifier.
+func snapshot(wfRepo workflow.Repository, wfsRepo workflowstep.Repository, trRepo trigger.Repository) (map[string][]string, error) {
+	workflows, err := wfRepo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+
+	definitions := make(map[string][]string)
+	for _, w := range workflows {
+		def, err := export(w, wfsRepo, trRepo)
+		if err != nil {
+			return nil, fmt.Errorf("error while exporting workflow %s: %w", w.Identifier, err)
+		}
+
+		var buffer bytes.Buffer
+		if err = toml.NewEncoder(&buffer).Encode(workflowDefintionTOML{Workflows: []workflowTOML{def}}); err != nil {
+			return nil, err
+		}
+
+		definitions[w.Identifier] = strings.Split(strings.TrimRight(buffer.String(), "\n"), "\n")
+	}
+
+	return definitions, nil
+}
+
+// diffLines compares lines, using their longest common subsequence to tell the ones which were kept from the ones
+// which were removed or added.
+func diffLines(before []string, after []string) (lines []string) {
+	// common[i][j] is the length of the longest common subsequence of before[i:] and after[j:]
+	common := make([][]int, len(before)+1)
+	for i := range common {
+		common[i] = make([]int, len(after)+1)
+	}
+	for i := len(before) - 1; i >= 0; i-- {
+		for j := len(after) - 1; j >= 0; j-- {
+			if before[i] == after[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else if common[i+1][j] >= common[i][j+1] {
+				common[i][j] = common[i+1][j]
+			} else {
+				common[i][j] = common[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(before) || j < len(after) {
+		switch {
+		case i < len(before) && j < len(after) && before[i] == after[j]:
+			lines = append(lines, "  "+before[i])
+			i++
+			j++
+		case j == len(after) || (i < len(before) && common[i+1][j] >= common[i][j+1]):
+			lines = append(lines, "- "+before[i])
+			i++
+		default:
+			lines = append(lines, "+ "+after[j])
+			j++
+		}
+	}
+
+	return
+}
@@ -0,0 +1,105 @@
+package toml
+
+import (
+	"bytes"
+	"neurobot/app/engine/steps"
+	"neurobot/app/trigger"
+	"neurobot/app/workflow"
+	"neurobot/app/workflowstep"
+	"neurobot/resources/tests/database"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/upper/db/v4"
+)
+
+func TestDiff(t *testing.T) {
+	toml := `[[workflow]]
+	identifier = "KEPT"
+	active = true
+
+	[[workflow.step]]
+	active = true
+	variety = "stdout"
+
+	[[workflow]]
+	identifier = "CHANGED"
+	active = true
+
+	[[workflow.step]]
+	active = true
+	name = "Post message"
+	variety = "postMatrixMessage"
+
+	[workflow.step.meta]
+	room = "!ops:matrix.test"`
+
+	tomlFilePath := "./toml_file_for_testing.toml"
+	os.WriteFile(tomlFilePath, []byte(toml), 0644)
+	defer os.Remove(tomlFilePath)
+
+	database.Test(func(session db.Session) {
+		wfRepo := workflow.NewRepository(session)
+		if _, err := Import(tomlFilePath, PruneNone, wfRepo, workflowstep.NewRepository(session), trigger.NewRepository(session), steps.Schemas(), trigger.Checks()); err != nil {
+			t.Fatalf("valid toml import failed: %s", err)
+		}
+
+		changed := strings.Replace(toml, "!ops:matrix.test", "!dev:matrix.test", 1) + `
+
+	[[workflow]]
+	identifier = "CREATED"
+	active = true
+
+	[[workflow.step]]
+	active = true
+	variety = "stdout"`
+		os.WriteFile(tomlFilePath, []byte(changed), 0644)
+
+		var buffer bytes.Buffer
+		summary, err := Diff(&buffer, tomlFilePath, PruneNone, wfRepo, workflowstep.NewRepository(session), trigger.NewRepository(session), steps.Schemas(), trigger.Checks())
+		if err != nil {
+			t.Fatalf("diff failed: %s", err)
+		}
+
+		if !reflect.DeepEqual(summary, Summary{Created: []string{"CREATED"}, Updated: []string{"CHANGED"}, Unchanged: []string{"KEPT"}}) {
+			t.Errorf("unexpected summary: %s", summary)
+		}
+
+		expected := `# CREATED created
++ [[workflow]]
++   identifier = "CREATED"
++   active = true
++ 
++   [[workflow.step]]
++     active = true
++     variety = "stdout"
+
+# CHANGED updated
+  [[workflow]]
+    identifier = "CHANGED"
+    active = true
+  
+    [[workflow.step]]
+      active = true
+      name = "Post message"
+      variety = "postMatrixMessage"
+      [workflow.step.meta]
+-       room = "!ops:matrix.test"
++       room = "!dev:matrix.test"
+
+`
+		if buffer.String() != expected {
+			t.Errorf("unexpected diff\n%s\n%s", buffer.String(), expected)
+		}
+	})
+}
+
+func TestDiffLines(t *testing.T) {
+	got := diffLines([]string{"a", "b", "c", "d"}, []string{"a", "c", "e", "d"})
+	expected := []string{"  a", "- b", "  c", "+ e", "  d"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected diff\n%q\n%q", got, expected)
+	}
+}
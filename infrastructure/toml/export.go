@@ -0,0 +1,86 @@
+package toml
+
+import (
+	"fmt"
+	"io"
+	"neurobot/model/trigger"
+	"neurobot/model/workflow"
+	"neurobot/model/workflowstep"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Export writes every workflow of the database, along with its triggers and its steps in their order, as a TOML
+// document which Import imports back as it is. Workflows without any step are exported too, but can't be imported
+// back until they have some.
+func Export(writer io.Writer, wfRepo workflow.Repository, wfsRepo workflowstep.Repository, trRepo trigger.Repository) error {
+	workflows, err := wfRepo.FindAll()
+	if err != nil {
+		return err
+	}
+
+	var defs workflowDefintionTOML
+	for _, w := range workflows {
+		def, err := export(w, wfsRepo, trRepo)
+		if err != nil {
+			return fmt.Errorf("error while exporting workflow %s: %w", w.Identifier, err)
+		}
+
+		defs.Workflows = append(defs.Workflows, def)
+	}
+
+	return toml.NewEncoder(writer).Encode(defs)
+}
+
+// export makes the TOML definition of a single workflow, the inverse of prepare.
+func export(w workflow.Workflow, wfsRepo workflowstep.Repository, trRepo trigger.Repository) (def workflowTOML, err error) {
+	def = workflowTOML{
+		Identifier:  w.Identifier,
+		Active:      w.Active,
+		Name:        w.Name,
+		Description: w.Description,
+		Timeout:     w.Timeout,
+		errorPolicyTOML: errorPolicyTOML{
+			OnError:      w.OnError,
+			MaxAttempts:  w.MaxAttempts,
+			RetryBackoff: w.RetryBackoff,
+		},
+	}
+
+	triggers, err := trRepo.FindByWorkflowID(w.ID)
+	if err != nil {
+		return
+	}
+
+	for _, t := range triggers {
+		def.Triggers = append(def.Triggers, triggerTOML{
+			Variety: t.Variety,
+			Meta:    t.Meta,
+		})
+	}
+
+	// Steps are ordered by their sort order
+	steps, err := wfsRepo.FindByWorkflowID(w.ID)
+	if err != nil {
+		return
+	}
+
+	for _, step := range steps {
+		def.Steps = append(def.Steps, workflowStepTOML{
+			Active:      step.Active,
+			Name:        step.Name,
+			Description: step.Description,
+			Variety:     step.Variety,
+			If:          step.Condition,
+			Timeout:     step.Timeout,
+			errorPolicyTOML: errorPolicyTOML{
+				OnError:      step.OnError,
+				MaxAttempts:  step.MaxAttempts,
+				RetryBackoff: step.RetryBackoff,
+			},
+			Meta: step.Meta,
+		})
+	}
+
+	return
+}
@@ -0,0 +1,105 @@
+package toml
+
+import (
+	"bytes"
+	"neurobot/app/engine/steps"
+	"neurobot/app/trigger"
+	"neurobot/app/workflow"
+	"neurobot/app/workflowstep"
+	"neurobot/resources/tests/database"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/upper/db/v4"
+)
+
+func TestExport(t *testing.T) {
+	toml := `[[workflow]]
+	identifier = "DEPLOY"
+	active = true
+	name = "Deploy"
+	description = "Announces deploys"
+	timeout = "5m"
+	onError = "retry"
+	maxAttempts = 3
+	retryBackoff = "10s"
+
+	[[workflow.trigger]]
+	variety = "webhook"
+
+	[workflow.trigger.meta]
+	path = "/deploy/{env}"
+	methods = "POST"
+
+	[[workflow.trigger]]
+	variety = "schedule"
+
+	[workflow.trigger.meta]
+	cron = "0 9 * * mon-fri"
+
+	[[workflow.step]]
+	active = true
+	name = "Post message"
+	variety = "postMatrixMessage"
+	if = 'payload.env == "production"'
+	onError = "halt"
+
+	[workflow.step.meta]
+	message = "Deploying to {{ .env }}"
+	room = "!ops:matrix.test"
+
+	[[workflow.step]]
+	active = false
+	variety = "stdout"
+	timeout = "30s"
+
+	[[workflow]]
+	identifier = "DISABLED"
+	active = false
+
+	[[workflow.step]]
+	active = true
+	variety = "stdout"`
+
+	tomlFilePath := "./toml_file_for_testing.toml"
+	os.WriteFile(tomlFilePath, []byte(toml), 0644)
+	defer os.Remove(tomlFilePath)
+
+	export := func(session db.Session) []byte {
+		var buffer bytes.Buffer
+		err := Export(&buffer, workflow.NewRepository(session), workflowstep.NewRepository(session), trigger.NewRepository(session))
+		if err != nil {
+			t.Fatalf("failed to export: %s", err)
+		}
+		return buffer.Bytes()
+	}
+	importFile := func(session db.Session) Summary {
+		summary, err := Import(tomlFilePath, PruneNone, workflow.NewRepository(session), workflowstep.NewRepository(session), trigger.NewRepository(session), steps.Schemas(), trigger.Checks())
+		if err != nil {
+			t.Fatalf("failed to import: %s", err)
+		}
+		return summary
+	}
+
+	var exported []byte
+	database.Test(func(session db.Session) {
+		importFile(session)
+		exported = export(session)
+
+		// Importing what was exported changes nothing
+		os.WriteFile(tomlFilePath, exported, 0644)
+		summary := importFile(session)
+		if !reflect.DeepEqual(summary, Summary{Unchanged: []string{"DEPLOY", "DISABLED"}}) {
+			t.Errorf("expected exported workflows to be unchanged once imported, got: %s", summary)
+		}
+	})
+
+	// Neither does importing it into another database
+	database.Test(func(session db.Session) {
+		importFile(session)
+		if again := export(session); !bytes.Equal(again, exported) {
+			t.Errorf("expected the same export from another database\n%s\n%s", again, exported)
+		}
+	})
+}
@@ -1,74 +1,335 @@
 package toml
 
 import (
+	"bytes"
 	"fmt"
+	"neurobot/infrastructure/condition"
+	"neurobot/model/trigger"
 	"neurobot/model/workflow"
 	"neurobot/model/workflowstep"
+	"reflect"
+	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 )
 
+// Keys are matched regardless of their case when decoding, tags are the names keys are encoded with when exporting.
 type workflowDefintionTOML struct {
-	Workflows []workflowTOML `toml:"Workflow"`
+	Workflows []workflowTOML `toml:"workflow"`
 }
 
 type workflowTOML struct {
-	Identifier  string
-	Active      bool
-	Name        string
-	Description string
-	Steps       []workflowStepTOML `toml:"Step"`
+	Identifier  string `toml:"identifier"`
+	Active      bool   `toml:"active"`
+	Name        string `toml:"name,omitempty"`
+	Description string `toml:"description,omitempty"`
+	Timeout     string `toml:"timeout,omitempty"`
+	errorPolicyTOML
+	Triggers triggersTOML       `toml:"trigger"`
+	Steps    []workflowStepTOML `toml:"step"`
+}
+
+// triggersTOML are the triggers of a workflow, defined as an array of tables, or as a single table the way they were
+// before workflows could have more than one trigger.
+type triggersTOML []triggerTOML
+
+func (triggers *triggersTOML) UnmarshalTOML(data interface{}) error {
+	var tables []map[string]interface{}
+	switch data := data.(type) {
+	case map[string]interface{}:
+		tables = []map[string]interface{}{data}
+	case []map[string]interface{}:
+		tables = data
+	case []interface{}:
+		for _, item := range data {
+			table, ok := item.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("trigger must be a table, got %T", item)
+			}
+			tables = append(tables, table)
+		}
+	default:
+		return fmt.Errorf("trigger must be a table or an array of tables, got %T", data)
+	}
+
+	*triggers = nil
+	for _, table := range tables {
+		// Encoding the table back decodes it the way any other table is, keys regardless of their case
+		var buffer bytes.Buffer
+		if err := toml.NewEncoder(&buffer).Encode(table); err != nil {
+			return err
+		}
+
+		var t triggerTOML
+		if _, err := toml.Decode(buffer.String(), &t); err != nil {
+			return fmt.Errorf("invalid trigger: %w", err)
+		}
+
+		*triggers = append(*triggers, t)
+	}
+
+	return nil
+}
+
+type triggerTOML struct {
+	Variety string            `toml:"variety"`
+	Meta    map[string]string `toml:"meta,omitempty"`
 }
 
 type workflowStepTOML struct {
-	Active      bool
-	Name        string
-	Description string
-	Variety     string
-	Meta        map[string]string
+	Active      bool   `toml:"active"`
+	Name        string `toml:"name,omitempty"`
+	Description string `toml:"description,omitempty"`
+	Variety     string `toml:"variety"`
+	If          string `toml:"if,omitempty"`
+	Timeout     string `toml:"timeout,omitempty"`
+	errorPolicyTOML
+	Meta map[string]string `toml:"meta,omitempty"`
+}
+
+// errorPolicyTOML defines what to do when a workflow step fails, for a workflow or for a single step.
+type errorPolicyTOML struct {
+	OnError      string `toml:"onError,omitempty"`
+	MaxAttempts  uint64 `toml:"maxAttempts,omitzero"`
+	RetryBackoff string `toml:"retryBackoff,omitempty"`
+}
+
+// Prune is what an import does to the workflows of the database which are no longer defined in the TOML file.
+type Prune string
+
+const (
+	// PruneNone leaves them as they are.
+	PruneNone Prune = ""
+
+	// PruneDeactivate deactivates them, their steps and triggers are kept so that they can be activated again.
+	PruneDeactivate Prune = "deactivate"
+
+	// PruneDelete deletes them along with their steps and triggers, their runs are kept in the history.
+	PruneDelete Prune = "delete"
+)
+
+// Summary lists the identifiers of the workflows an import created, updated, left unchanged, deactivated or deleted.
+type Summary struct {
+	Created     []string
+	Updated     []string
+	Unchanged   []string
+	Deactivated []string
+	Deleted     []string
+}
+
+// Changed tells whether the import changed any workflow.
+func (s Summary) Changed() bool {
+	return len(s.Created)+len(s.Updated)+len(s.Deactivated)+len(s.Deleted) > 0
 }
 
-// Import accepts a workflow repository where workflows are to be imported from the provided toml file
-func Import(tomlFilePath string, wfRepo workflow.Repository, wfsRepo workflowstep.Repository) (err error) {
-	workflowDefs, err := parse(tomlFilePath)
+func (s Summary) String() string {
+	var parts []string
+	for _, change := range []struct {
+		name        string
+		identifiers []string
+	}{
+		{"created", s.Created},
+		{"updated", s.Updated},
+		{"unchanged", s.Unchanged},
+		{"deactivated", s.Deactivated},
+		{"deleted", s.Deleted},
+	} {
+		part := fmt.Sprintf("%d %s", len(change.identifiers), change.name)
+		if len(change.identifiers) > 0 {
+			part += " (" + strings.Join(change.identifiers, ", ") + ")"
+		}
+		parts = append(parts, part)
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// Import accepts a workflow repository where workflows are to be imported from the provided toml file, the meta of
+// workflow steps is validated against the schema of their variety, and triggers by the check of their variety.
+// Workflows whose definition didn't change are left untouched, the ones no longer defined are pruned as asked.
+// Every workflow is saved with statements of its own: the repositories are meant to be bound to a database
+// transaction, so that nothing is imported when the import fails midway.
+func Import(tomlFilePath string, prune Prune, wfRepo workflow.Repository, wfsRepo workflowstep.Repository, trRepo trigger.Repository, stepSchemas map[string]workflowstep.Schema, triggerChecks map[string]trigger.Check) (summary Summary, err error) {
+	switch prune {
+	case PruneNone, PruneDeactivate, PruneDelete:
+	default:
+		return summary, fmt.Errorf("prune must be %s or %s, got %q", PruneDeactivate, PruneDelete, prune)
+	}
+
+	workflowDefs, err := parse(tomlFilePath, stepSchemas, triggerChecks)
 	if err != nil {
-		return fmt.Errorf("error while parsing toml file: %w", err)
+		return summary, fmt.Errorf("error while parsing toml file: %w", err)
 	}
 
+	defined := make(map[string]bool)
 	for _, def := range workflowDefs.Workflows {
-		workflow, workflowSteps, err := prepare(def, wfRepo, wfsRepo)
+		defined[def.Identifier] = true
+
+		workflow, workflowSteps, triggers, err := prepare(def, wfRepo, wfsRepo)
 		if err != nil {
-			return fmt.Errorf("error while preparing toml def for import: %w", err)
+			return summary, fmt.Errorf("error while preparing toml def for import: %w", err)
+		}
+
+		existed := workflow.ID != 0
+		if existed {
+			same, err := unchanged(workflow, workflowSteps, triggers, wfRepo, wfsRepo, trRepo)
+			if err != nil {
+				return summary, err
+			}
+			if same {
+				summary.Unchanged = append(summary.Unchanged, workflow.Identifier)
+				continue
+			}
 		}
 
 		if err = wfRepo.Save(&workflow); err != nil {
-			return err
+			return summary, err
 		}
 
 		// remove all workflow steps for this workflow before freshly insert all workflow steps data (including step meta)
 		if err = wfsRepo.RemoveByWorkflowID(workflow.ID); err != nil {
-			return err
+			return summary, err
 		}
 
 		for _, step := range workflowSteps {
 			// now that we surely have the workflow ID, populate that in step
 			step.WorkflowID = workflow.ID
 			if err = wfsRepo.Save(&step); err != nil {
-				return err
+				return summary, err
+			}
+		}
+
+		// same goes for triggers
+		if err = trRepo.RemoveByWorkflowID(workflow.ID); err != nil {
+			return summary, err
+		}
+
+		for _, t := range triggers {
+			t.WorkflowID = workflow.ID
+			if err = trRepo.Save(&t); err != nil {
+				return summary, err
 			}
 		}
+
+		if existed {
+			summary.Updated = append(summary.Updated, workflow.Identifier)
+		} else {
+			summary.Created = append(summary.Created, workflow.Identifier)
+		}
+	}
+
+	if prune == PruneNone {
+		return
+	}
+
+	workflows, err := wfRepo.FindAll()
+	if err != nil {
+		return
+	}
+
+	for _, workflow := range workflows {
+		if defined[workflow.Identifier] {
+			continue
+		}
+
+		if prune == PruneDeactivate {
+			if !workflow.Active {
+				continue
+			}
+
+			workflow.Active = false
+			if err = wfRepo.Save(&workflow); err != nil {
+				return
+			}
+			summary.Deactivated = append(summary.Deactivated, workflow.Identifier)
+			continue
+		}
+
+		if err = wfsRepo.RemoveByWorkflowID(workflow.ID); err != nil {
+			return
+		}
+		if err = trRepo.RemoveByWorkflowID(workflow.ID); err != nil {
+			return
+		}
+		if err = wfRepo.Remove(workflow.ID); err != nil {
+			return
+		}
+		summary.Deleted = append(summary.Deleted, workflow.Identifier)
 	}
 
 	return
 }
 
-func parse(tomlFilePath string) (def workflowDefintionTOML, err error) {
+// unchanged tells whether a prepared workflow, its steps and its triggers are the same as the ones in the database.
+func unchanged(w workflow.Workflow, steps []workflowstep.WorkflowStep, triggers []trigger.Trigger, wfRepo workflow.Repository, wfsRepo workflowstep.Repository, trRepo trigger.Repository) (bool, error) {
+	existing, err := wfRepo.FindByID(w.ID)
+	if err != nil {
+		return false, err
+	}
+	if existing != w {
+		return false, nil
+	}
+
+	existingSteps, err := wfsRepo.FindByWorkflowID(w.ID)
+	if err != nil {
+		return false, err
+	}
+	if len(existingSteps) != len(steps) {
+		return false, nil
+	}
+	for i, step := range steps {
+		// IDs are not part of the definition of a step, nor is its sort order as long as the steps are in the same order
+		step.ID = existingSteps[i].ID
+		step.WorkflowID = existingSteps[i].WorkflowID
+		step.SortOrder = existingSteps[i].SortOrder
+		if !sameMeta(step.Meta, existingSteps[i].Meta) {
+			return false, nil
+		}
+		step.Meta = existingSteps[i].Meta
+		if !reflect.DeepEqual(step, existingSteps[i]) {
+			return false, nil
+		}
+	}
+
+	existingTriggers, err := trRepo.FindByWorkflowID(w.ID)
+	if err != nil {
+		return false, err
+	}
+	if len(existingTriggers) != len(triggers) {
+		return false, nil
+	}
+	for i, t := range triggers {
+		if t.Variety != existingTriggers[i].Variety || !sameMeta(t.Meta, existingTriggers[i].Meta) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// sameMeta compares meta, no meta being the same as an empty one.
+func sameMeta(a map[string]string, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for key, value := range a {
+		if other, ok := b[key]; !ok || other != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+func parse(tomlFilePath string, stepSchemas map[string]workflowstep.Schema, triggerChecks map[string]trigger.Check) (def workflowDefintionTOML, err error) {
 	_, err = toml.DecodeFile(tomlFilePath, &def)
 	if err != nil {
 		return
 	}
 
-	err = runSemanticCheckOnTOML(def)
+	err = runSemanticCheckOnTOML(def, stepSchemas, triggerChecks)
 	if err != nil {
 		return def, fmt.Errorf("semantic checks failed on toml definition: %w", err)
 	}
@@ -76,7 +337,7 @@ func parse(tomlFilePath string) (def workflowDefintionTOML, err error) {
 	return
 }
 
-func runSemanticCheckOnTOML(def workflowDefintionTOML) error {
+func runSemanticCheckOnTOML(def workflowDefintionTOML, stepSchemas map[string]workflowstep.Schema, triggerChecks map[string]trigger.Check) error {
 	// > make sure Identifier is unique for each workflow and based on that realize what inserts/update needs to happen
 	// > make sure workflow has atleast a trigger and atleast a workflow step inside of it
 	uniqueIDs := make(map[string]bool)
@@ -90,32 +351,136 @@ func runSemanticCheckOnTOML(def workflowDefintionTOML) error {
 		if len(w.Steps) == 0 {
 			return fmt.Errorf("no workflow steps defined for workflow in TOML with ID:%s", w.Identifier)
 		}
+
+		if err := checkErrorPolicy(w.errorPolicyTOML); err != nil {
+			return fmt.Errorf("invalid error policy for workflow in TOML with ID:%s: %w", w.Identifier, err)
+		}
+
+		if err := checkTimeout(w.Timeout); err != nil {
+			return fmt.Errorf("invalid timeout for workflow in TOML with ID:%s: %w", w.Identifier, err)
+		}
+
+		for _, t := range w.Triggers {
+			if err := checkTrigger(w.Identifier, t, triggerChecks); err != nil {
+				return fmt.Errorf("invalid %s trigger for workflow in TOML with ID:%s: %w", t.Variety, w.Identifier, err)
+			}
+		}
+
+		for _, s := range w.Steps {
+			if err := checkStep(s, stepSchemas); err != nil {
+				return fmt.Errorf("invalid %s step for workflow in TOML with ID:%s: %w", s.Variety, w.Identifier, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkTrigger makes sure a trigger is of a known variety, and checks its meta the way the variety does.
+func checkTrigger(workflowIdentifier string, t triggerTOML, triggerChecks map[string]trigger.Check) error {
+	check, ok := triggerChecks[t.Variety]
+	if !ok {
+		return fmt.Errorf("unknown variety %q", t.Variety)
+	}
+
+	return check(workflow.Workflow{Identifier: workflowIdentifier}, trigger.Trigger{Variety: t.Variety, Meta: t.Meta})
+}
+
+func checkErrorPolicy(p errorPolicyTOML) error {
+	switch p.OnError {
+	case "", "continue", "halt", "retry":
+	default:
+		return fmt.Errorf("onError must be continue, halt or retry, got %q", p.OnError)
+	}
+
+	if p.RetryBackoff != "" {
+		if _, err := time.ParseDuration(p.RetryBackoff); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-// Prepares a workflow struct and an array of workflow steps struct from a TOML definition of a single workflow
-func prepare(def workflowTOML, wfRepo workflow.Repository, wfsRepo workflowstep.Repository) (w workflow.Workflow, steps []workflowstep.WorkflowStep, err error) {
+// checkTimeout makes sure a timeout is a positive duration, empty meaning no timeout.
+func checkTimeout(timeout string) error {
+	if timeout == "" {
+		return nil
+	}
+
+	d, err := time.ParseDuration(timeout)
+	if err != nil {
+		return err
+	}
+	if d <= 0 {
+		return fmt.Errorf("timeout must be positive, got %q", timeout)
+	}
+
+	return nil
+}
+
+func checkStep(s workflowStepTOML, stepSchemas map[string]workflowstep.Schema) error {
+	if err := checkErrorPolicy(s.errorPolicyTOML); err != nil {
+		return err
+	}
+
+	if err := checkTimeout(s.Timeout); err != nil {
+		return err
+	}
+
+	if s.If != "" {
+		if _, err := condition.Parse(s.If); err != nil {
+			return err
+		}
+	}
+
+	schema, ok := stepSchemas[s.Variety]
+	if !ok {
+		return fmt.Errorf("unknown variety %q", s.Variety)
+	}
+
+	return schema.Validate(s.Meta)
+}
+
+// Prepares a workflow struct, an array of workflow steps struct and an array of triggers struct from a TOML definition of a single workflow
+func prepare(def workflowTOML, wfRepo workflow.Repository, wfsRepo workflowstep.Repository) (w workflow.Workflow, steps []workflowstep.WorkflowStep, triggers []trigger.Trigger, err error) {
 	w, _ = wfRepo.FindByIdentifier(def.Identifier)
 
 	w.Identifier = def.Identifier
 	w.Name = def.Name
 	w.Description = def.Description
 	w.Active = def.Active
+	w.OnError = def.OnError
+	w.MaxAttempts = def.MaxAttempts
+	w.RetryBackoff = def.RetryBackoff
+	w.Timeout = def.Timeout
 
-	for _, step := range def.Steps {
+	for i, step := range def.Steps {
 		s := workflowstep.WorkflowStep{
-			Active:      step.Active,
-			Name:        step.Name,
-			Description: step.Description,
-			Variety:     step.Variety,
-			WorkflowID:  w.ID,
-			Meta:        step.Meta,
+			SortOrder:    uint64(i),
+			Active:       step.Active,
+			Name:         step.Name,
+			Description:  step.Description,
+			Variety:      step.Variety,
+			Condition:    step.If,
+			OnError:      step.OnError,
+			MaxAttempts:  step.MaxAttempts,
+			RetryBackoff: step.RetryBackoff,
+			Timeout:      step.Timeout,
+			WorkflowID:   w.ID,
+			Meta:         step.Meta,
 		}
 
 		steps = append(steps, s)
 	}
 
+	for _, t := range def.Triggers {
+		triggers = append(triggers, trigger.Trigger{
+			WorkflowID: w.ID,
+			Variety:    t.Variety,
+			Meta:       t.Meta,
+		})
+	}
+
 	return
 }
@@ -1,13 +1,18 @@
 package toml
 
 import (
+	"errors"
+	"neurobot/app/engine/steps"
+	"neurobot/app/trigger"
 	"neurobot/app/workflow"
 	"neurobot/app/workflowstep"
+	trm "neurobot/model/trigger"
 	wfm "neurobot/model/workflow"
 	wfsm "neurobot/model/workflowstep"
 	"neurobot/resources/tests/database"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/upper/db/v4"
@@ -25,6 +30,12 @@ func TestImport(t *testing.T) {
 	name = "Workflow toml test big name workflow"
 	description = "some description"
 
+	[[workflow.trigger]]
+	variety = "schedule"
+
+	[workflow.trigger.meta]
+	cron = "0 9 * * mon-fri"
+
 	[[workflow.step]]
 	active = true
 	name = "Post message"
@@ -33,7 +44,7 @@ func TestImport(t *testing.T) {
 
 	[workflow.step.meta]
 	messagePrefix = "[Alert]"
-	matrixRoom = "#room"`
+	room = "#room"`
 
 	tomlFilePath := "./toml_file_for_testing.toml"
 	os.WriteFile(tomlFilePath, []byte(toml), 0644)
@@ -42,11 +53,15 @@ func TestImport(t *testing.T) {
 	database.Test(func(session db.Session) {
 		wfRepo := workflow.NewRepository(session)
 		wfsRepo := workflowstep.NewRepository(session)
+		trRepo := trigger.NewRepository(session)
 
-		err := Import(tomlFilePath, wfRepo, wfsRepo)
+		summary, err := Import(tomlFilePath, PruneNone, wfRepo, wfsRepo, trRepo, steps.Schemas(), trigger.Checks())
 		if err != nil {
 			t.Errorf("valid toml import failed: %s", err)
 		}
+		if !reflect.DeepEqual(summary, Summary{Created: []string{"TOMLTESTME"}}) {
+			t.Errorf("unexpected summary: %s", summary)
+		}
 
 		expectedWorkflow := wfm.Workflow{Name: "Workflow toml test big name workflow"}
 
@@ -58,7 +73,198 @@ func TestImport(t *testing.T) {
 		if workflow.Name != expectedWorkflow.Name {
 			t.Errorf("imported workflow does not match\n%v\n%v", workflow, expectedWorkflow)
 		}
+
+		triggers, err := trRepo.FindByWorkflowID(workflow.ID)
+		if err != nil {
+			t.Errorf("could not find triggers in the database")
+		}
+
+		if len(triggers) != 1 || triggers[0].Variety != "schedule" || triggers[0].Meta["cron"] != "0 9 * * mon-fri" {
+			t.Errorf("imported triggers do not match: %+v", triggers)
+		}
+	})
+}
+
+func TestImportSingleTrigger(t *testing.T) {
+	// Before workflows could have more than one trigger, a workflow had a single trigger table
+	toml := `[[workflow]]
+	identifier = "OLDFORMAT"
+	active = true
+	name = "Old format"
+
+	[workflow.trigger]
+	variety = "webhook"
+
+	[workflow.trigger.meta]
+	urlSuffix = "old-format"
+
+	[[workflow.step]]
+	active = true
+	variety = "postMatrixMessage"
+
+	[workflow.step.meta]
+	room = "#room"`
+
+	tomlFilePath := "./toml_file_for_testing.toml"
+	os.WriteFile(tomlFilePath, []byte(toml), 0644)
+	defer os.Remove(tomlFilePath)
+
+	database.Test(func(session db.Session) {
+		wfRepo := workflow.NewRepository(session)
+		trRepo := trigger.NewRepository(session)
+
+		if _, err := Import(tomlFilePath, PruneNone, wfRepo, workflowstep.NewRepository(session), trRepo, steps.Schemas(), trigger.Checks()); err != nil {
+			t.Fatalf("toml with a single trigger table failed to import: %s", err)
+		}
+
+		workflow, err := wfRepo.FindByIdentifier("OLDFORMAT")
+		if err != nil {
+			t.Fatalf("could not find workflow in the database")
+		}
+
+		triggers, err := trRepo.FindByWorkflowID(workflow.ID)
+		if err != nil {
+			t.Fatalf("could not find triggers in the database")
+		}
+
+		if len(triggers) != 1 || triggers[0].Variety != "webhook" || triggers[0].Meta["urlSuffix"] != "old-format" {
+			t.Errorf("imported triggers do not match: %+v", triggers)
+		}
+	})
+}
+
+func TestImportAgain(t *testing.T) {
+	toml := `[[workflow]]
+	identifier = "KEPT"
+	active = true
+	name = "Kept"
+
+	[[workflow.step]]
+	active = true
+	name = "Post message"
+	variety = "postMatrixMessage"
+
+	[workflow.step.meta]
+	room = "#room"
+
+	[[workflow]]
+	identifier = "CHANGED"
+	active = true
+	name = "Changed"
+
+	[[workflow.step]]
+	active = true
+	name = "Post message"
+	variety = "postMatrixMessage"
+
+	[workflow.step.meta]
+	room = "#room"`
+
+	removed := `
+
+	[[workflow]]
+	identifier = "REMOVED"
+	active = true
+
+	[[workflow.step]]
+	variety = "stdout"`
+
+	tomlFilePath := "./toml_file_for_testing.toml"
+	defer os.Remove(tomlFilePath)
+
+	tables := []struct {
+		prune    Prune
+		expected Summary
+		deleted  bool // whether the workflow no longer defined is deleted from the database
+	}{
+		{prune: PruneNone, expected: Summary{Updated: []string{"CHANGED"}, Unchanged: []string{"KEPT"}}},
+		{prune: PruneDeactivate, expected: Summary{Updated: []string{"CHANGED"}, Unchanged: []string{"KEPT"}, Deactivated: []string{"REMOVED"}}},
+		{prune: PruneDelete, expected: Summary{Updated: []string{"CHANGED"}, Unchanged: []string{"KEPT"}, Deleted: []string{"REMOVED"}}, deleted: true},
+	}
+
+	for _, table := range tables {
+		database.Test(func(session db.Session) {
+			wfRepo := workflow.NewRepository(session)
+			wfsRepo := workflowstep.NewRepository(session)
+			trRepo := trigger.NewRepository(session)
+
+			os.WriteFile(tomlFilePath, []byte(toml+removed), 0644)
+			if _, err := Import(tomlFilePath, table.prune, wfRepo, wfsRepo, trRepo, steps.Schemas(), trigger.Checks()); err != nil {
+				t.Fatalf("valid toml import failed: %s", err)
+			}
+
+			os.WriteFile(tomlFilePath, []byte(strings.Replace(toml, `name = "Changed"`, `name = "Changed again"`, 1)), 0644)
+			summary, err := Import(tomlFilePath, table.prune, wfRepo, wfsRepo, trRepo, steps.Schemas(), trigger.Checks())
+			if err != nil {
+				t.Fatalf("valid toml import failed: %s", err)
+			}
+			if !reflect.DeepEqual(summary, table.expected) {
+				t.Errorf("%q: unexpected summary: %s", table.prune, summary)
+			}
+
+			workflow, err := wfRepo.FindByIdentifier("REMOVED")
+			if table.deleted != (err != nil) {
+				t.Errorf("%q: expected removed workflow to be deleted: %t, got error: %v", table.prune, table.deleted, err)
+			}
+			if err == nil && workflow.Active != (table.prune == PruneNone) {
+				t.Errorf("%q: unexpected active state of removed workflow: %t", table.prune, workflow.Active)
+			}
+		})
+	}
+}
+
+func TestImportRollback(t *testing.T) {
+	// Workflows are saved one after the other, a transaction makes sure none of them is when one fails
+	toml := `[[workflow]]
+	identifier = "FIRST"
+	active = true
+
+	[[workflow.step]]
+	variety = "stdout"
+
+	[[workflow]]
+	identifier = "SECOND"
+	active = true
+
+	[[workflow.step]]
+	variety = "stdout"`
+
+	tomlFilePath := "./toml_file_for_testing.toml"
+	os.WriteFile(tomlFilePath, []byte(toml), 0644)
+	defer os.Remove(tomlFilePath)
+
+	// database.Test runs within a transaction already, nesting another one would lock the tables
+	session := database.MakeTestDatabaseSession()
+	defer session.Close()
+
+	err := session.Tx(func(tx db.Session) error {
+		wfRepo := failingRepository{Repository: workflow.NewRepository(tx), failOn: "SECOND"}
+		_, err := Import(tomlFilePath, PruneNone, wfRepo, workflowstep.NewRepository(tx), trigger.NewRepository(tx), steps.Schemas(), trigger.Checks())
+		return err
 	})
+	if !errors.Is(err, errFailingRepository) {
+		t.Fatalf("expected import to fail saving the second workflow, got: %v", err)
+	}
+
+	if _, err = workflow.NewRepository(session).FindByIdentifier("FIRST"); err == nil {
+		t.Errorf("expected nothing to be imported when the import fails")
+	}
+}
+
+var errFailingRepository = errors.New("failed to save")
+
+// failingRepository fails to save a workflow.
+type failingRepository struct {
+	wfm.Repository
+	failOn string
+}
+
+func (r failingRepository) Save(workflow *wfm.Workflow) error {
+	if workflow.Identifier == r.failOn {
+		return errFailingRepository
+	}
+
+	return r.Repository.Save(workflow)
 }
 
 func TestParse(t *testing.T) {
@@ -68,22 +274,25 @@ func TestParse(t *testing.T) {
 	active = true
 	name = "Workflow1"
 	description = "Some description"
+	onError = "halt"
 
 	[[workflow.step]]
 	active = true
 	name = "Post message"
 	description = "Post message to a matrix room"
 	variety = "postMatrixMessage"
+	onError = "retry"
+	maxAttempts = 5
 
 	[workflow.step.meta]
 	messagePrefix = "[Alert]"
-	matrixRoom = "#room"`
+	room = "#room"`
 
 	tomlFilePath := "./toml_file_for_testing.toml"
 	os.WriteFile(tomlFilePath, []byte(toml), 0644)
 	defer os.Remove(tomlFilePath)
 
-	got, err := parse(tomlFilePath)
+	got, err := parse(tomlFilePath, steps.Schemas(), trigger.Checks())
 	if err != nil {
 		t.Errorf("could not parse toml file: %s", err)
 	}
@@ -95,15 +304,22 @@ func TestParse(t *testing.T) {
 				Active:      true,
 				Name:        "Workflow1",
 				Description: "Some description",
+				errorPolicyTOML: errorPolicyTOML{
+					OnError: "halt",
+				},
 				Steps: []workflowStepTOML{
 					{
 						Active:      true,
 						Name:        "Post message",
 						Description: "Post message to a matrix room",
 						Variety:     "postMatrixMessage",
+						errorPolicyTOML: errorPolicyTOML{
+							OnError:     "retry",
+							MaxAttempts: 5,
+						},
 						Meta: map[string]string{
 							"messagePrefix": "[Alert]",
-							"matrixRoom":    "#room",
+							"room":          "#room",
 						},
 					},
 				},
@@ -133,7 +349,7 @@ func TestSemanticCheckOnTOML(t *testing.T) {
 						Variety:     "postMatrixMessage",
 						Meta: map[string]string{
 							"messagePrefix": "[Alert]",
-							"matrixRoom":    "",
+							"room":          "",
 						},
 					},
 				},
@@ -141,7 +357,7 @@ func TestSemanticCheckOnTOML(t *testing.T) {
 		},
 	}
 
-	err := runSemanticCheckOnTOML(def)
+	err := runSemanticCheckOnTOML(def, steps.Schemas(), trigger.Checks())
 	if err != nil {
 		t.Errorf("semantic check on valid toml (def1) failed: %s", err)
 	}
@@ -162,7 +378,7 @@ func TestSemanticCheckOnTOML(t *testing.T) {
 						Variety:     "postMatrixMessage",
 						Meta: map[string]string{
 							"messagePrefix": "[Alert]",
-							"matrixRoom":    "",
+							"room":          "",
 						},
 					},
 				},
@@ -180,7 +396,7 @@ func TestSemanticCheckOnTOML(t *testing.T) {
 						Variety:     "postMatrixMessage",
 						Meta: map[string]string{
 							"messagePrefix": "[Alert]",
-							"matrixRoom":    "",
+							"room":          "",
 						},
 					},
 				},
@@ -188,7 +404,7 @@ func TestSemanticCheckOnTOML(t *testing.T) {
 		},
 	}
 
-	err = runSemanticCheckOnTOML(def)
+	err = runSemanticCheckOnTOML(def, steps.Schemas(), trigger.Checks())
 	if err == nil {
 		t.Errorf("semantic check on invalid toml (duplicate identifier) did not fail")
 	}
@@ -205,10 +421,190 @@ func TestSemanticCheckOnTOML(t *testing.T) {
 		},
 	}
 
-	err = runSemanticCheckOnTOML(def)
+	err = runSemanticCheckOnTOML(def, steps.Schemas(), trigger.Checks())
 	if err == nil {
 		t.Errorf("semantic check on invalid toml (missing workflow steps) did not fail")
 	}
+
+	// Testing with invalid TOML - invalid cron expression of schedule trigger
+	def = workflowDefintionTOML{
+		Workflows: []workflowTOML{
+			{
+				Identifier:  "TESTME",
+				Active:      true,
+				Name:        "Workflow1",
+				Description: "Some description",
+				Triggers: []triggerTOML{
+					{
+						Variety: "schedule",
+						Meta: map[string]string{
+							"cron": "0 25 * * *",
+						},
+					},
+				},
+				Steps: []workflowStepTOML{
+					{
+						Active:      true,
+						Name:        "Step1",
+						Description: "Some description",
+						Variety:     "postMatrixMessage",
+					},
+				},
+			},
+		},
+	}
+
+	err = runSemanticCheckOnTOML(def, steps.Schemas(), trigger.Checks())
+	if err == nil {
+		t.Errorf("semantic check on invalid toml (invalid cron expression) did not fail")
+	}
+
+	// Testing with invalid TOML - invalid condition of workflow step
+	def = workflowDefintionTOML{
+		Workflows: []workflowTOML{
+			{
+				Identifier:  "TESTME",
+				Active:      true,
+				Name:        "Workflow1",
+				Description: "Some description",
+				Steps: []workflowStepTOML{
+					{
+						Active:      true,
+						Name:        "Step1",
+						Description: "Some description",
+						Variety:     "postMatrixMessage",
+						If:          `severity == "critical"`,
+					},
+				},
+			},
+		},
+	}
+
+	err = runSemanticCheckOnTOML(def, steps.Schemas(), trigger.Checks())
+	if err == nil {
+		t.Errorf("semantic check on invalid toml (invalid step condition) did not fail")
+	}
+
+	// Testing with invalid TOML - unknown variety of workflow step
+	def = workflowDefintionTOML{
+		Workflows: []workflowTOML{
+			{
+				Identifier:  "TESTME",
+				Active:      true,
+				Name:        "Workflow1",
+				Description: "Some description",
+				Steps: []workflowStepTOML{
+					{
+						Active:      true,
+						Name:        "Step1",
+						Description: "Some description",
+						Variety:     "sendFax",
+					},
+				},
+			},
+		},
+	}
+
+	err = runSemanticCheckOnTOML(def, steps.Schemas(), trigger.Checks())
+	if err == nil {
+		t.Errorf("semantic check on invalid toml (unknown step variety) did not fail")
+	}
+
+	// Testing with invalid TOML - invalid meta of workflow step
+	def = workflowDefintionTOML{
+		Workflows: []workflowTOML{
+			{
+				Identifier:  "TESTME",
+				Active:      true,
+				Name:        "Workflow1",
+				Description: "Some description",
+				Steps: []workflowStepTOML{
+					{
+						Active:      true,
+						Name:        "Step1",
+						Description: "Some description",
+						Variety:     "httpRequest",
+						Meta: map[string]string{
+							"url":     "https://example.com",
+							"timeout": "soon",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	err = runSemanticCheckOnTOML(def, steps.Schemas(), trigger.Checks())
+	if err == nil {
+		t.Errorf("semantic check on invalid toml (invalid step meta) did not fail")
+	}
+
+	// Testing with invalid TOML - invalid timeout of workflow step
+	def = workflowDefintionTOML{
+		Workflows: []workflowTOML{
+			{
+				Identifier:  "TESTME",
+				Active:      true,
+				Name:        "Workflow1",
+				Description: "Some description",
+				Timeout:     "5m",
+				Steps: []workflowStepTOML{
+					{
+						Active:      true,
+						Name:        "Step1",
+						Description: "Some description",
+						Variety:     "postMatrixMessage",
+						Timeout:     "-10s",
+					},
+				},
+			},
+		},
+	}
+
+	err = runSemanticCheckOnTOML(def, steps.Schemas(), trigger.Checks())
+	if err == nil {
+		t.Errorf("semantic check on invalid toml (invalid step timeout) did not fail")
+	}
+
+	// Testing with invalid TOML - webhook trigger authenticated without a secret
+	def = workflowDefintionTOML{
+		Workflows: []workflowTOML{
+			{
+				Identifier:  "TESTME",
+				Active:      true,
+				Name:        "Workflow1",
+				Description: "Some description",
+				Triggers: []triggerTOML{
+					{
+						Variety: "webhook",
+						Meta: map[string]string{
+							"auth": "github",
+						},
+					},
+				},
+				Steps: []workflowStepTOML{
+					{
+						Active:      true,
+						Name:        "Step1",
+						Description: "Some description",
+						Variety:     "postMatrixMessage",
+					},
+				},
+			},
+		},
+	}
+
+	err = runSemanticCheckOnTOML(def, steps.Schemas(), trigger.Checks())
+	if err == nil {
+		t.Errorf("semantic check on invalid toml (webhook auth without secret) did not fail")
+	}
+
+	// Testing with invalid TOML - webhook trigger with an unknown mode
+	def.Workflows[0].Triggers[0].Meta = map[string]string{"mode": "later"}
+	err = runSemanticCheckOnTOML(def, steps.Schemas(), trigger.Checks())
+	if err == nil {
+		t.Errorf("semantic check on invalid toml (webhook mode) did not fail")
+	}
 }
 
 func TestPrepare(t *testing.T) {
@@ -221,6 +617,14 @@ func TestPrepare(t *testing.T) {
 			Active:      true,
 			Name:        "Workflow1",
 			Description: "Some description",
+			Triggers: []triggerTOML{
+				{
+					Variety: "schedule",
+					Meta: map[string]string{
+						"cron": "@daily",
+					},
+				},
+			},
 			Steps: []workflowStepTOML{
 				{
 					Active:      true,
@@ -229,13 +633,13 @@ func TestPrepare(t *testing.T) {
 					Variety:     "postMatrixMessage",
 					Meta: map[string]string{
 						"messagePrefix": "[Alert]",
-						"matrixRoom":    "",
+						"room":          "",
 					},
 				},
 			},
 		}
 
-		preparedWorkflow, preparedSteps, err := prepare(def, wfRepo, wfsRepo)
+		preparedWorkflow, preparedSteps, preparedTriggers, err := prepare(def, wfRepo, wfsRepo)
 		if err != nil {
 			t.Errorf("could not prepare: %s", err)
 		}
@@ -255,7 +659,7 @@ func TestPrepare(t *testing.T) {
 				Active:      true,
 				Meta: map[string]string{
 					"messagePrefix": "[Alert]",
-					"matrixRoom":    "",
+					"room":          "",
 				},
 			},
 		}
@@ -266,5 +670,18 @@ func TestPrepare(t *testing.T) {
 		if !reflect.DeepEqual(preparedSteps, expectedSteps) {
 			t.Errorf("prepare output not as expected - diff workflow steps\n%v\n%v", preparedSteps, expectedSteps)
 		}
+
+		expectedTriggers := []trm.Trigger{
+			{
+				Variety: "schedule",
+				Meta: map[string]string{
+					"cron": "@daily",
+				},
+			},
+		}
+
+		if !reflect.DeepEqual(preparedTriggers, expectedTriggers) {
+			t.Errorf("prepare output not as expected - diff triggers\n%v\n%v", preparedTriggers, expectedTriggers)
+		}
 	})
 }
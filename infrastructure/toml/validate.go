@@ -0,0 +1,102 @@
+package toml
+
+import (
+	"fmt"
+	"neurobot/model/room"
+	"neurobot/model/trigger"
+	"neurobot/model/workflowstep"
+	"strings"
+)
+
+// Environment is what Validate checks a TOML file against, besides what importing it checks.
+type Environment struct {
+	StepSchemas   map[string]workflowstep.Schema
+	TriggerChecks map[string]trigger.Check
+	Bots          []string // usernames of the bots triggers and steps can act as, besides the primary bot
+}
+
+// Validate checks a TOML file without importing it, nor connecting to anything. On top of the checks made when
+// importing it, rooms must be valid room IDs and bots must be known. Meta values which are templates are only known
+// once rendered, they are not checked.
+// Every problem found is returned, unless the file can't be imported at all, in which case only the reason is.
+func Validate(tomlFilePath string, env Environment) (problems []error) {
+	def, err := parse(tomlFilePath, env.StepSchemas, env.TriggerChecks)
+	if err != nil {
+		return []error{err}
+	}
+
+	bots := map[string]bool{"": true} // the primary bot
+	for _, bot := range env.Bots {
+		bots[bot] = true
+	}
+
+	for _, w := range def.Workflows {
+		for _, t := range w.Triggers {
+			if err := checkTriggerEnvironment(t, bots); err != nil {
+				problems = append(problems, fmt.Errorf("invalid %s trigger for workflow in TOML with ID:%s: %w", t.Variety, w.Identifier, err))
+			}
+		}
+
+		for i, s := range w.Steps {
+			if err := checkStepEnvironment(s, bots); err != nil {
+				problems = append(problems, fmt.Errorf("invalid %s step #%d for workflow in TOML with ID:%s: %w", s.Variety, i+1, w.Identifier, err))
+			}
+		}
+	}
+
+	return
+}
+
+func checkTriggerEnvironment(t triggerTOML, bots map[string]bool) error {
+	// Rooms of triggers are checked by their variety when importing them
+	for _, bot := range split(t.Meta["asBot"]) {
+		if err := checkBot(bot, bots); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func checkStepEnvironment(s workflowStepTOML, bots map[string]bool) error {
+	if err := checkRoom(s.Meta["room"]); err != nil {
+		return err
+	}
+
+	return checkBot(s.Meta["asBot"], bots)
+}
+
+// checkRoom makes sure a room is a valid room ID or alias, empty meaning the room is taken from the payload.
+func checkRoom(roomID string) error {
+	if roomID == "" || isTemplate(roomID) {
+		return nil
+	}
+
+	_, err := room.NewID(roomID)
+
+	return err
+}
+
+// checkBot makes sure a bot is known, empty meaning the primary bot.
+func checkBot(bot string, bots map[string]bool) error {
+	if isTemplate(bot) || bots[bot] {
+		return nil
+	}
+
+	return fmt.Errorf("unknown bot %q", bot)
+}
+
+func isTemplate(value string) bool {
+	return strings.Contains(value, "{{")
+}
+
+// split splits a comma separated meta value.
+func split(value string) (items []string) {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return
+}
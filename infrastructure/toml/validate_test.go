@@ -0,0 +1,83 @@
+package toml
+
+import (
+	"neurobot/app/engine/steps"
+	"neurobot/app/trigger"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	toml := `[[workflow]]
+	identifier = "VALID"
+	active = true
+
+	[[workflow.trigger]]
+	variety = "matrixCommand"
+
+	[workflow.trigger.meta]
+	command = "!deploy"
+	rooms = "!ops:matrix.test, #dev:matrix.test"
+	asBot = "afkbot"
+
+	[[workflow.step]]
+	active = true
+	variety = "postMatrixMessage"
+
+	[workflow.step.meta]
+	room = "{{ .room }}"
+	asBot = "{{ .bot }}"
+
+	[[workflow]]
+	identifier = "INVALID"
+	active = true
+
+	[[workflow.trigger]]
+	variety = "matrixReaction"
+
+	[workflow.trigger.meta]
+	asBot = "nobody"
+
+	[[workflow.step]]
+	active = true
+	variety = "postMatrixMessage"
+
+	[workflow.step.meta]
+	room = "ops"`
+
+	tomlFilePath := "./toml_file_for_testing.toml"
+	os.WriteFile(tomlFilePath, []byte(toml), 0644)
+	defer os.Remove(tomlFilePath)
+
+	env := Environment{
+		StepSchemas:   steps.Schemas(),
+		TriggerChecks: trigger.Checks(),
+		Bots:          []string{"afkbot"},
+	}
+
+	problems := Validate(tomlFilePath, env)
+	expected := []string{"unknown bot", "room id must start with ! or #"}
+	if len(problems) != len(expected) {
+		t.Fatalf("expected %d problems, got %v", len(expected), problems)
+	}
+	for i, problem := range problems {
+		if !strings.Contains(problem.Error(), "INVALID") || !strings.Contains(problem.Error(), expected[i]) {
+			t.Errorf("expected problem about %q, got: %s", expected[i], problem)
+		}
+	}
+
+	// Files which can't be imported only have that problem
+	for _, invalid := range []string{`variety = "carrierPigeon"`, `variety = "shedule"`} {
+		os.WriteFile(tomlFilePath, []byte(strings.Replace(toml, `variety = "matrixReaction"`, invalid, 1)), 0644)
+		if problems = Validate(tomlFilePath, env); len(problems) != 1 || !strings.Contains(problems[0].Error(), "unknown variety") {
+			t.Errorf("expected a single problem about an unknown variety, got %v", problems)
+		}
+	}
+
+	// Invalid trigger meta can't be imported either
+	os.WriteFile(tomlFilePath, []byte(strings.Replace(toml, `command = "!deploy"`, `command = "!deploy now"`, 1)), 0644)
+	if problems = Validate(tomlFilePath, env); len(problems) != 1 || !strings.Contains(problems[0].Error(), "single word") {
+		t.Errorf("expected a single problem about the command, got %v", problems)
+	}
+}
@@ -0,0 +1,67 @@
+package toml
+
+import (
+	"os"
+	"time"
+
+	"github.com/apex/log"
+)
+
+// Watcher checks a TOML file at a regular interval, and calls a handler when it changed.
+type Watcher interface {
+	// Run watches until Stop is called, it is blocking.
+	Run()
+
+	// Stop stops watching.
+	Stop()
+}
+
+type watcher struct {
+	interval time.Duration
+	path     string
+	handler  func()
+	done     chan struct{}
+}
+
+// NewWatcher makes a watcher of the file at path, a change being a different modification time or size. Files saved
+// twice within the precision of modification times of the filesystem may go unnoticed, until they change again.
+func NewWatcher(interval time.Duration, path string, handler func()) Watcher {
+	return &watcher{
+		interval: interval,
+		path:     path,
+		handler:  handler,
+		done:     make(chan struct{}),
+	}
+}
+
+func (watcher *watcher) Run() {
+	ticker := time.NewTicker(watcher.interval)
+	defer ticker.Stop()
+
+	last, _ := os.Stat(watcher.path)
+	for {
+		select {
+		case <-watcher.done:
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(watcher.path)
+		if err != nil {
+			// Editors may replace the file, it is checked again on the next tick
+			log.WithError(err).WithFields(log.Fields{"path": watcher.path}).Debug("Failed to check TOML file")
+			continue
+		}
+
+		if last != nil && info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size() {
+			continue
+		}
+		last = info
+
+		watcher.handler()
+	}
+}
+
+func (watcher *watcher) Stop() {
+	close(watcher.done)
+}
@@ -0,0 +1,45 @@
+package toml
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "workflows.toml")
+	if err := os.WriteFile(path, []byte("[[workflow]]\n"), 0644); err != nil {
+		t.Fatalf("failed to write TOML file: %s", err)
+	}
+
+	changes := make(chan struct{}, 10)
+	watcher := NewWatcher(10*time.Millisecond, path, func() {
+		changes <- struct{}{}
+	})
+
+	go watcher.Run()
+	defer watcher.Stop()
+
+	select {
+	case <-changes:
+		t.Fatalf("unchanged file should not be handled")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := os.WriteFile(path, []byte("[[workflow]]\nidentifier = \"foo\"\n"), 0644); err != nil {
+		t.Fatalf("failed to write TOML file: %s", err)
+	}
+
+	select {
+	case <-changes:
+	case <-time.After(time.Second):
+		t.Fatalf("changed file should be handled")
+	}
+
+	select {
+	case <-changes:
+		t.Fatalf("change should be handled once")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -1,20 +1,32 @@
 package main
 
 import (
+	"context"
 	"flag"
 	application "neurobot/app"
+	"neurobot/app/admin"
 	botApp "neurobot/app/bot"
 	configuration "neurobot/app/config"
 	"neurobot/app/engine"
+	"neurobot/app/engine/steps"
+	"neurobot/app/run"
+	"neurobot/app/runner/afk_notifier"
+	"neurobot/app/trigger"
 	"neurobot/app/workflow"
 	"neurobot/app/workflowstep"
 	"neurobot/infrastructure/database"
+	"neurobot/infrastructure/event"
 	"neurobot/infrastructure/http"
 	"neurobot/infrastructure/matrix"
 	"neurobot/infrastructure/toml"
 	b "neurobot/model/bot"
+	t "neurobot/model/trigger"
+	w "neurobot/model/workflow"
 	"neurobot/resources/seeds"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 
 	"github.com/apex/log"
 	"github.com/upper/db/v4"
@@ -23,8 +35,28 @@ import (
 var envFile = flag.String("env", "./.env", ".env file")
 
 func main() {
-	logger := log.Log
+	flag.Usage = usage
 	flag.Parse()
+
+	switch command := flag.Arg(0); command {
+	case "":
+		serve()
+	case "export":
+		export(flag.Args()[1:])
+	case "validate":
+		validate(flag.Args()[1:])
+	case "diff":
+		diff(flag.Args()[1:])
+	default:
+		log.Errorf("Unknown command %q", command)
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// serve runs the workflows until the program is signalled to stop.
+func serve() {
+	logger := log.Log
 	config := configuration.LoadFromEnvFile(*envFile)
 
 	if config.Debug {
@@ -32,45 +64,212 @@ func main() {
 	}
 
 	databaseSession := database.MakeDatabaseSession(config.DatabasePath)
-	defer databaseSession.Close()
 
 	botRepository := botApp.NewRepository(databaseSession)
 	workflowRepository := workflow.NewRepository(databaseSession)
 	workflowStepsRepository := workflowstep.NewRepository(databaseSession)
+	triggerRepository := trigger.NewRepository(databaseSession)
+	triggerStateRepository := trigger.NewStateRepository(databaseSession)
+	runRepository := run.NewRepository(databaseSession)
 
 	// Seed database.
 	seeds.Bots(botRepository, config)
 
 	// import TOML
-	err := toml.Import(config.WorkflowsTOMLPath, workflowRepository, workflowStepsRepository)
+	var summary toml.Summary
+	err := importWorkflows(databaseSession, config, &summary)(func(t.Repository, w.Repository) error { return nil })
 	if err != nil {
 		logger.WithError(err).WithFields(log.Fields{
 			"path": config.WorkflowsTOMLPath,
 		}).Fatal("Failed to import TOML workflows")
 	}
+	logger.WithFields(log.Fields{"path": config.WorkflowsTOMLPath}).Infof("Imported TOML workflows: %s", summary)
 
-	botRegistry := makeBotRegistry(config.ServerName, botRepository, databaseSession)
+	newBotClient := makeBotClientFactory(config.ServerName, databaseSession)
+	botRegistry := makeBotRegistry(config.ServerName, botRepository, newBotClient)
 	webhookListenerServer := http.NewServer(config.WebhookListenerPort)
 
-	e := engine.NewEngine(botRegistry, workflowStepsRepository)
+	eventBus := event.NewMemoryBus()
+
+	e := engine.NewEngine(botRegistry, workflowStepsRepository, runRepository)
+
+	app := application.NewApp(e, botRegistry, workflowRepository, triggerRepository, triggerStateRepository, runRepository, webhookListenerServer, eventBus, config.Workers)
+
+	// Workflows which are run by custom code, instead of by the engine.
+	if afkClient, err := botRegistry.GetClient("afkbot"); err == nil {
+		app.RegisterRunner("afk_notifier", afk_notifier.NewRunner(afkClient))
+	}
 
-	app := application.NewApp(e, botRegistry, workflowRepository, webhookListenerServer)
 	if err := app.Run(); err != nil {
 		logger.WithError(err).Fatal("Failed to run application")
 	}
 
-	logger.WithFields(log.Fields{
-		"port": config.WebhookListenerPort,
-	}).Infof("Starting webhook listener")
-	webhookListenerServer.Run() // blocking
+	var adminServer *http.Server
+	if config.AdminAPIPort != 0 {
+		adminServer = http.NewServer(config.AdminAPIPort)
+		api := admin.NewAPI(
+			config.AdminAPIToken,
+			workflowRepository,
+			workflowStepsRepository,
+			triggerRepository,
+			botRepository,
+			botRegistry,
+			newBotClient,
+			eventBus,
+			adminTransaction(databaseSession, app.ReloadWithin),
+			steps.Schemas(),
+			trigger.Checks(),
+		)
+		if err := adminServer.RegisterHandler("/", api); err != nil {
+			logger.WithError(err).Fatal("Failed to register admin API")
+		}
+	}
+
+	// Reload the TOML file on SIGHUP, and when it changes if watched
+	reload := func(reason string) {
+		logger := logger.WithFields(log.Fields{"path": config.WorkflowsTOMLPath, "reason": reason})
+
+		var summary toml.Summary
+		if err := app.ReloadWithin(importWorkflows(databaseSession, config, &summary)); err != nil {
+			logger.WithError(err).Error("Failed to reload TOML workflows, keeping the previous ones")
+			return
+		}
+		logger.Infof("Reloaded TOML workflows: %s", summary)
+	}
+
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	go func() {
+		for range hangups {
+			reload("SIGHUP")
+		}
+	}()
+
+	var watcher toml.Watcher
+	if config.WatchInterval > 0 {
+		watcher = toml.NewWatcher(config.WatchInterval, config.WorkflowsTOMLPath, func() {
+			reload("file changed")
+		})
+		go watcher.Run()
+	}
+
+	signals, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+
+	serverErr := make(chan error, 2)
+	go func() {
+		logger.WithFields(log.Fields{
+			"port": config.WebhookListenerPort,
+		}).Infof("Starting webhook listener")
+		serverErr <- webhookListenerServer.Run()
+	}()
+
+	if adminServer != nil {
+		go func() {
+			logger.WithFields(log.Fields{
+				"port": config.AdminAPIPort,
+			}).Infof("Starting admin API")
+			serverErr <- adminServer.Run()
+		}()
+	}
+
+	select {
+	case err = <-serverErr:
+	case <-signals.Done():
+		logger.Info("Shutting down")
+	}
+	stopSignals() // a second signal stops the program right away
+	signal.Stop(hangups)
+	if watcher != nil {
+		watcher.Stop()
+	}
+
+	// Everything shares the same deadline, most of which is meant for the workflows still running to finish
+	ctx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancel()
+
+	if shutdownErr := webhookListenerServer.Shutdown(ctx); shutdownErr != nil {
+		logger.WithError(shutdownErr).Error("Failed to shut webhook listener down")
+	}
+	if adminServer != nil {
+		if shutdownErr := adminServer.Shutdown(ctx); shutdownErr != nil {
+			logger.WithError(shutdownErr).Error("Failed to shut admin API down")
+		}
+	}
+	app.Stop(ctx)
+	botRegistry.Stop()
+
+	if closeErr := databaseSession.Close(); closeErr != nil {
+		logger.WithError(closeErr).Error("Failed to close database session")
+	}
+
+	if err != nil {
+		logger.WithError(err).Fatal("HTTP server failed")
+	}
+
+	logger.Info("Stopped")
 }
 
-func makeBotRegistry(serverName string, botRepository b.Repository, db db.Session) (registry botApp.Registry) {
+// importWorkflows makes the transaction which imports the TOML file, the workflows it imported being read from within
+// it. Nothing is imported when anything fails, summary tells what was otherwise.
+func importWorkflows(databaseSession db.Session, config *configuration.Config, summary *toml.Summary) application.Transaction {
+	return func(fn func(t.Repository, w.Repository) error) error {
+		return databaseSession.Tx(func(tx db.Session) (err error) {
+			workflowRepository := workflow.NewRepository(tx)
+			triggerRepository := trigger.NewRepository(tx)
+
+			*summary, err = toml.Import(
+				config.WorkflowsTOMLPath,
+				toml.Prune(config.Prune),
+				workflowRepository,
+				workflowstep.NewRepository(tx),
+				triggerRepository,
+				steps.Schemas(),
+				trigger.Checks(),
+			)
+			if err != nil {
+				return
+			}
+
+			return fn(triggerRepository, workflowRepository)
+		})
+	}
+}
+
+// adminTransaction makes the transaction changes made through the admin API are made within, triggers being reloaded
+// from within it so that the changes they fail to load are rolled back.
+func adminTransaction(databaseSession db.Session, reloadWithin func(application.Transaction) error) admin.Transaction {
+	return func(fn func(admin.Repositories) error) error {
+		return reloadWithin(func(load func(t.Repository, w.Repository) error) error {
+			return databaseSession.Tx(func(tx db.Session) error {
+				repositories := admin.Repositories{
+					Workflows:     workflow.NewRepository(tx),
+					WorkflowSteps: workflowstep.NewRepository(tx),
+					Triggers:      trigger.NewRepository(tx),
+				}
+				if err := fn(repositories); err != nil {
+					return err
+				}
+
+				return load(repositories.Triggers, repositories.Workflows)
+			})
+		})
+	}
+}
+
+// makeBotClientFactory makes the function which makes the matrix client of a bot, for the homeserver of serverName.
+func makeBotClientFactory(serverName string, db db.Session) admin.ClientFactory {
 	homeserverURL, err := matrix.DiscoverServerURL(serverName)
 	if err != nil {
 		log.WithError(err).Fatal("Failed to discover homeserver URL")
 	}
 
+	return func(bot b.Bot) (matrix.Client, error) {
+		return matrix.NewMautrixClient(homeserverURL, matrix.NewStorer(db, bot.ID), true)
+	}
+}
+
+func makeBotRegistry(serverName string, botRepository b.Repository, newClient admin.ClientFactory) (registry botApp.Registry) {
 	bots, err := botRepository.FindActive()
 	if err != nil {
 		log.WithError(err).Fatal("Failed to find active bots")
@@ -80,16 +279,15 @@ func makeBotRegistry(serverName string, botRepository b.Repository, db db.Sessio
 	registry = botApp.NewRegistry(serverNameWithoutPort)
 
 	for _, bot := range bots {
-		storer := matrix.NewStorer(db, bot.ID)
 		var client matrix.Client
-		client, err = matrix.NewMautrixClient(homeserverURL, storer, true)
+		client, err = newClient(bot)
 		if err != nil {
 			log.WithError(err).WithFields(log.Fields{
 				"username": bot.Username,
 			}).Fatal("Failed to login as bot")
 		}
 
-		err = registry.Append(bot, client)
+		err = registry.Append(context.Background(), bot, client)
 		if err != nil {
 			log.WithError(err).WithFields(log.Fields{
 				"username": bot.Username,
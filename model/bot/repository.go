@@ -5,9 +5,18 @@ type Repository interface {
 	// Save persists a bot.
 	Save(bot *Bot) error
 
+	// FindAll retrieves all bots, active or not.
+	FindAll() ([]Bot, error)
+
 	// FindActive retrieves all active bots.
 	FindActive() ([]Bot, error)
 
+	// FindByID retrieves a bot by its ID.
+	FindByID(ID uint64) (Bot, error)
+
 	// FindByUsername retrieves a bot by its unique username.
 	FindByUsername(username string) (Bot, error)
+
+	// Remove removes a bot.
+	Remove(ID uint64) error
 }
@@ -0,0 +1,42 @@
+package payload
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Lookup returns the value found at a dot separated path in a decoded JSON document, like `data.items.0.id`.
+// Keys of objects may contain dots themselves, like `user.login`, in which case they are found as is.
+func Lookup(value interface{}, path string) (interface{}, bool) {
+	if path == "" {
+		return value, true
+	}
+
+	segment, rest := path, ""
+	if i := strings.Index(path, "."); i >= 0 {
+		segment, rest = path[:i], path[i+1:]
+	}
+
+	switch v := value.(type) {
+	case Payload:
+		return Lookup(map[string]interface{}(v), path)
+	case map[string]interface{}:
+		if child, ok := v[path]; ok {
+			return child, true
+		}
+
+		child, ok := v[segment]
+		if !ok {
+			return nil, false
+		}
+		return Lookup(child, rest)
+	case []interface{}:
+		i, err := strconv.Atoi(segment)
+		if err != nil || i < 0 || i >= len(v) {
+			return nil, false
+		}
+		return Lookup(v[i], rest)
+	}
+
+	return nil, false
+}
@@ -0,0 +1,42 @@
+package payload
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func decode(document string) (value interface{}) {
+	_ = json.Unmarshal([]byte(document), &value)
+	return
+}
+
+func TestLookup(t *testing.T) {
+	document := decode(`{"data": {"items": [{"id": "a"}, {"id": "b"}], "dotted.key": "c"}}`)
+
+	tables := []struct {
+		path     string
+		expected interface{}
+		found    bool
+	}{
+		{path: "data.items.1.id", expected: "b", found: true},
+		{path: "data.items.2.id", found: false},
+		{path: "data.items.x", found: false},
+		{path: "data.missing", found: false},
+		{path: "data.items.0.id.foo", found: false},
+		{path: "", expected: document, found: true},
+		{path: "data.dotted.key", expected: "c", found: true},
+	}
+
+	for _, table := range tables {
+		got, found := Lookup(document, table.path)
+		if found != table.found {
+			t.Errorf("%s: expected found to be %t", table.path, table.found)
+			continue
+		}
+
+		if found && !reflect.DeepEqual(got, table.expected) {
+			t.Errorf("%s: unexpected value %+v", table.path, got)
+		}
+	}
+}
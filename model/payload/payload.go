@@ -0,0 +1,119 @@
+package payload
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Payload is the data a workflow is started with, which is passed from one workflow step to the next.
+// It is JSON compatible: values are strings, numbers, booleans, nil, objects (map[string]interface{}) and arrays
+// ([]interface{}), which can be nested and reached with a dot separated path, like `pull_request.user.login`.
+type Payload map[string]interface{}
+
+// Decode decodes a JSON object into a payload, numbers are kept as json.Number so that they don't lose precision.
+func Decode(data []byte) (Payload, error) {
+	var p Payload
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&p); err != nil {
+		return nil, err
+	}
+
+	if p == nil {
+		return nil, fmt.Errorf("expected a JSON object")
+	}
+
+	return p, nil
+}
+
+// Get returns the value found at a dot separated path, like `pull_request.user.login` or `commits.0.id`.
+func (p Payload) Get(path string) (interface{}, bool) {
+	return Lookup(map[string]interface{}(p), path)
+}
+
+// String returns the value found at a path as a string, which is empty when nothing is found.
+func (p Payload) String(path string) string {
+	value, _ := p.Get(path)
+
+	return Format(value)
+}
+
+// Set stores a value at a dot separated path, making the objects along the path when they don't exist,
+// and replacing what isn't an object.
+func (p Payload) Set(path string, value interface{}) {
+	if nested, ok := value.(Payload); ok {
+		value = map[string]interface{}(nested)
+	}
+
+	segments := strings.Split(path, ".")
+	object := map[string]interface{}(p)
+	for _, segment := range segments[:len(segments)-1] {
+		child, ok := object[segment].(map[string]interface{})
+		if !ok {
+			child = make(map[string]interface{})
+			object[segment] = child
+		}
+		object = child
+	}
+
+	object[segments[len(segments)-1]] = value
+}
+
+// Clone returns a deep copy of the payload, so that a workflow step can modify it without affecting the payload
+// it was given.
+func (p Payload) Clone() Payload {
+	if p == nil {
+		return nil
+	}
+
+	return Payload(clone(map[string]interface{}(p)).(map[string]interface{}))
+}
+
+func clone(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			result[key] = clone(child)
+		}
+		return result
+	case Payload:
+		return clone(map[string]interface{}(v))
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, child := range v {
+			result[i] = clone(child)
+		}
+		return result
+	}
+
+	return value
+}
+
+// Format formats a payload value as a string: nil is empty, strings are as is, numbers and booleans are formatted as
+// in JSON, and objects and arrays are encoded as JSON.
+func Format(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case map[string]interface{}, Payload, []interface{}:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(encoded)
+	}
+
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,85 @@
+package payload
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	got, err := Decode([]byte(`{"user": {"login": "alice"}, "count": 2}`))
+	if err != nil {
+		t.Fatalf("failed to decode payload: %s", err)
+	}
+
+	expected := Payload{"user": map[string]interface{}{"login": "alice"}, "count": json.Number("2")}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected payload\n%+v\n%+v", got, expected)
+	}
+
+	for _, invalid := range []string{`[1, 2]`, `null`, `"text"`, `{"unterminated": `} {
+		if _, err = Decode([]byte(invalid)); err == nil {
+			t.Errorf("%s: decoding should fail", invalid)
+		}
+	}
+}
+
+func TestGetAndString(t *testing.T) {
+	p := Payload{
+		"message": "hello",
+		"count":   json.Number("10"),
+		"ratio":   0.5,
+		"ok":      true,
+		"none":    nil,
+		"user":    map[string]interface{}{"login": "alice"},
+		"tags":    []interface{}{"a", "b"},
+	}
+
+	tables := []struct {
+		path     string
+		expected string
+	}{
+		{path: "message", expected: "hello"},
+		{path: "count", expected: "10"},
+		{path: "ratio", expected: "0.5"},
+		{path: "ok", expected: "true"},
+		{path: "none", expected: ""},
+		{path: "missing", expected: ""},
+		{path: "user.login", expected: "alice"},
+		{path: "user", expected: `{"login":"alice"}`},
+		{path: "tags.1", expected: "b"},
+		{path: "tags", expected: `["a","b"]`},
+	}
+
+	for _, table := range tables {
+		if got := p.String(table.path); got != table.expected {
+			t.Errorf("%s: expected %q, got %q", table.path, table.expected, got)
+		}
+	}
+
+	if _, found := p.Get("user.missing"); found {
+		t.Errorf("expected nothing to be found at user.missing")
+	}
+}
+
+func TestSetAndClone(t *testing.T) {
+	p := Payload{"message": "hello", "user": map[string]interface{}{"login": "alice"}}
+	clone := p.Clone()
+
+	clone.Set("user.name", "Alice")
+	clone.Set("response.status", 200)
+	clone.Set("message.text", "replaced")
+
+	expected := Payload{
+		"message":  map[string]interface{}{"text": "replaced"},
+		"user":     map[string]interface{}{"login": "alice", "name": "Alice"},
+		"response": map[string]interface{}{"status": 200},
+	}
+	if !reflect.DeepEqual(clone, expected) {
+		t.Errorf("unexpected payload\n%+v\n%+v", clone, expected)
+	}
+
+	if !reflect.DeepEqual(p, Payload{"message": "hello", "user": map[string]interface{}{"login": "alice"}}) {
+		t.Errorf("original payload should not be modified, got %+v", p)
+	}
+}
@@ -0,0 +1,28 @@
+package run
+
+import "time"
+
+// Repository facilitates persistence and retrieval of the history of runs.
+type Repository interface {
+	// Save persists a run.
+	Save(run *Run) error
+
+	// SaveStep persists a step run.
+	SaveStep(step *StepRun) error
+
+	// FindByID retrieves a run by its ID.
+	FindByID(ID uint64) (Run, error)
+
+	// FindByWorkflowID retrieves the runs of a workflow started since a time, the latest first.
+	FindByWorkflowID(ID uint64, since time.Time) ([]Run, error)
+
+	// FindSteps retrieves the step runs of a run, in the order they ran.
+	FindSteps(runID uint64) ([]StepRun, error)
+
+	// Claim marks the oldest queued run as running and retrieves it, found is false when no run is queued.
+	// A run is never claimed twice, even when claimed concurrently.
+	Claim() (run Run, found bool, err error)
+
+	// Requeue marks all running runs as queued, for the runs which were interrupted.
+	Requeue() error
+}
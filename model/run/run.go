@@ -0,0 +1,52 @@
+package run
+
+import (
+	"neurobot/model/payload"
+	"time"
+)
+
+// Statuses of a run.
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Statuses of a step run.
+const (
+	StepStatusSucceeded = "succeeded"
+	StepStatusFailed    = "failed"
+	StepStatusSkipped   = "skipped" // the condition of the step didn't match
+	StepStatusHalted    = "halted"  // the step stopped the workflow
+)
+
+// Run is an execution of a workflow, kept in the history to know whether it went well.
+type Run struct {
+	ID         uint64
+	WorkflowID uint64
+	Trigger    string          // variety of the trigger which started the workflow, e.g. webhook
+	Payload    payload.Payload // payload the workflow was started with
+	Output     payload.Payload // payload once the workflow ran, empty until finished
+	Status     string
+	Error      string
+	Attempts   int       // how many times the run was started, more than once when interrupted by a restart
+	StartedAt  time.Time // when the run was queued, as of when its trigger was received
+	FinishedAt time.Time // zero until finished
+}
+
+// StepRun is the execution of a workflow step, as part of a run.
+type StepRun struct {
+	ID        uint64
+	RunID     uint64
+	StepID    uint64
+	Position  int // position of the step in the workflow, starting at 1
+	Name      string
+	Variety   string
+	Status    string
+	Attempts  int
+	Output    payload.Payload // payload after the step ran
+	Error     string
+	StartedAt time.Time
+	Duration  time.Duration
+}
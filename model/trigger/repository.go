@@ -0,0 +1,32 @@
+package trigger
+
+// Repository facilitates persistence and retrieval of triggers.
+type Repository interface {
+	// Save persists a trigger.
+	Save(trigger *Trigger) error
+
+	// FindByID retrieves a trigger by its ID.
+	FindByID(ID uint64) (Trigger, error)
+
+	// FindByVariety retrieves all triggers of a variety.
+	FindByVariety(variety string) ([]Trigger, error)
+
+	// FindByWorkflowID retrieves all triggers of a workflow.
+	FindByWorkflowID(ID uint64) ([]Trigger, error)
+
+	// RemoveByWorkflowID removes all triggers of a workflow.
+	RemoveByWorkflowID(ID uint64) error
+
+	// Remove removes a trigger.
+	Remove(ID uint64) error
+}
+
+// StateRepository facilitates persistence of the state that triggers need to remember across restarts,
+// such as the last time a schedule fired.
+type StateRepository interface {
+	// Get retrieves a value for a workflow, returns an empty string when nothing was stored yet.
+	Get(workflowID uint64, key string) (string, error)
+
+	// Set stores a value for a workflow, overwriting any previous value.
+	Set(workflowID uint64, key string, value string) error
+}
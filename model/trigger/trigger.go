@@ -0,0 +1,14 @@
+package trigger
+
+import "neurobot/model/workflow"
+
+// Trigger defines an event which starts a workflow. The meta information varies with each variety of trigger.
+type Trigger struct {
+	ID         uint64 `db:"id,omitempty"`
+	WorkflowID uint64 `db:"workflow_id"`
+	Variety    string `db:"variety"`
+	Meta       map[string]string
+}
+
+// Check makes sure a trigger of a workflow is valid for its variety, the meta of each variety being checked by its own.
+type Check func(workflow workflow.Workflow, trigger Trigger) error
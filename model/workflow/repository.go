@@ -5,6 +5,9 @@ type Repository interface {
 	// Save persists a workflow
 	Save(workflow *Workflow) error
 
+	// FindAll retrieves all workflows, active or not.
+	FindAll() ([]Workflow, error)
+
 	// FindActive retrieves all active workflows.
 	FindActive() ([]Workflow, error)
 
@@ -13,4 +16,7 @@ type Repository interface {
 
 	// FindByIdentifier retrieves a workflow by its unique identifier.
 	FindByIdentifier(identifier string) (Workflow, error)
+
+	// Remove removes a workflow, but not its steps and triggers.
+	Remove(ID uint64) error
 }
@@ -1,9 +1,13 @@
 package workflow
 
 type Workflow struct {
-	ID          uint64 `db:"id,omitempty"`
-	Name        string `db:"name"`
-	Description string `db:"description"`
-	Active      bool   `db:"active"`
-	Identifier  string `db:"identifier"`
+	ID           uint64 `db:"id,omitempty"`
+	Name         string `db:"name"`
+	Description  string `db:"description"`
+	Active       bool   `db:"active"`
+	Identifier   string `db:"identifier"`
+	OnError      string `db:"on_error"`      // what to do when a step fails: continue (default), halt or retry
+	MaxAttempts  uint64 `db:"max_attempts"`  // how many times a step is run in total when retrying, 0 means the default
+	RetryBackoff string `db:"retry_backoff"` // delay before the first retry, doubled for every following one
+	Timeout      string `db:"timeout"`       // how long a run can take before being cancelled, empty means no limit
 }
@@ -16,4 +16,7 @@ type Repository interface {
 
 	// Removes all workflow steps under a workflow
 	RemoveByWorkflowID(ID uint64) error
+
+	// Remove removes a workflow step, with its meta.
+	Remove(ID uint64) error
 }
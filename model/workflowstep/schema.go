@@ -0,0 +1,110 @@
+package workflowstep
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Types of the values of meta fields, which are all strings.
+const (
+	StringMeta   = "string"
+	IntMeta      = "int"
+	BoolMeta     = "bool"
+	DurationMeta = "duration" // like 30s
+	URLMeta      = "url"      // http or https
+)
+
+// MetaField describes a meta field of a variety of workflow steps.
+type MetaField struct {
+	Type     string
+	Required bool
+	Default  string // used when the field is empty
+}
+
+// Schema describes the meta fields a variety of workflow steps accepts, by name.
+type Schema map[string]MetaField
+
+// Validate checks meta against the schema: unknown fields, missing required fields and values which are not of
+// their field's type are errors. The type of values which are templates is not checked, as they are only known once
+// rendered.
+func (schema Schema) Validate(meta map[string]string) error {
+	var names []string
+	for name := range meta {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		field, ok := schema[name]
+		if !ok {
+			return fmt.Errorf("unknown meta field %s", name)
+		}
+
+		value := meta[name]
+		if value == "" || strings.Contains(value, "{{") {
+			continue
+		}
+
+		if err := checkType(field.Type, value); err != nil {
+			return fmt.Errorf("invalid meta field %s: %w", name, err)
+		}
+	}
+
+	for _, name := range schema.names() {
+		if schema[name].Required && meta[name] == "" {
+			return fmt.Errorf("missing meta field %s", name)
+		}
+	}
+
+	return nil
+}
+
+// WithDefaults returns a copy of meta, in which the fields which are empty are set to their default.
+func (schema Schema) WithDefaults(meta map[string]string) map[string]string {
+	result := make(map[string]string, len(meta))
+	for name, value := range meta {
+		result[name] = value
+	}
+
+	for name, field := range schema {
+		if result[name] == "" && field.Default != "" {
+			result[name] = field.Default
+		}
+	}
+
+	return result
+}
+
+func (schema Schema) names() (names []string) {
+	for name := range schema {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return
+}
+
+func checkType(metaType string, value string) (err error) {
+	switch metaType {
+	case StringMeta:
+	case IntMeta:
+		_, err = strconv.ParseInt(value, 10, 64)
+	case BoolMeta:
+		_, err = strconv.ParseBool(value)
+	case DurationMeta:
+		_, err = time.ParseDuration(value)
+	case URLMeta:
+		var u *url.URL
+		if u, err = url.Parse(value); err == nil && u.Scheme != "http" && u.Scheme != "https" {
+			err = fmt.Errorf("url must be http or https, got %q", value)
+		}
+	default:
+		err = fmt.Errorf("unknown meta type %s", metaType)
+	}
+
+	return
+}
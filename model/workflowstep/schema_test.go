@@ -0,0 +1,63 @@
+package workflowstep
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	schema := Schema{
+		"url":     {Type: URLMeta, Required: true},
+		"timeout": {Type: DurationMeta, Default: "30s"},
+		"size":    {Type: IntMeta},
+		"decode":  {Type: BoolMeta},
+		"body":    {Type: StringMeta},
+	}
+
+	tables := []struct {
+		meta  map[string]string
+		valid bool
+	}{
+		{meta: map[string]string{"url": "https://example.com"}, valid: true},
+		{meta: map[string]string{"url": "https://example.com", "timeout": "10s", "size": "10", "decode": "true", "body": "{}"}, valid: true},
+		{meta: map[string]string{"url": "{{ .payload.url }}", "size": "{{ .payload.size }}"}, valid: true},
+		{meta: map[string]string{"url": "https://example.com", "timeout": ""}, valid: true},
+		{meta: map[string]string{}, valid: false},
+		{meta: map[string]string{"url": ""}, valid: false},
+		{meta: map[string]string{"url": "ftp://example.com"}, valid: false},
+		{meta: map[string]string{"url": "https://example.com", "timeout": "soon"}, valid: false},
+		{meta: map[string]string{"url": "https://example.com", "size": "big"}, valid: false},
+		{meta: map[string]string{"url": "https://example.com", "decode": "maybe"}, valid: false},
+		{meta: map[string]string{"url": "https://example.com", "room": "#room"}, valid: false},
+	}
+
+	for _, table := range tables {
+		err := schema.Validate(table.meta)
+		if table.valid && err != nil {
+			t.Errorf("%v: expected to be valid, got: %s", table.meta, err)
+		}
+		if !table.valid && err == nil {
+			t.Errorf("%v: expected to be invalid", table.meta)
+		}
+	}
+}
+
+func TestWithDefaults(t *testing.T) {
+	schema := Schema{
+		"url":     {Type: URLMeta, Required: true},
+		"timeout": {Type: DurationMeta, Default: "30s"},
+		"method":  {Type: StringMeta, Default: "GET"},
+	}
+
+	meta := map[string]string{"url": "https://example.com", "method": "POST"}
+	got := schema.WithDefaults(meta)
+
+	expected := map[string]string{"url": "https://example.com", "method": "POST", "timeout": "30s"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected meta\n%v\n%v", got, expected)
+	}
+
+	if _, ok := meta["timeout"]; ok {
+		t.Errorf("meta should not be modified")
+	}
+}
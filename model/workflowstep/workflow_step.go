@@ -1,12 +1,17 @@
 package workflowstep
 
 type WorkflowStep struct {
-	ID          uint64 `db:"id,omitempty"`
-	Name        string `db:"name"`
-	Description string `db:"description"`
-	Variety     string `db:"variety"`
-	WorkflowID  uint64 `db:"workflow_id"`
-	SortOrder   uint64 `db:"sort_order"`
-	Active      bool   `db:"active"`
-	Meta        map[string]string
+	ID           uint64 `db:"id,omitempty"`
+	Name         string `db:"name"`
+	Description  string `db:"description"`
+	Variety      string `db:"variety"`
+	WorkflowID   uint64 `db:"workflow_id"`
+	SortOrder    uint64 `db:"sort_order"`
+	Active       bool   `db:"active"`
+	Condition    string `db:"condition"`     // the step is skipped when the payload doesn't match it, empty means always run
+	OnError      string `db:"on_error"`      // what to do when the step fails, inherited from the workflow when empty
+	MaxAttempts  uint64 `db:"max_attempts"`  // when retrying, inherited from the workflow when 0
+	RetryBackoff string `db:"retry_backoff"` // when retrying, inherited from the workflow when empty
+	Timeout      string `db:"timeout"`       // how long every attempt can take before being cancelled, empty means no limit
+	Meta         map[string]string
 }
@@ -9,12 +9,31 @@ import (
 	"strings"
 )
 
+// bots are the bots seeded besides the primary bot, their description by username.
+var bots = []struct {
+	username    string
+	description string
+}{
+	{"afkbot", "Used by afk_notifier and !afk"},
+	{"messengerbot", "Used by a8c_matrix()"},
+	{"celebrationbot", "Used for congratulating on birthdays & work anniversaries"},
+}
+
+// BotUsernames returns the usernames of the bots seeded besides the primary bot.
+func BotUsernames() (usernames []string) {
+	for _, b := range bots {
+		usernames = append(usernames, b.username)
+	}
+
+	return
+}
+
 func Bots(repository bot.Repository, config *configuration.Config) {
 	seeds := []bot.Bot{
 		makePrimaryBot("Primary bot", config), // MUST be the first to be created so that ID = 1
-		makeBot("afkbot", "Used by afk_notifier and !afk"),
-		makeBot("messengerbot", "Used by a8c_matrix()"),
-		makeBot("celebrationbot", "Used for congratulating on birthdays & work anniversaries"),
+	}
+	for _, b := range bots {
+		seeds = append(seeds, makeBot(b.username, b.description))
 	}
 
 	for _, seed := range seeds {
@@ -0,0 +1,66 @@
+package fixtures
+
+import (
+	"log"
+	"neurobot/model/trigger"
+
+	"github.com/upper/db/v4"
+)
+
+type triggerMetaRow struct {
+	ID        uint64 `db:"id,omitempty"`
+	TriggerID uint64 `db:"trigger_id"`
+	Key       string `db:"key"`
+	Value     string `db:"value"`
+}
+
+func Triggers(session db.Session) map[string]trigger.Trigger {
+	fixtures := map[string]trigger.Trigger{
+		"Daily": {
+			ID:         1,
+			WorkflowID: 1,
+			Variety:    "schedule",
+			Meta: map[string]string{
+				"cron":     "0 9 * * *",
+				"timezone": "Europe/Lisbon",
+			},
+		},
+		"Weekly": {
+			ID:         2,
+			WorkflowID: 11,
+			Variety:    "schedule",
+			Meta: map[string]string{
+				"cron":    "@weekly",
+				"catchUp": "once",
+			},
+		},
+		"Webhook": {
+			ID:         3,
+			WorkflowID: 1,
+			Variety:    "webhook",
+			Meta: map[string]string{
+				"urlSuffix": "quickstart",
+			},
+		},
+	}
+
+	for _, fixture := range fixtures {
+		_, err := session.Collection("workflow_triggers").Insert(fixture)
+		if err != nil {
+			log.Fatalf("Failed to insert fixtures for triggers: %s", err)
+		}
+
+		for k, v := range fixture.Meta {
+			_, err = session.Collection("workflow_trigger_meta").Insert(triggerMetaRow{
+				TriggerID: fixture.ID,
+				Key:       k,
+				Value:     v,
+			})
+			if err != nil {
+				log.Fatalf("Failed to insert fixtures for trigger meta: %s", err)
+			}
+		}
+	}
+
+	return fixtures
+}
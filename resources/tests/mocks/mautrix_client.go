@@ -11,13 +11,12 @@ import (
 )
 
 type MautrixClientMock interface {
-	Login(*mautrix.ReqLogin) (*mautrix.RespLogin, error)
-	SendText(roomID id.RoomID, text string) (*mautrix.RespSendEvent, error)
-	SendMessageEvent(roomID id.RoomID, eventType event.Type, contentJSON interface{}, extra ...mautrix.ReqSendEvent) (resp *mautrix.RespSendEvent, err error)
+	LoginContext(ctx context.Context, req *mautrix.ReqLogin) (*mautrix.RespLogin, error)
+	SendMessageEventContext(ctx context.Context, roomID id.RoomID, eventType event.Type, contentJSON interface{}) (*mautrix.RespSendEvent, error)
 	WasMessageSent(text string) bool
-	JoinRoom(roomIDorAlias string, serverName string, content interface{}) (resp *mautrix.RespJoinRoom, err error)
+	JoinRoomContext(ctx context.Context, roomIDorAlias string) (*mautrix.RespJoinRoom, error)
 	WasRoomJoined(roomIDorAlias string) bool
-	ResolveAlias(alias id.RoomAlias) (resp *mautrix.RespAliasResolve, err error)
+	ResolveAliasContext(ctx context.Context, alias id.RoomAlias) (*mautrix.RespAliasResolve, error)
 	SyncWithContextWasCalled() bool
 	SyncWithContext(ctx context.Context) error
 }
@@ -35,7 +34,11 @@ func NewMautrixClientMock(creator string) MautrixClientMock {
 	}
 }
 
-func (m *mautrixClientMock) Login(*mautrix.ReqLogin) (*mautrix.RespLogin, error) {
+func (m *mautrixClientMock) LoginContext(ctx context.Context, req *mautrix.ReqLogin) (*mautrix.RespLogin, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	// assume the best for mock purposes
 	homeserverInfo := mautrix.HomeserverInfo{BaseURL: ""}
 	identityServerInfo := mautrix.IdentityServerInfo{BaseURL: ""}
@@ -51,21 +54,19 @@ func (m *mautrixClientMock) Login(*mautrix.ReqLogin) (*mautrix.RespLogin, error)
 	}, nil
 }
 
-func (m *mautrixClientMock) SendText(roomID id.RoomID, text string) (*mautrix.RespSendEvent, error) {
-	// a specific message is designed to return error
-	if text == "throwerr" {
-		return nil, errors.New("whatever")
+func (m *mautrixClientMock) SendMessageEventContext(ctx context.Context, roomID id.RoomID, eventType event.Type, contentJSON interface{}) (*mautrix.RespSendEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 
-	m.msgs = append(m.msgs, text) // store internally for checking, whether this function was called or not
+	body := contentJSON.(event.MessageEventContent).Body
 
-	return &mautrix.RespSendEvent{
-		EventID: "AAAA",
-	}, nil
-}
+	// a specific message is designed to return error
+	if body == "throwerr" {
+		return nil, errors.New("whatever")
+	}
 
-func (m *mautrixClientMock) SendMessageEvent(roomID id.RoomID, eventType event.Type, contentJSON interface{}, extra ...mautrix.ReqSendEvent) (resp *mautrix.RespSendEvent, err error) {
-	m.msgs = append(m.msgs, contentJSON.(event.MessageEventContent).Body) // store internally for checking, whether this function was called or not
+	m.msgs = append(m.msgs, body) // store internally for checking, whether this function was called or not
 
 	return &mautrix.RespSendEvent{
 		EventID: "AAAA",
@@ -82,7 +83,11 @@ func (m *mautrixClientMock) WasMessageSent(text string) bool {
 	return false
 }
 
-func (m *mautrixClientMock) JoinRoom(roomIDorAlias string, serverName string, content interface{}) (resp *mautrix.RespJoinRoom, err error) {
+func (m *mautrixClientMock) JoinRoomContext(ctx context.Context, roomIDorAlias string) (resp *mautrix.RespJoinRoom, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
 	if roomIDorAlias == "" {
 		return nil, errors.New("")
 	}
@@ -102,7 +107,11 @@ func (m *mautrixClientMock) WasRoomJoined(roomIDorAlias string) bool {
 	return false
 }
 
-func (m *mautrixClientMock) ResolveAlias(alias id.RoomAlias) (resp *mautrix.RespAliasResolve, err error) {
+func (m *mautrixClientMock) ResolveAliasContext(ctx context.Context, alias id.RoomAlias) (*mautrix.RespAliasResolve, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	// convert #room:matrix.test to !room:matrix.test as part of mock resolution
 	return &mautrix.RespAliasResolve{
 		RoomID:  id.RoomID(strings.Replace(alias.String(), "#", "!", 1)),
@@ -112,7 +121,11 @@ func (m *mautrixClientMock) ResolveAlias(alias id.RoomAlias) (resp *mautrix.Resp
 
 func (m *mautrixClientMock) SyncWithContext(ctx context.Context) error {
 	m.syncWithContextCalled = true
-	return nil
+
+	// sync until stopped, like the real client
+	<-ctx.Done()
+
+	return ctx.Err()
 }
 
 func (m *mautrixClientMock) SyncWithContextWasCalled() bool {